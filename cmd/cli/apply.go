@@ -0,0 +1,123 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+var applyArgs = &mapper.MapperArguments{}
+
+var applyOptions = mapper.ApplyOptions{}
+
+var applyFilename string
+
+// applyCmd makes the aws-auth configmap match a desired state file
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "apply makes the aws-auth configmap match a desired state file",
+	Long: `apply adds and updates the mapRoles, mapUsers and mapAccounts of a desired state file in the aws-auth configmap.
+With --prune, mappings which were applied before and are no longer in the file are deleted, mappings added by other means are never deleted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		desired, err := readDesiredState(applyFilename)
+		exitOnError(err)
+		policies := mustReadPolicies()
+
+		ctx, cancel := commandContext(applyArgs.Timeout)
+		defer cancel()
+
+		exitOnError(forEachCluster(ctx, applyArgs, os.Stdout, func(ctx context.Context, worker *mapper.AuthMapper, args *mapper.MapperArguments, w io.Writer) error {
+			worker.Backups = backupOptions
+			worker.Policies = policies
+			worker.OverrideProtection = overrideProtection
+			worker.Actor = args.AsUser
+
+			result, err := worker.ApplyWithContext(ctx, &desired, applyOptions)
+			if err != nil {
+				return err
+			}
+			printApplyResult(w, result, applyOptions.DryRun != mapper.DryRunNone)
+			return nil
+		}))
+	},
+}
+
+// readDesiredState reads a desired state file, - reads from stdin
+func readDesiredState(filename string) (mapper.AwsAuthData, error) {
+	data, err := readInput(filename)
+	if err != nil {
+		return mapper.AwsAuthData{}, err
+	}
+
+	desired, err := mapper.UnmarshalAuthData(data)
+	if err != nil {
+		return mapper.AwsAuthData{}, fmt.Errorf("failed to parse %v: %w", filename, err)
+	}
+	return desired, nil
+}
+
+// readInput reads a file, - reads from stdin
+func readInput(filename string) ([]byte, error) {
+	if filename == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(filename)
+}
+
+func printApplyResult(w io.Writer, result *mapper.ApplyResult, dryRun bool) {
+	if !result.Changed() {
+		fmt.Fprintln(w, "aws-auth is up to date")
+		return
+	}
+
+	var suffix string
+	if dryRun {
+		suffix = " (dry run)"
+	}
+
+	for _, m := range result.Added {
+		fmt.Fprintf(w, "%v added%v\n", m.Name(), suffix)
+	}
+	for _, m := range result.Updated {
+		fmt.Fprintf(w, "%v updated%v\n", m.Name(), suffix)
+	}
+	for _, m := range result.Deleted {
+		fmt.Fprintf(w, "%v deleted%v\n", m.Name(), suffix)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().StringVarP(&applyFilename, "filename", "f", "", "Desired state file with mapRoles, mapUsers and mapAccounts, - reads from stdin")
+	applyCmd.Flags().BoolVar(&applyOptions.Prune, "prune", false, "Delete mappings which were applied before and are no longer in the file")
+	applyCmd.Flags().StringVar((*string)(&applyOptions.DryRun), "dry-run", "", "Only print the changes, 'client' computes them locally, 'server' also submits them to the API server without persisting")
+	applyCmd.Flags().Lookup("dry-run").NoOptDefVal = string(mapper.DryRunClient)
+	addBackupFlags(applyCmd)
+	addPolicyFlags(applyCmd)
+	addProtectionFlags(applyCmd)
+	addContextFlags(applyCmd)
+	applyCmd.Flags().StringVar(&applyArgs.KubeconfigPath, "kubeconfig", "", "Path to kubeconfig")
+	applyCmd.Flags().DurationVar(&applyArgs.Timeout, "timeout", 0, "Maximum duration of the operation, zero means no timeout")
+	applyCmd.Flags().StringVar(&applyArgs.AsUser, "as", "", "Username to impersonate for the operation")
+	applyCmd.Flags().StringSliceVar(&applyArgs.AsGroups, "as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
+	_ = applyCmd.MarkFlagRequired("filename")
+}
@@ -0,0 +1,154 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+// backupOptions are shared by the mutating commands, only one command runs at a time
+var backupOptions = mapper.BackupOptions{}
+
+var backupArgs = &mapper.MapperArguments{}
+
+var restoreArgs = &mapper.MapperArguments{}
+
+var restoreOptions = mapper.RestoreOptions{}
+
+// addBackupFlags adds the flags which configure the backup taken before a mutating command writes
+func addBackupFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&backupOptions.Disabled, "no-backup", false, "Do not back up the aws-auth configmap before writing it")
+	cmd.Flags().IntVar(&backupOptions.Retain, "backup-retention", mapper.DefaultBackupRetention, "Number of backups to keep besides those the change history can roll back to, a negative value keeps all of them")
+}
+
+// backupCmd groups the commands which inspect backups
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "backup lists and shows the backups taken before every write of the aws-auth configmap",
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list lists the backups of the aws-auth configmap, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		worker := newWorker(backupArgs)
+
+		ctx, cancel := commandContext(backupArgs.Timeout)
+		defer cancel()
+
+		backups, err := worker.ListBackupsWithContext(ctx)
+		exitOnError(err)
+		exitOnError(printBackups(os.Stdout, backupArgs.Format, backups))
+	},
+}
+
+var backupShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "show prints the mappings of a backup",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		worker := newWorker(backupArgs)
+
+		ctx, cancel := commandContext(backupArgs.Timeout)
+		defer cancel()
+
+		backup, err := worker.GetBackupWithContext(ctx, args[0])
+		exitOnError(err)
+		exitOnError(printMappings(os.Stdout, backupArgs.Format, backup.AuthData))
+	},
+}
+
+// restoreCmd restores a backup
+var restoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "restore replaces the mappings of the aws-auth configmap with those of a backup",
+	Long: `restore replaces the mapRoles, mapUsers and mapAccounts of the aws-auth configmap with those of a backup.
+The current data is backed up first, so a restore can be undone. A backup without role mappings is only
+restored over a configmap which has some with --force.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		worker := newWorker(restoreArgs)
+		worker.Backups = backupOptions
+		worker.Policies = mustReadPolicies()
+		worker.Actor = restoreArgs.AsUser
+
+		ctx, cancel := commandContext(restoreArgs.Timeout)
+		defer cancel()
+
+		restoreOptions.DryRun = restoreArgs.DryRun
+		plan, err := worker.RestoreWithContext(ctx, args[0], restoreOptions)
+		exitOnError(err)
+
+		if restoreArgs.DryRun != mapper.DryRunNone {
+			exitOnError(printPlan(os.Stdout, restoreArgs.Format, plan))
+		}
+	},
+}
+
+// printBackups writes the backups to w as a table or as json
+func printBackups(w io.Writer, format string, backups []*mapper.Backup) error {
+	switch format {
+	case mapper.FormatJSON:
+		if backups == nil {
+			backups = []*mapper.Backup{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(backups)
+	case mapper.FormatTable:
+		fmt.Fprintf(w, "%-26v %-21v %6v %6v %9v\n", "ID", "CREATED", "ROLES", "USERS", "ACCOUNTS")
+		for _, b := range backups {
+			fmt.Fprintf(w, "%-26v %-21v %6d %6d %9d\n", b.ID, b.Created.UTC().Format(time.RFC3339),
+				len(b.AuthData.MapRoles), len(b.AuthData.MapUsers), len(b.AuthData.MapAccounts))
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %q, supported values are %v, %v", mapper.ErrUnsupportedFormat, format, mapper.FormatTable, mapper.FormatJSON)
+}
+
+// addClientFlags adds the kubeconfig, timeout and impersonation flags of a command
+func addClientFlags(cmd *cobra.Command, args *mapper.MapperArguments) {
+	cmd.Flags().StringVar(&args.KubeconfigPath, "kubeconfig", "", "Kubeconfig path")
+	cmd.Flags().DurationVar(&args.Timeout, "timeout", 0, "Maximum duration of the operation, zero means no timeout")
+	cmd.Flags().StringVar(&args.AsUser, "as", "", "Username to impersonate for the operation")
+	cmd.Flags().StringSliceVar(&args.AsGroups, "as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupShowCmd)
+
+	backupListCmd.Flags().StringVar(&backupArgs.Format, "format", mapper.FormatTable, "Output format, one of table or json")
+	addClientFlags(backupListCmd, backupArgs)
+	backupShowCmd.Flags().StringVar(&backupArgs.Format, "format", mapper.FormatTable, "The format in which to display the mappings, one of: table, wide, json, yaml, name")
+	addClientFlags(backupShowCmd, backupArgs)
+
+	restoreCmd.Flags().BoolVar(&restoreOptions.Force, "force", false, "Restore a backup without role mappings over a configmap which has some")
+	addDryRunFlags(restoreCmd, restoreArgs)
+	addBackupFlags(restoreCmd)
+	addPolicyFlags(restoreCmd)
+	addClientFlags(restoreCmd, restoreArgs)
+}
@@ -16,9 +16,22 @@ limitations under the License.
 package cli
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/keikoproj/aws-auth/pkg/mapper"
 	"github.com/onsi/gomega"
+	"github.com/spf13/cobra"
 )
 
 func TestUpsertCmd_KubeconfigFlagBindsToUpsertArgs(t *testing.T) {
@@ -58,6 +71,22 @@ func TestUpsertCmd_AsGroupFlagBindsToUpsertArgs(t *testing.T) {
 	upsertArgs.AsGroups = []string{}
 }
 
+func TestUpsertCmd_AccountFlagsBindToUpsertArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	err := upsertCmd.Flags().Set("mapaccounts", "true")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	err = upsertCmd.Flags().Set("account", "111111111111")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(upsertArgs.MapAccounts).To(gomega.BeTrue())
+	g.Expect(upsertArgs.AccountID).To(gomega.Equal("111111111111"))
+	g.Expect(removeArgs.AccountID).NotTo(gomega.Equal("111111111111"))
+
+	// cleanup
+	upsertArgs.MapAccounts = false
+	upsertArgs.AccountID = ""
+}
+
 func TestRemoveCmd_KubeconfigFlagBindsToRemoveArgs(t *testing.T) {
 	g := gomega.NewWithT(t)
 
@@ -176,3 +205,1018 @@ func TestGetKubernetesClient_ImpersonationOptions(t *testing.T) {
 	g.Expect(options.AsUser).To(gomega.Equal("admin"))
 	g.Expect(options.AsGroups).To(gomega.Equal([]string{"system:masters"}))
 }
+
+func TestExitCode(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(exitCode(errors.New("some failure"))).To(gomega.Equal(exitCodeError))
+	g.Expect(exitCode(mapper.ErrMissingRoleARN)).To(gomega.Equal(exitCodeMissingRoleARN))
+	g.Expect(exitCode(&mapper.MutuallyExclusiveError{Flags: []string{"--mapusers", "--maproles"}})).To(gomega.Equal(exitCodeMutuallyExclusive))
+	g.Expect(exitCode(fmt.Errorf("%w: xml", mapper.ErrUnsupportedFormat))).To(gomega.Equal(exitCodeUnsupportedFormat))
+	g.Expect(exitCode(errors.Join(mapper.ErrMissingUserARN, mapper.ErrMissingUsername))).To(gomega.Equal(exitCodeMissingUserARN))
+
+	// every argument error has its own exit code
+	seen := map[int]bool{exitCodeError: true}
+	for _, e := range exitCodes {
+		g.Expect(seen).NotTo(gomega.HaveKey(e.code))
+		seen[e.code] = true
+	}
+}
+
+func TestExitCode_Cancellation(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(exitCode(fmt.Errorf("waiter cancelled: %w", context.DeadlineExceeded))).To(gomega.Equal(exitCodeTimeout))
+	g.Expect(exitCode(fmt.Errorf("waiter cancelled: %w", context.Canceled))).To(gomega.Equal(exitCodeInterrupted))
+}
+
+func TestCommandContext_Timeout(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	ctx, cancel := commandContext(10 * time.Millisecond)
+	defer cancel()
+	g.Eventually(ctx.Done()).Should(gomega.BeClosed())
+	g.Expect(ctx.Err()).To(gomega.MatchError(context.DeadlineExceeded))
+
+	ctx, cancel = commandContext(0)
+	_, hasDeadline := ctx.Deadline()
+	g.Expect(hasDeadline).To(gomega.BeFalse())
+	cancel()
+	g.Expect(ctx.Err()).To(gomega.MatchError(context.Canceled))
+}
+
+func TestTimeoutFlagBindsToArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	err := upsertCmd.Flags().Set("timeout", "30s")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(upsertArgs.Timeout).To(gomega.Equal(30 * time.Second))
+	g.Expect(removeArgs.Timeout).To(gomega.BeZero())
+
+	err = getCmd.Flags().Set("timeout", "1m")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(getArgs.Timeout).To(gomega.Equal(time.Minute))
+
+	g.Expect(removeByUsernameCmd().Flags().Lookup("timeout")).NotTo(gomega.BeNil())
+
+	// cleanup
+	upsertArgs.Timeout = 0
+	getArgs.Timeout = 0
+}
+
+func TestGetCmd_FormatFlagBindsToGetArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	err := getCmd.Flags().Set("format", "json")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(getArgs.Format).To(gomega.Equal(mapper.FormatJSON))
+
+	// cleanup
+	getArgs.Format = mapper.FormatTable
+}
+
+func testAuthData() mapper.AwsAuthData {
+	return mapper.AwsAuthData{
+		MapRoles:    []*mapper.RolesAuthMap{mapper.NewRolesAuthMap("arn:aws:iam::555555555555:role/node", "system:node:{{EC2PrivateDNSName}}", []string{"system:bootstrappers", "system:nodes"})},
+		MapUsers:    []*mapper.UsersAuthMap{mapper.NewUsersAuthMap("arn:aws:iam::555555555555:user/admin", "admin", []string{"system:masters"})},
+		MapAccounts: []string{"666666666666"},
+	}
+}
+
+func TestPrintMappings_JSON(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(printMappings(&buf, mapper.FormatJSON, testAuthData())).To(gomega.Succeed())
+
+	var list mapper.MappingList
+	g.Expect(json.Unmarshal(buf.Bytes(), &list)).To(gomega.Succeed())
+	g.Expect(list.APIVersion).To(gomega.Equal(mapper.MappingListAPIVersion))
+	g.Expect(list.Items).To(gomega.HaveLen(3))
+	g.Expect(list.Items[0].Kind).To(gomega.Equal(mapper.RoleMappingKind))
+	g.Expect(list.Items[0].Groups).To(gomega.Equal([]string{"system:bootstrappers", "system:nodes"}))
+	g.Expect(list.Items[2].Account).To(gomega.Equal("666666666666"))
+}
+
+func TestPrintMappings_YAML(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(printMappings(&buf, mapper.FormatYAML, testAuthData())).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.HavePrefix("apiVersion: aws-auth.keikoproj.io/v1\nkind: MappingList\nitems:\n  - kind: RoleMapping\n    arn: arn:aws:iam::555555555555:role/node\n"))
+	g.Expect(buf.String()).To(gomega.ContainSubstring("  - kind: AccountMapping\n    arn: \"\"\n    username: \"\"\n    groups: []\n    account: \"666666666666\"\n"))
+}
+
+func TestPrintMappings_Name(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(printMappings(&buf, mapper.FormatName, testAuthData())).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal("role/arn:aws:iam::555555555555:role/node\nuser/arn:aws:iam::555555555555:user/admin\naccount/666666666666\n"))
+}
+
+func TestPrintMappings_Table(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(printMappings(&buf, mapper.FormatTable, testAuthData())).To(gomega.Succeed())
+	lines := strings.Split(buf.String(), "\n")
+	g.Expect(lines[0]).To(gomega.MatchRegexp(`Type\s+ARN\s+Username\s+Groups`))
+	g.Expect(lines[1]).To(gomega.MatchRegexp(`Role Mapping\s+arn:aws:iam::555555555555:role/node\s+system:node`))
+
+	buf.Reset()
+	g.Expect(printMappings(&buf, mapper.FormatWide, testAuthData())).To(gomega.Succeed())
+	lines = strings.Split(buf.String(), "\n")
+	g.Expect(lines[0]).To(gomega.MatchRegexp(`Type\s+ARN\s+Account\s+Username\s+Groups`))
+	g.Expect(lines[1]).To(gomega.MatchRegexp(`Role Mapping\s+arn:aws:iam::555555555555:role/node\s+555555555555\s+system:node`))
+}
+
+func TestGetCmd_FilterFlagsBindToGetArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(getCmd.Flags().Set("maproles", "true")).To(gomega.Succeed())
+	g.Expect(getCmd.Flags().Set("rolearn", "arn:aws:iam::555555555555:role/*")).To(gomega.Succeed())
+	g.Expect(getCmd.Flags().Set("username", "admin")).To(gomega.Succeed())
+	g.Expect(getCmd.Flags().Set("group", "system:masters")).To(gomega.Succeed())
+	g.Expect(getCmd.Flags().Set("account", "555555555555")).To(gomega.Succeed())
+
+	g.Expect(getArgs.Filter()).To(gomega.Equal(&mapper.MappingFilter{
+		MapRoles:  true,
+		RoleARN:   "arn:aws:iam::555555555555:role/*",
+		Username:  "admin",
+		AccountID: "555555555555",
+		Groups:    []string{"system:masters"},
+	}))
+	g.Expect(getArgs.Validate()).To(gomega.Succeed())
+	g.Expect(upsertArgs.RoleARN).To(gomega.BeEmpty())
+
+	// cleanup
+	getArgs.MapRoles = false
+	getArgs.RoleARN = ""
+	getArgs.Username = ""
+	getArgs.Groups = []string{}
+	getArgs.AccountID = ""
+}
+
+func TestApplyCmd_FlagsBindToApplyArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(applyCmd.Flags().Set("filename", "desired.yaml")).To(gomega.Succeed())
+	g.Expect(applyCmd.Flags().Set("prune", "true")).To(gomega.Succeed())
+	g.Expect(applyCmd.Flags().Set("as", "test-user")).To(gomega.Succeed())
+	g.Expect(applyFilename).To(gomega.Equal("desired.yaml"))
+	g.Expect(applyOptions.Prune).To(gomega.BeTrue())
+	g.Expect(applyArgs.AsUser).To(gomega.Equal("test-user"))
+	g.Expect(upsertArgs.AsUser).NotTo(gomega.Equal("test-user"))
+
+	// cleanup
+	applyFilename = ""
+	applyOptions.Prune = false
+	applyArgs.AsUser = ""
+}
+
+func TestReadDesiredState(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "desired.yaml")
+	err := os.WriteFile(path, []byte("mapAccounts:\n  - \"555555555555\"\n"), 0644)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	desired, err := readDesiredState(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(desired.MapAccounts).To(gomega.Equal([]string{"555555555555"}))
+
+	err = os.WriteFile(path, []byte("mapAcounts: []\n"), 0644)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, err = readDesiredState(path)
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(err.Error()).To(gomega.ContainSubstring(path))
+}
+
+func TestPrintApplyResult(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var buf bytes.Buffer
+	printApplyResult(&buf, &mapper.ApplyResult{}, false)
+	g.Expect(buf.String()).To(gomega.Equal("aws-auth is up to date\n"))
+
+	buf.Reset()
+	printApplyResult(&buf, &mapper.ApplyResult{
+		Added:   []mapper.Mapping{{Kind: mapper.RoleMappingKind, ARN: "arn:aws:iam::555555555555:role/ci"}},
+		Deleted: []mapper.Mapping{{Kind: mapper.AccountMappingKind, Account: "555555555555"}},
+	}, false)
+	g.Expect(buf.String()).To(gomega.Equal("role/arn:aws:iam::555555555555:role/ci added\naccount/555555555555 deleted\n"))
+}
+
+func TestDryRunFlagBindsToArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	// --dry-run without a value is a client dry run
+	g.Expect(upsertCmd.ParseFlags([]string{"--dry-run"})).To(gomega.Succeed())
+	g.Expect(upsertArgs.DryRun).To(gomega.Equal(mapper.DryRunClient))
+	g.Expect(upsertArgs.Format).To(gomega.Equal(mapper.FormatDiff))
+
+	g.Expect(removeCmd.ParseFlags([]string{"--dry-run=server", "--format", "json"})).To(gomega.Succeed())
+	g.Expect(removeArgs.DryRun).To(gomega.Equal(mapper.DryRunServer))
+	g.Expect(removeArgs.Format).To(gomega.Equal(mapper.FormatJSON))
+
+	g.Expect(removeByUsernameCmd().Flags().Lookup("dry-run")).NotTo(gomega.BeNil())
+	g.Expect(applyCmd.Flags().Lookup("dry-run")).NotTo(gomega.BeNil())
+
+	// cleanup
+	upsertArgs.DryRun = mapper.DryRunNone
+	removeArgs.DryRun = mapper.DryRunNone
+	removeArgs.Format = mapper.FormatDiff
+}
+
+func TestNormalizeCmd_FlagsBindToNormalizeArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(normalizeArgs.OperationType).To(gomega.Equal(mapper.OperationNormalize))
+	g.Expect(normalizeCmd.ParseFlags([]string{"--kubeconfig", "/tmp/kubeconfig", "--dry-run"})).To(gomega.Succeed())
+	g.Expect(normalizeArgs.KubeconfigPath).To(gomega.Equal("/tmp/kubeconfig"))
+	g.Expect(normalizeArgs.DryRun).To(gomega.Equal(mapper.DryRunClient))
+
+	g.Expect(upsertCmd.ParseFlags([]string{"--ignore-path"})).To(gomega.Succeed())
+	g.Expect(upsertArgs.IgnorePath).To(gomega.BeTrue())
+	g.Expect(removeCmd.Flags().Lookup("ignore-path")).NotTo(gomega.BeNil())
+
+	// cleanup
+	normalizeArgs.KubeconfigPath = ""
+	normalizeArgs.DryRun = mapper.DryRunNone
+	upsertArgs.IgnorePath = false
+}
+
+func TestRenderCmd_FlagsBindToRenderArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(renderCmd.ParseFlags([]string{"--arn", "arn:aws:iam::555555555555:role/ci", "--session-name", "build-1", "--format", "json"})).To(gomega.Succeed())
+	g.Expect(renderCaller.CallerARN).To(gomega.Equal("arn:aws:iam::555555555555:role/ci"))
+	g.Expect(renderCaller.SessionName).To(gomega.Equal("build-1"))
+	g.Expect(renderArgs.Format).To(gomega.Equal(mapper.FormatJSON))
+
+	// cleanup
+	*renderCaller = mapper.RenderArguments{}
+	renderArgs.Format = mapper.FormatTable
+}
+
+func TestPrintIdentity(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	identity := &mapper.Identity{
+		Mapping:    mapper.Mapping{Kind: mapper.RoleMappingKind, ARN: "arn:aws:iam::555555555555:role/node"},
+		Username:   "system:node:{{EC2PrivateDNSName}}",
+		Groups:     []string{"system:bootstrappers", "system:nodes"},
+		Unresolved: []string{mapper.PlaceholderEC2PrivateDNSName},
+	}
+
+	var buf bytes.Buffer
+	g.Expect(printIdentity(&buf, mapper.FormatTable, identity)).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal(`mapping:  role/arn:aws:iam::555555555555:role/node
+username: system:node:{{EC2PrivateDNSName}}
+groups:   system:bootstrappers, system:nodes
+unresolved placeholders: {{EC2PrivateDNSName}}
+`))
+
+	buf.Reset()
+	g.Expect(printIdentity(&buf, mapper.FormatJSON, identity)).To(gomega.Succeed())
+	var out map[string]interface{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &out)).To(gomega.Succeed())
+	g.Expect(out).To(gomega.HaveKeyWithValue("username", "system:node:{{EC2PrivateDNSName}}"))
+
+	g.Expect(printIdentity(&buf, mapper.FormatYAML, identity)).To(gomega.MatchError(mapper.ErrUnsupportedFormat))
+}
+
+func TestResolveCmd_FlagsBindToResolveArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(resolveCmd.ParseFlags([]string{"--arn", "arn:aws:sts::555555555555:assumed-role/ci/build", "--kubeconfig", "/tmp/kubeconfig"})).To(gomega.Succeed())
+	g.Expect(resolveCaller.CallerARN).To(gomega.Equal("arn:aws:sts::555555555555:assumed-role/ci/build"))
+	g.Expect(resolveArgs.KubeconfigPath).To(gomega.Equal("/tmp/kubeconfig"))
+	g.Expect(resolveArgs.Format).To(gomega.Equal(mapper.FormatTable))
+
+	// cleanup
+	*resolveCaller = mapper.RenderArguments{}
+	resolveArgs.KubeconfigPath = ""
+}
+
+func TestPrintNoMatch(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var buf bytes.Buffer
+	printNoMatch(&buf, &mapper.NoMatchError{
+		CallerARN: "arn:aws:iam::555555555555:user/bob",
+		Reasons:   []string{"no mapUsers entry for arn:aws:iam::555555555555:user/bob", "account 555555555555 is not in mapAccounts"},
+	})
+	g.Expect(buf.String()).To(gomega.Equal(`no mapping matches for arn:aws:iam::555555555555:user/bob
+  - no mapUsers entry for arn:aws:iam::555555555555:user/bob
+  - account 555555555555 is not in mapAccounts
+`))
+}
+
+func TestBackupFlagsBindToBackupOptions(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(backupOptions.Retain).To(gomega.Equal(mapper.DefaultBackupRetention))
+	g.Expect(upsertCmd.ParseFlags([]string{"--no-backup", "--backup-retention", "3"})).To(gomega.Succeed())
+	g.Expect(backupOptions).To(gomega.Equal(mapper.BackupOptions{Disabled: true, Retain: 3}))
+
+	for _, cmd := range []*cobra.Command{removeCmd, removeByUsernameCmd(), applyCmd, normalizeCmd, restoreCmd} {
+		g.Expect(cmd.Flags().Lookup("no-backup")).NotTo(gomega.BeNil(), cmd.Name())
+	}
+
+	g.Expect(restoreCmd.ParseFlags([]string{"--force", "--dry-run"})).To(gomega.Succeed())
+	g.Expect(restoreOptions.Force).To(gomega.BeTrue())
+	g.Expect(restoreArgs.DryRun).To(gomega.Equal(mapper.DryRunClient))
+
+	// cleanup
+	backupOptions = mapper.BackupOptions{Retain: mapper.DefaultBackupRetention}
+	restoreOptions = mapper.RestoreOptions{}
+	restoreArgs.DryRun = mapper.DryRunNone
+}
+
+func TestOverrideProtectionFlag(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	for _, cmd := range []*cobra.Command{upsertCmd, removeCmd, removeByUsernameCmd(), applyCmd} {
+		g.Expect(cmd.Flags().Lookup("override-protection")).NotTo(gomega.BeNil(), cmd.Name())
+	}
+	g.Expect(normalizeCmd.Flags().Lookup("override-protection")).To(gomega.BeNil())
+
+	g.Expect(removeCmd.ParseFlags([]string{"--override-protection"})).To(gomega.Succeed())
+	g.Expect(overrideProtection).To(gomega.BeTrue())
+
+	// cleanup
+	overrideProtection = false
+}
+
+func TestPolicyFlag(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	for _, cmd := range []*cobra.Command{upsertCmd, removeCmd, removeByUsernameCmd(), applyCmd, normalizeCmd, restoreCmd, rollbackCmd} {
+		g.Expect(cmd.Flags().Lookup("policy")).NotTo(gomega.BeNil(), cmd.Name())
+	}
+
+	g.Expect(upsertCmd.ParseFlags([]string{"--policy", "rules.yaml"})).To(gomega.Succeed())
+	g.Expect(policyFilename).To(gomega.Equal("rules.yaml"))
+
+	// cleanup
+	policyFilename = ""
+}
+
+func TestReadPolicies(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	policies, err := readPolicies("")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(policies).To(gomega.BeEmpty())
+
+	filename := filepath.Join(t.TempDir(), "rules.yaml")
+	g.Expect(os.WriteFile(filename, []byte("rules:\n  - name: no-users\n    match: {kinds: [user]}\n    deny: true\n"), 0600)).To(gomega.Succeed())
+	policies, err = readPolicies(filename)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(policies).To(gomega.HaveLen(1))
+
+	g.Expect(os.WriteFile(filename, []byte("rules:\n  - name: no-users\n"), 0600)).To(gomega.Succeed())
+	_, err = readPolicies(filename)
+	g.Expect(err).To(gomega.MatchError(mapper.ErrInvalidPolicy))
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeInvalidPolicy))
+}
+
+func TestLintCmd_FlagsBindToLintArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(lintCmd.ParseFlags([]string{"--format", "sarif", "--severity-threshold", "warning"})).To(gomega.Succeed())
+	g.Expect(lintArgs.Format).To(gomega.Equal(mapper.FormatSARIF))
+	g.Expect(lintThreshold).To(gomega.Equal("warning"))
+
+	// cleanup
+	lintArgs.Format = mapper.FormatTable
+	lintThreshold = string(mapper.SeverityError)
+}
+
+func TestFileFlag(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	for _, cmd := range []*cobra.Command{upsertCmd, removeCmd, getCmd, lintCmd, applyCmd, historyCmd} {
+		g.Expect(cmd.InheritedFlags().Lookup("file")).NotTo(gomega.BeNil(), cmd.Name())
+	}
+
+	path := filepath.Join(t.TempDir(), "aws-auth.yaml")
+	g.Expect(rootCmd.PersistentFlags().Set("file", path)).To(gomega.Succeed())
+	g.Expect(manifestFile).To(gomega.Equal(path))
+
+	// the file is used without a kubeconfig
+	worker := newWorker(&mapper.MapperArguments{KubeconfigPath: "/tmp/nonexistent-kubeconfig-12345"})
+	g.Expect(worker.KubernetesClient).To(gomega.BeNil())
+	_, err := worker.ListBackups()
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeClusterRequired))
+
+	// cleanup
+	manifestFile = ""
+}
+
+func TestConfigMapFlags(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	for _, cmd := range []*cobra.Command{upsertCmd, getCmd, backupListCmd, restoreCmd} {
+		g.Expect(cmd.InheritedFlags().Lookup("namespace")).NotTo(gomega.BeNil(), cmd.Name())
+		g.Expect(cmd.InheritedFlags().Lookup("configmap-name")).NotTo(gomega.BeNil(), cmd.Name())
+	}
+	g.Expect(configMapNamespace).To(gomega.Equal(mapper.AwsAuthNamespace))
+	g.Expect(configMapName).To(gomega.Equal(mapper.AwsAuthName))
+
+	g.Expect(rootCmd.PersistentFlags().Set("namespace", "auth")).To(gomega.Succeed())
+	g.Expect(rootCmd.PersistentFlags().Set("configmap-name", "aws-auth-staging")).To(gomega.Succeed())
+	g.Expect(configMapNamespace).To(gomega.Equal("auth"))
+	g.Expect(configMapName).To(gomega.Equal("aws-auth-staging"))
+
+	// cleanup
+	configMapNamespace = mapper.AwsAuthNamespace
+	configMapName = mapper.AwsAuthName
+}
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com
+- name: staging
+  cluster:
+    server: https://staging.example.com
+contexts:
+- name: staging
+  context:
+    cluster: staging
+    user: ci
+- name: prod
+  context:
+    cluster: prod
+    user: ci
+current-context: staging
+users:
+- name: ci
+  user:
+    token: secret
+`
+
+func TestContextFlags(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(backupListCmd.InheritedFlags().Lookup("context")).NotTo(gomega.BeNil())
+	for _, cmd := range []*cobra.Command{upsertCmd, removeCmd, getCmd, applyCmd} {
+		g.Expect(cmd.InheritedFlags().Lookup("context")).NotTo(gomega.BeNil(), cmd.Name())
+		g.Expect(cmd.Flags().Lookup("all-contexts")).NotTo(gomega.BeNil(), cmd.Name())
+		g.Expect(cmd.Flags().Lookup("contexts")).NotTo(gomega.BeNil(), cmd.Name())
+		g.Expect(cmd.Flags().Lookup("concurrency")).NotTo(gomega.BeNil(), cmd.Name())
+	}
+	g.Expect(lintCmd.Flags().Lookup("all-contexts")).To(gomega.BeNil())
+
+	g.Expect(upsertCmd.Flags().Set("contexts", "prod,staging")).To(gomega.Succeed())
+	g.Expect(upsertCmd.Flags().Set("concurrency", "2")).To(gomega.Succeed())
+	g.Expect(clusterOptions.Contexts).To(gomega.Equal([]string{"prod", "staging"}))
+	g.Expect(clusterOptions.Concurrency).To(gomega.Equal(2))
+
+	// cleanup
+	clusterOptions.Contexts = []string{}
+	clusterOptions.Concurrency = 5
+}
+
+func TestKubeContexts(t *testing.T) {
+	g := gomega.NewWithT(t)
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	g.Expect(os.WriteFile(path, []byte(testKubeconfig), 0600)).To(gomega.Succeed())
+
+	contexts, err := kubeContexts(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(contexts).To(gomega.Equal([]string{"prod", "staging"}))
+
+	config, err := kubeconfigLoader(path, "").ClientConfig()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(config.Host).To(gomega.Equal("https://staging.example.com"))
+	config, err = kubeconfigLoader(path, "prod").ClientConfig()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(config.Host).To(gomega.Equal("https://prod.example.com"))
+
+	_, err = getKubernetesClient(path, kubeOptions{Context: "prod"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, err = getKubernetesClient(path, kubeOptions{Context: "dev"})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("dev")))
+}
+
+func TestSelectedContexts(t *testing.T) {
+	g := gomega.NewWithT(t)
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	g.Expect(os.WriteFile(path, []byte(testKubeconfig), 0600)).To(gomega.Succeed())
+	defer func() {
+		clusterOptions.AllContexts = false
+		clusterOptions.Contexts = []string{}
+		clusterOptions.Concurrency = 5
+		kubeContext = ""
+		manifestFile = ""
+	}()
+
+	contexts, err := selectedContexts(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(contexts).To(gomega.BeNil())
+
+	clusterOptions.AllContexts = true
+	contexts, err = selectedContexts(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(contexts).To(gomega.Equal([]string{"prod", "staging"}))
+
+	clusterOptions.Contexts = []string{"staging"}
+	_, err = selectedContexts(path)
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeMutuallyExclusive))
+
+	clusterOptions.AllContexts = false
+	contexts, err = selectedContexts(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(contexts).To(gomega.Equal([]string{"staging"}))
+
+	kubeContext = "prod"
+	_, err = selectedContexts(path)
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeMutuallyExclusive))
+	kubeContext = ""
+
+	manifestFile = "aws-auth.yaml"
+	_, err = selectedContexts(path)
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeMutuallyExclusive))
+	manifestFile = ""
+
+	clusterOptions.Concurrency = 0
+	_, err = selectedContexts(path)
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeInvalidConcurrency))
+}
+
+func TestFanOut(t *testing.T) {
+	g := gomega.NewWithT(t)
+	contexts := []string{"a", "b", "c", "d", "e", "f"}
+
+	var mu sync.Mutex
+	var running, peak int
+	results := fanOut(context.Background(), contexts, 2, func(ctx context.Context, kubeContext string, w io.Writer) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprintf(w, "ran on %v\n", kubeContext)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if kubeContext == "c" {
+			return mapper.ErrProtected
+		}
+		return nil
+	})
+
+	g.Expect(peak).To(gomega.Equal(2))
+	g.Expect(results).To(gomega.HaveLen(len(contexts)))
+	for i, r := range results {
+		g.Expect(r.Context).To(gomega.Equal(contexts[i]))
+		g.Expect(r.Output.String()).To(gomega.Equal("ran on " + contexts[i] + "\n"))
+	}
+	g.Expect(results[2].Err).To(gomega.MatchError(mapper.ErrProtected))
+	g.Expect(exitCode(clusterResultsError(results))).To(gomega.Equal(exitCodePartialFailure))
+
+	// clusters which did not start when the context is done fail with its error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = fanOut(ctx, contexts, 1, func(ctx context.Context, kubeContext string, w io.Writer) error {
+		return ctx.Err()
+	})
+	g.Expect(exitCode(clusterResultsError(results))).To(gomega.Equal(exitCodeInterrupted))
+}
+
+func TestForEachCluster_Logger(t *testing.T) {
+	g := gomega.NewWithT(t)
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	g.Expect(os.WriteFile(path, []byte(testKubeconfig), 0600)).To(gomega.Succeed())
+	clusterOptions.Contexts = []string{"prod", "staging"}
+	defer func() { clusterOptions.Contexts = []string{} }()
+
+	// the messages of every cluster are prefixed with its context
+	var buf bytes.Buffer
+	err := forEachCluster(context.Background(), &mapper.MapperArguments{KubeconfigPath: path}, &buf, func(ctx context.Context, worker *mapper.AuthMapper, args *mapper.MapperArguments, w io.Writer) error {
+		fmt.Fprintln(w, worker.Logger.Prefix())
+		return nil
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(buf.String()).To(gomega.HavePrefix("==> prod <==\n[prod] \n\n==> staging <==\n[staging] \n"))
+}
+
+func TestClusterResultsError(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	results := []*clusterResult{{Context: "prod"}, {Context: "staging"}}
+	g.Expect(clusterResultsError(results)).To(gomega.Succeed())
+
+	results[1].Err = errors.New("connection refused")
+	err := clusterResultsError(results)
+	g.Expect(err).To(gomega.MatchError("the operation failed on some of the clusters: 1 of 2 failed: [staging]"))
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodePartialFailure))
+
+	// when every cluster failed the exit code of the first failure is kept
+	results[0].Err = mapper.ErrPolicyViolation
+	err = clusterResultsError(results)
+	g.Expect(err).NotTo(gomega.MatchError(errPartialFailure))
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodePolicyViolation))
+}
+
+func TestPrintClusterResults(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	results := []*clusterResult{{Context: "prod"}, {Context: "staging-cluster", Err: errors.New("connection refused")}}
+	results[0].Output.WriteString("aws-auth is up to date\n")
+
+	var buf bytes.Buffer
+	printClusterResults(&buf, results)
+	g.Expect(buf.String()).To(gomega.Equal(`==> prod <==
+aws-auth is up to date
+
+CONTEXT         RESULT ERROR
+prod            ok
+staging-cluster failed connection refused
+`))
+}
+
+func TestPrintClusterReport(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	results := []*clusterResult{{Context: "prod"}, {Context: "staging", Err: errors.New("connection refused")}}
+	results[0].Output.WriteString("[\n  {\n    \"kind\": \"account\",\n    \"account\": \"111111111111\"\n  }\n]\n")
+
+	var buf bytes.Buffer
+	g.Expect(printClusterReport(&buf, mapper.FormatJSON, results)).To(gomega.Succeed())
+	var reports []map[string]interface{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &reports)).To(gomega.Succeed())
+	g.Expect(reports).To(gomega.HaveLen(2))
+	g.Expect(reports[0]["context"]).To(gomega.Equal("prod"))
+	g.Expect(reports[0]["output"]).To(gomega.Equal([]interface{}{map[string]interface{}{"kind": "account", "account": "111111111111"}}))
+	g.Expect(reports[1]).To(gomega.Equal(map[string]interface{}{"context": "staging", "result": "failed", "error": "connection refused"}))
+
+	results[0].Output.Reset()
+	results[0].Output.WriteString("- kind: account\n  account: \"111111111111\"\n")
+	buf.Reset()
+	g.Expect(printClusterReport(&buf, mapper.FormatYAML, results)).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal(`- context: prod
+  result: ok
+  output:
+    - kind: account
+      account: "111111111111"
+- context: staging
+  result: failed
+  error: connection refused
+`))
+}
+
+func TestDiffSyncCmd_FlagsBindToArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(diffCmd.Flags().Set("from-context", "prod-east")).To(gomega.Succeed())
+	g.Expect(diffCmd.Flags().Set("to-file", "aws-auth.yaml")).To(gomega.Succeed())
+	g.Expect(diffCmd.Flags().Set("rolearn", "arn:aws:iam::*:role/ci-*")).To(gomega.Succeed())
+	g.Expect(diffFrom).To(gomega.Equal(authSource{Context: "prod-east"}))
+	g.Expect(diffTo).To(gomega.Equal(authSource{File: "aws-auth.yaml"}))
+	g.Expect(diffArgs.RoleARN).To(gomega.Equal("arn:aws:iam::*:role/ci-*"))
+
+	g.Expect(syncOptions.DryRun).To(gomega.Equal(mapper.DryRunClient))
+	g.Expect(syncCmd.Flags().Set("to-context", "prod-west")).To(gomega.Succeed())
+	g.Expect(syncCmd.Flags().Set("account", "111111111111")).To(gomega.Succeed())
+	g.Expect(syncCmd.Flags().Set("confirm", "true")).To(gomega.Succeed())
+	g.Expect(syncTo).To(gomega.Equal(authSource{Context: "prod-west"}))
+	g.Expect(syncArgs.AccountID).To(gomega.Equal("111111111111"))
+	g.Expect(syncConfirm).To(gomega.BeTrue())
+	for _, name := range []string{"no-backup", "policy", "override-protection", "kubeconfig", "timeout", "as"} {
+		g.Expect(syncCmd.Flags().Lookup(name)).NotTo(gomega.BeNil(), name)
+	}
+
+	// cleanup
+	diffFrom, diffTo, syncTo = authSource{}, authSource{}, authSource{}
+	diffArgs.RoleARN = ""
+	syncArgs.AccountID = ""
+	syncConfirm = false
+}
+
+func TestValidateSources(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(validateSources(authSource{Context: "prod-east"}, authSource{File: "aws-auth.yaml"})).To(gomega.Succeed())
+	err := validateSources(authSource{Context: "prod-east", File: "aws-auth.yaml"}, authSource{})
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeMutuallyExclusive))
+	err = validateSources(authSource{}, authSource{Context: "prod-west", File: "aws-auth.yaml"})
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeMutuallyExclusive))
+
+	// the global flags do not select a side and are not silently ignored
+	manifestFile = "aws-auth.yaml"
+	err = validateSources(authSource{Context: "prod-east"}, authSource{Context: "prod-west"})
+	g.Expect(err).To(gomega.MatchError("--file, --from-file or --to-file are mutually exclusive"))
+	manifestFile = ""
+	kubeContext = "prod-east"
+	err = validateSources(authSource{}, authSource{Context: "prod-west"})
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeMutuallyExclusive))
+	kubeContext = ""
+}
+
+func TestValidatePlanFormat(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(validatePlanFormat(mapper.FormatDiff)).To(gomega.Succeed())
+	g.Expect(validatePlanFormat(mapper.FormatJSON)).To(gomega.Succeed())
+	err := validatePlanFormat("yaml")
+	g.Expect(exitCode(err)).To(gomega.Equal(exitCodeUnsupportedFormat))
+}
+
+func TestSyncFiles(t *testing.T) {
+	g := gomega.NewWithT(t)
+	dir := t.TempDir()
+	from := authSource{File: filepath.Join(dir, "prod-east.yaml")}
+	to := authSource{File: filepath.Join(dir, "prod-west.yaml")}
+	g.Expect(os.WriteFile(from.File, []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: aws-auth
+  namespace: kube-system
+data:
+  mapRoles: |
+    - rolearn: arn:aws:iam::111111111111:role/ci-deploy
+      username: ci
+      groups:
+      - deployers
+    - rolearn: arn:aws:iam::222222222222:role/node
+      username: system:node:{{EC2PrivateDNSName}}
+      groups:
+      - system:nodes
+`), 0600)).To(gomega.Succeed())
+
+	ctx := context.Background()
+	args := &mapper.MapperArguments{AccountID: "111111111111"}
+
+	// the missing target file is read as empty and not created
+	source, err := readAuthSource(ctx, args, from)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	target, err := readAuthSource(ctx, args, to)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(target.MapRoles).To(gomega.BeEmpty())
+	_, err = os.Stat(to.File)
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+
+	filter := args.Filter()
+	selected, targetSelected := source.Filter(filter), target.Filter(filter)
+	var buf bytes.Buffer
+	g.Expect(printPlan(&buf, mapper.FormatDiff, mapper.DiffAuthData(&targetSelected, &selected))).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.ContainSubstring("+ rolearn: arn:aws:iam::111111111111:role/ci-deploy"))
+	g.Expect(buf.String()).NotTo(gomega.ContainSubstring("role/node"))
+
+	worker, err := sourceWorker(args, to)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	plan, err := worker.SyncWithContext(ctx, &source, mapper.SyncOptions{Filter: filter})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(1))
+
+	target, err = readAuthSource(ctx, args, to)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(target.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(target.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::111111111111:role/ci-deploy"))
+}
+
+func TestExportImportCmd_FlagsBindToArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(exportOptions.Format).To(gomega.Equal(mapper.FormatYAML))
+	g.Expect(exportCmd.Flags().Set("format", "eksctl")).To(gomega.Succeed())
+	g.Expect(exportCmd.Flags().Set("cluster-name", "prod-east")).To(gomega.Succeed())
+	g.Expect(exportCmd.Flags().Set("maproles", "true")).To(gomega.Succeed())
+	g.Expect(exportOptions.Format).To(gomega.Equal(mapper.FormatEksctl))
+	g.Expect(exportOptions.ClusterName).To(gomega.Equal("prod-east"))
+	g.Expect(exportArgs.MapRoles).To(gomega.BeTrue())
+
+	g.Expect(importCmd.ParseFlags([]string{"-f", "aws-auth.tfvars", "--format", "terraform", "--prune", "--dry-run"})).To(gomega.Succeed())
+	g.Expect(importFilename).To(gomega.Equal("aws-auth.tfvars"))
+	g.Expect(importFormat).To(gomega.Equal(mapper.FormatTerraform))
+	g.Expect(importOptions.Prune).To(gomega.BeTrue())
+	g.Expect(importOptions.DryRun).To(gomega.Equal(mapper.DryRunClient))
+	for _, name := range []string{"no-backup", "policy", "override-protection", "kubeconfig", "timeout", "as"} {
+		g.Expect(importCmd.Flags().Lookup(name)).NotTo(gomega.BeNil(), name)
+	}
+
+	// cleanup
+	exportOptions = mapper.ExportOptions{Format: mapper.FormatYAML}
+	exportArgs.MapRoles = false
+	importFilename, importFormat = "", mapper.FormatYAML
+	importOptions = mapper.ApplyOptions{}
+}
+
+func TestExportImportFiles(t *testing.T) {
+	g := gomega.NewWithT(t)
+	dir := t.TempDir()
+	source := filepath.Join(dir, "prod-east.yaml")
+	target := filepath.Join(dir, "prod-west.yaml")
+	g.Expect(os.WriteFile(source, []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: aws-auth
+  namespace: kube-system
+data:
+  mapRoles: |
+    - rolearn: arn:aws:iam::111111111111:role/ci-deploy
+      username: ci
+      groups:
+      - deployers
+  mapAccounts: |
+    - "111111111111"
+`), 0600)).To(gomega.Succeed())
+
+	ctx := context.Background()
+	for _, format := range mapper.ExportFormats {
+		var buf bytes.Buffer
+		err := mapper.NewFromFile(source, true).ExportWithContext(ctx, &buf, mapper.ExportOptions{Format: format, ClusterName: "prod-east"})
+		g.Expect(err).NotTo(gomega.HaveOccurred(), format)
+
+		exported := filepath.Join(dir, "export."+format)
+		g.Expect(os.WriteFile(exported, buf.Bytes(), 0600)).To(gomega.Succeed())
+		data, err := readInput(exported)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		desired, err := mapper.ImportAuthData(data, format)
+		g.Expect(err).NotTo(gomega.HaveOccurred(), format)
+
+		result, err := mapper.NewFromFile(target, true).ApplyWithContext(ctx, &desired, mapper.ApplyOptions{DryRun: mapper.DryRunClient})
+		g.Expect(err).NotTo(gomega.HaveOccurred(), format)
+		g.Expect(result.Added).NotTo(gomega.BeEmpty(), format)
+		_, err = os.Stat(target)
+		g.Expect(os.IsNotExist(err)).To(gomega.BeTrue(), format)
+	}
+}
+
+func TestPrintLintReport(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	report := &mapper.LintReport{Findings: []mapper.Finding{{
+		Rule:     mapper.LintSystemMasters,
+		Severity: mapper.SeverityWarning,
+		Entry:    "mapRoles[2]",
+		Name:     "role/arn:aws:iam::555555555555:role/admin",
+		Message:  "arn:aws:iam::555555555555:role/admin is granted system:masters",
+	}}}
+
+	var buf bytes.Buffer
+	g.Expect(printLintReport(&buf, mapper.FormatTable, report)).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal(`SEVERITY RULE               ENTRY           MESSAGE
+warning  system-masters     mapRoles[2]     arn:aws:iam::555555555555:role/admin is granted system:masters
+`))
+
+	buf.Reset()
+	g.Expect(printLintReport(&buf, mapper.FormatTable, &mapper.LintReport{})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal("no problems found\n"))
+
+	buf.Reset()
+	g.Expect(printLintReport(&buf, mapper.FormatSARIF, report)).To(gomega.Succeed())
+	var sarif map[string]interface{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &sarif)).To(gomega.Succeed())
+	g.Expect(sarif).To(gomega.HaveKeyWithValue("version", "2.1.0"))
+
+	buf.Reset()
+	g.Expect(printLintReport(&buf, mapper.FormatJSON, report)).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.ContainSubstring(`"rule": "system-masters"`))
+
+	g.Expect(printLintReport(&buf, mapper.FormatYAML, report)).To(gomega.MatchError(mapper.ErrUnsupportedFormat))
+	g.Expect(exitCode(report.Check(mapper.SeverityWarning))).To(gomega.Equal(exitCodeLintFindings))
+}
+
+func TestPrintBackups(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	backups := []*mapper.Backup{{
+		ID:      "20261018-153045-123456789",
+		Created: time.Date(2026, 10, 18, 15, 30, 45, 0, time.UTC),
+		AuthData: mapper.AwsAuthData{
+			MapRoles:    []*mapper.RolesAuthMap{mapper.NewRolesAuthMap("arn:aws:iam::555555555555:role/node", "system:node:{{EC2PrivateDNSName}}", nil)},
+			MapAccounts: []string{"555555555555", "666666666666"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	g.Expect(printBackups(&buf, mapper.FormatTable, backups)).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal(`ID                         CREATED                ROLES  USERS  ACCOUNTS
+20261018-153045-123456789  2026-10-18T15:30:45Z       1      0         2
+`))
+
+	buf.Reset()
+	g.Expect(printBackups(&buf, mapper.FormatJSON, nil)).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal("[]\n"))
+}
+
+func TestRollbackCmd_FlagsBindToRollbackArgs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(rollbackCmd.ParseFlags([]string{"--steps", "3", "--force", "--dry-run", "--as", "alice"})).To(gomega.Succeed())
+	g.Expect(rollbackSteps).To(gomega.Equal(3))
+	g.Expect(rollbackOptions.Force).To(gomega.BeTrue())
+	g.Expect(rollbackArgs.DryRun).To(gomega.Equal(mapper.DryRunClient))
+	g.Expect(rollbackArgs.AsUser).To(gomega.Equal("alice"))
+	g.Expect(rollbackCmd.Flags().Lookup("no-backup")).NotTo(gomega.BeNil())
+
+	// cleanup
+	rollbackSteps = 1
+	rollbackOptions = mapper.RestoreOptions{}
+	rollbackArgs.DryRun = mapper.DryRunNone
+	rollbackArgs.AsUser = ""
+}
+
+func TestPrintHistory(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	history := &mapper.History{
+		Modified: true,
+		Entries: []mapper.HistoryEntry{
+			{
+				Revision:        2,
+				Time:            time.Date(2026, 10, 18, 15, 30, 45, 0, time.UTC),
+				User:            "alice",
+				Operation:       mapper.OperationRemoveByUsername,
+				Hash:            "0123456789abcdef0123",
+				Backup:          "20261018-153045-123456789",
+				ModifiedOutside: true,
+			},
+			{
+				Revision:  1,
+				Time:      time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC),
+				User:      "system:admin",
+				Operation: mapper.OperationUpsert,
+				Hash:      "fedcba9876543210fedc",
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	g.Expect(printHistory(&buf, mapper.FormatTable, history)).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal(`warning: aws-auth was modified outside of the history since the newest change
+REVISION  TIME                  OPERATION            USER                HASH          BACKUP
+2*        2026-10-18T15:30:45Z  remove-by-username   alice               0123456789ab  20261018-153045-123456789
+1         2026-10-18T15:00:00Z  upsert               system:admin        fedcba987654  -
+`))
+
+	buf.Reset()
+	g.Expect(printHistory(&buf, mapper.FormatJSON, &mapper.History{})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal("{\n  \"entries\": [],\n  \"modified\": false\n}\n"))
+
+	g.Expect(printHistory(&buf, "yaml", history)).To(gomega.MatchError(mapper.ErrUnsupportedFormat))
+}
+
+func testPlan() *mapper.Plan {
+	before := &mapper.AwsAuthData{
+		MapRoles:    []*mapper.RolesAuthMap{mapper.NewRolesAuthMap("arn:aws:iam::555555555555:role/admin", "admin", []string{"system:masters", "viewers"})},
+		MapAccounts: []string{"555555555555"},
+	}
+	after := &mapper.AwsAuthData{
+		MapRoles: []*mapper.RolesAuthMap{mapper.NewRolesAuthMap("arn:aws:iam::555555555555:role/admin", "break-glass", []string{"system:masters", "auditors"})},
+		MapUsers: []*mapper.UsersAuthMap{mapper.NewUsersAuthMap("arn:aws:iam::555555555555:user/ci", "ci", []string{"deployers"})},
+	}
+	return mapper.DiffAuthData(before, after)
+}
+
+func TestPrintPlan_Diff(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(printPlan(&buf, mapper.FormatDiff, testPlan())).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal(`--- aws-auth (current)
++++ aws-auth (planned)
+@@ role/arn:aws:iam::555555555555:role/admin @@
+  rolearn: arn:aws:iam::555555555555:role/admin
+- username: admin
++ username: break-glass
+  groups:
+    - system:masters
+-   - viewers
++   - auditors
+@@ account/555555555555 @@
+- "555555555555"
+@@ user/arn:aws:iam::555555555555:user/ci @@
++ userarn: arn:aws:iam::555555555555:user/ci
++ username: ci
++ groups:
++   - deployers
+`))
+
+	buf.Reset()
+	g.Expect(printPlan(&buf, mapper.FormatDiff, &mapper.Plan{})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal("no changes\n"))
+}
+
+func TestPrintPlan_JSON(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var buf bytes.Buffer
+	g.Expect(printPlan(&buf, mapper.FormatJSON, testPlan())).To(gomega.Succeed())
+
+	var plan mapper.Plan
+	g.Expect(json.Unmarshal(buf.Bytes(), &plan)).To(gomega.Succeed())
+	g.Expect(plan.Changes).To(gomega.HaveLen(3))
+	g.Expect(plan.Changes[0].Action).To(gomega.Equal(mapper.ChangeModify))
+	g.Expect(plan.Changes[0].Fields[0]).To(gomega.Equal(mapper.FieldChange{Field: "username", Before: "admin", After: "break-glass"}))
+}
@@ -0,0 +1,278 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	errPartialFailure     = errors.New("the operation failed on some of the clusters")
+	errInvalidConcurrency = errors.New("--concurrency must be at least 1")
+)
+
+// kubeContext is the kubeconfig context of the cluster the commands operate on, the current
+// context when it is empty
+var kubeContext string
+
+// clusterOptions select the clusters a command fans out to, only one command runs at a time
+var clusterOptions = struct {
+	AllContexts bool
+	Contexts    []string
+	Concurrency int
+}{}
+
+// addContextFlags adds the flags which run a command on the clusters of several kubeconfig contexts
+func addContextFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&clusterOptions.AllContexts, "all-contexts", false, "Run the operation on the cluster of every context of the kubeconfig")
+	cmd.Flags().StringSliceVar(&clusterOptions.Contexts, "contexts", []string{}, "Run the operation on the clusters of these kubeconfig contexts, comma separated")
+	cmd.Flags().IntVar(&clusterOptions.Concurrency, "concurrency", 5, "Maximum number of clusters the operation runs on at the same time with --all-contexts or --contexts")
+}
+
+// clusterFunc runs an operation with the mapper of one cluster, its output is written to w. args is
+// a copy of the arguments of the command owned by this cluster.
+type clusterFunc func(ctx context.Context, worker *mapper.AuthMapper, args *mapper.MapperArguments, w io.Writer) error
+
+// clusterResult is the outcome of an operation on the cluster of a kubeconfig context
+type clusterResult struct {
+	Context string
+	Output  bytes.Buffer
+	Err     error
+}
+
+// forEachCluster runs fn on the cluster of args, or on the cluster of every context selected with
+// --all-contexts or --contexts. When it fans out, the output of each cluster is written to w after
+// all of them are done, followed by a summary, and errPartialFailure is returned when only some of
+// the clusters failed. With --format json or yaml the results are written as a single document.
+func forEachCluster(ctx context.Context, args *mapper.MapperArguments, w io.Writer, fn clusterFunc) error {
+	contexts, err := selectedContexts(args.KubeconfigPath)
+	if err != nil {
+		return err
+	}
+	if contexts == nil {
+		worker, err := newClusterWorker(args, kubeContext)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, worker, args, w)
+	}
+
+	results := fanOut(ctx, contexts, clusterOptions.Concurrency, func(ctx context.Context, kubeContext string, w io.Writer) error {
+		clusterArgs := *args
+		worker, err := newClusterWorker(&clusterArgs, kubeContext)
+		if err != nil {
+			return err
+		}
+		// the clusters log at the same time, their messages are told apart by the context
+		worker.Logger = log.New(log.Writer(), "["+kubeContext+"] ", log.Flags())
+		return fn(ctx, worker, &clusterArgs, w)
+	})
+	if args.Format == mapper.FormatJSON || args.Format == mapper.FormatYAML {
+		if err := printClusterReport(w, args.Format, results); err != nil {
+			return err
+		}
+	} else {
+		printClusterResults(w, results)
+	}
+	return clusterResultsError(results)
+}
+
+// selectedContexts returns the contexts selected with --all-contexts or --contexts, nil when the
+// command runs on a single cluster
+func selectedContexts(kubePath string) ([]string, error) {
+	if !clusterOptions.AllContexts && len(clusterOptions.Contexts) == 0 {
+		return nil, nil
+	}
+
+	var flags []string
+	if clusterOptions.AllContexts && len(clusterOptions.Contexts) > 0 {
+		flags = []string{"--all-contexts", "--contexts"}
+	} else if manifestFile != "" {
+		flags = []string{"--file", "--all-contexts or --contexts"}
+	} else if kubeContext != "" {
+		flags = []string{"--context", "--all-contexts or --contexts"}
+	}
+	if flags != nil {
+		return nil, &mapper.MutuallyExclusiveError{Flags: flags}
+	}
+	if clusterOptions.Concurrency < 1 {
+		return nil, fmt.Errorf("%w, got %d", errInvalidConcurrency, clusterOptions.Concurrency)
+	}
+
+	if len(clusterOptions.Contexts) > 0 {
+		return clusterOptions.Contexts, nil
+	}
+
+	contexts, err := kubeContexts(kubePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(contexts) == 0 {
+		return nil, fmt.Errorf("the kubeconfig has no contexts")
+	}
+	return contexts, nil
+}
+
+// kubeContexts returns the names of the contexts of the kubeconfig in sorted order
+func kubeContexts(kubePath string) ([]string, error) {
+	config, err := kubeconfigLoader(kubePath, "").RawConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var contexts []string
+	for name := range config.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
+// fanOut runs fn for every context with at most concurrency of them at the same time, the results
+// are in the order of contexts. Contexts which did not start when ctx is done fail with its error.
+func fanOut(ctx context.Context, contexts []string, concurrency int, fn func(ctx context.Context, kubeContext string, w io.Writer) error) []*clusterResult {
+	results := make([]*clusterResult, len(contexts))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, name := range contexts {
+		result := &clusterResult{Context: name}
+		results[i] = result
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			result.Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result.Err = fn(ctx, result.Context, &result.Output)
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+// printClusterResults writes the output of every cluster under its context and a summary of the results
+func printClusterResults(w io.Writer, results []*clusterResult) {
+	for _, r := range results {
+		if r.Output.Len() == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "==> %v <==\n", r.Context)
+		_, _ = r.Output.WriteTo(w)
+		fmt.Fprintln(w)
+	}
+
+	width := len("CONTEXT")
+	for _, r := range results {
+		width = max(width, len(r.Context))
+	}
+
+	fmt.Fprintf(w, "%-*v %-6v %v\n", width, "CONTEXT", "RESULT", "ERROR")
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "%-*v %-6v %v\n", width, r.Context, "failed", r.Err)
+		} else {
+			fmt.Fprintf(w, "%-*v %v\n", width, r.Context, "ok")
+		}
+	}
+}
+
+// clusterReport is the result of a cluster in the json or yaml output of a fan out. Output holds
+// the document the cluster printed, or its text when it is not a json or yaml document.
+type clusterReport struct {
+	Context string      `json:"context" yaml:"context"`
+	Result  string      `json:"result" yaml:"result"`
+	Error   string      `json:"error,omitempty" yaml:"error,omitempty"`
+	Output  interface{} `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+// printClusterReport writes the results of every cluster as a single json or yaml list
+func printClusterReport(w io.Writer, format string, results []*clusterResult) error {
+	reports := make([]clusterReport, 0, len(results))
+	for _, r := range results {
+		report := clusterReport{Context: r.Context, Result: "ok"}
+		if r.Err != nil {
+			report.Result, report.Error = "failed", r.Err.Error()
+		}
+		if output := bytes.TrimSpace(r.Output.Bytes()); len(output) > 0 {
+			report.Output = string(output)
+			var doc yaml.Node
+			switch {
+			case format == mapper.FormatJSON && json.Valid(output):
+				report.Output = json.RawMessage(output)
+			case format == mapper.FormatYAML && yaml.Unmarshal(output, &doc) == nil && len(doc.Content) > 0 && doc.Content[0].Kind != yaml.ScalarNode:
+				report.Output = doc.Content[0]
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	if format == mapper.FormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(reports); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// clusterResultsError returns nil when every cluster succeeded and errPartialFailure when only some
+// of them failed. When all of them failed, the error of the first cluster is returned so that its
+// exit code is kept.
+func clusterResultsError(results []*clusterResult) error {
+	var failed []string
+	var first error
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+		if first == nil {
+			first = r.Err
+		}
+		failed = append(failed, r.Context)
+	}
+
+	switch len(failed) {
+	case 0:
+		return nil
+	case len(results):
+		return fmt.Errorf("failed on all %d clusters: %w", len(results), first)
+	}
+	return fmt.Errorf("%w: %d of %d failed: %v", errPartialFailure, len(failed), len(results), failed)
+}
@@ -0,0 +1,105 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+var exportArgs = &mapper.MapperArguments{}
+
+var exportOptions = mapper.ExportOptions{}
+
+var (
+	importArgs     = &mapper.MapperArguments{}
+	importOptions  = mapper.ApplyOptions{}
+	importFilename string
+	importFormat   string
+)
+
+// exportCmd renders the mappings for eksctl, terraform or cloudformation
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export renders the mappings of the aws-auth configmap for eksctl, terraform or cloudformation",
+	Long: `export renders the mappings of the aws-auth configmap as an eksctl ClusterConfig with iamIdentityMappings, as the aws_auth_roles, aws_auth_users and aws_auth_accounts variables of the terraform-aws-modules/eks aws-auth module, as a CloudFormation template of EKS access entries or as a ConfigMap manifest.
+The filter flags limit the export to the selected mappings.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		worker := newWorker(exportArgs)
+
+		ctx, cancel := commandContext(exportArgs.Timeout)
+		defer cancel()
+
+		exportOptions.Filter = exportArgs.Filter()
+		exitOnError(worker.ExportWithContext(ctx, os.Stdout, exportOptions))
+	},
+}
+
+// importCmd applies mappings exported by eksctl, terraform or cloudformation
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import applies the mappings of an eksctl, terraform, cloudformation or yaml file to the aws-auth configmap",
+	Long: `import reads mappings in one of the formats of export and applies them like apply does, --dry-run prints the plan without making the changes.
+With --prune, mappings which were applied or imported before and are no longer in the file are deleted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := readInput(importFilename)
+		exitOnError(err)
+		desired, err := mapper.ImportAuthData(data, importFormat)
+		if err != nil {
+			exitOnError(fmt.Errorf("failed to parse %v: %w", importFilename, err))
+		}
+
+		worker := newWorker(importArgs)
+		worker.Backups = backupOptions
+		worker.Policies = mustReadPolicies()
+		worker.OverrideProtection = overrideProtection
+		worker.Actor = importArgs.AsUser
+
+		ctx, cancel := commandContext(importArgs.Timeout)
+		defer cancel()
+
+		result, err := worker.ApplyWithContext(ctx, &desired, importOptions)
+		exitOnError(err)
+
+		printApplyResult(os.Stdout, result, importOptions.DryRun != mapper.DryRunNone)
+	},
+}
+
+func init() {
+	formats := strings.Join(mapper.ExportFormats, ", ")
+
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&exportOptions.Format, "format", mapper.FormatYAML, "The format of the export, one of: "+formats)
+	exportCmd.Flags().StringVar(&exportOptions.ClusterName, "cluster-name", "", "Name of the cluster in the eksctl ClusterConfig and default of the ClusterName parameter of the CloudFormation template")
+	addFilterFlags(exportCmd, exportArgs)
+	addClientFlags(exportCmd, exportArgs)
+
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVarP(&importFilename, "filename", "f", "", "File with the mappings to import, - reads from stdin")
+	importCmd.Flags().StringVar(&importFormat, "format", mapper.FormatYAML, "The format of the file, one of: "+formats)
+	importCmd.Flags().BoolVar(&importOptions.Prune, "prune", false, "Delete mappings which were applied or imported before and are no longer in the file")
+	importCmd.Flags().StringVar((*string)(&importOptions.DryRun), "dry-run", "", "Only print the changes, 'client' computes them locally, 'server' also submits them to the API server without persisting")
+	importCmd.Flags().Lookup("dry-run").NoOptDefVal = string(mapper.DryRunClient)
+	addBackupFlags(importCmd)
+	addPolicyFlags(importCmd)
+	addProtectionFlags(importCmd)
+	addClientFlags(importCmd, importArgs)
+	_ = importCmd.MarkFlagRequired("filename")
+}
@@ -16,7 +16,10 @@ limitations under the License.
 package cli
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -25,6 +28,7 @@ import (
 	"github.com/olekukonko/tablewriter/renderer"
 	"github.com/olekukonko/tablewriter/tw"
 	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
 )
 
 var getArgs = &mapper.MapperArguments{
@@ -38,75 +42,125 @@ var getCmd = &cobra.Command{
 	Short: "get provides a detailed summary of the configmap",
 	Long:  `get allows a user to output the aws-auth configmap entires in various formats`,
 	Run: func(cmd *cobra.Command, args []string) {
-		options := kubeOptions{
-			AsUser:   getArgs.AsUser,
-			AsGroups: getArgs.AsGroups,
-		}
-
-		k, err := getKubernetesClient(getArgs.KubeconfigPath, options)
-		if err != nil {
-			log.Fatal(err)
-		}
+		ctx, cancel := commandContext(getArgs.Timeout)
+		defer cancel()
 
-		worker := mapper.New(k, true)
+		exitOnError(forEachCluster(ctx, getArgs, os.Stdout, func(ctx context.Context, worker *mapper.AuthMapper, args *mapper.MapperArguments, w io.Writer) error {
+			d, err := worker.GetWithContext(ctx, args)
+			if err != nil {
+				return err
+			}
+			return printMappings(w, args.Format, d)
+		}))
+	},
+}
 
-		d, err := worker.Get(getArgs)
-		if err != nil {
-			log.Fatal(err)
+// printMappings writes the mappings to w in the given format
+func printMappings(w io.Writer, format string, d mapper.AwsAuthData) error {
+	list := d.Mappings()
+
+	switch format {
+	case mapper.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	case mapper.FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(list); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case mapper.FormatName:
+		for _, m := range list.Items {
+			if _, err := fmt.Fprintln(w, m.Name()); err != nil {
+				return err
+			}
 		}
+		return nil
+	case mapper.FormatWide:
+		return printTable(w, list, true)
+	default:
+		return printTable(w, list, false)
+	}
+}
+
+var mappingTypes = map[mapper.MappingKind]string{
+	mapper.RoleMappingKind:    "Role Mapping",
+	mapper.UserMappingKind:    "User Mapping",
+	mapper.AccountMappingKind: "Account Mapping",
+}
 
-		table := tablewriter.NewTable(os.Stdout,
-			tablewriter.WithRenderer(renderer.NewBlueprint(tw.Rendition{
-				Borders: tw.Border{
-					Left:   tw.Off,
-					Right:  tw.Off,
-					Top:    tw.Off,
-					Bottom: tw.Off,
+// printTable renders the mappings as a table, the wide table adds the account column
+func printTable(w io.Writer, list *mapper.MappingList, wide bool) error {
+	table := tablewriter.NewTable(w,
+		tablewriter.WithRenderer(renderer.NewBlueprint(tw.Rendition{
+			Borders: tw.Border{
+				Left:   tw.Off,
+				Right:  tw.Off,
+				Top:    tw.Off,
+				Bottom: tw.Off,
+			},
+			Settings: tw.Settings{
+				Lines: tw.Lines{
+					ShowTop:        tw.Off,
+					ShowBottom:     tw.Off,
+					ShowHeaderLine: tw.Off,
 				},
-				Settings: tw.Settings{
-					Lines: tw.Lines{
-						ShowTop:        tw.Off,
-						ShowBottom:     tw.Off,
-						ShowHeaderLine: tw.Off,
-					},
-					Separators: tw.Separators{
-						BetweenColumns: tw.Off,
-						BetweenRows:    tw.Off,
-					},
+				Separators: tw.Separators{
+					BetweenColumns: tw.Off,
+					BetweenRows:    tw.Off,
 				},
-			})),
-		)
-		table.Configure(func(cfg *tablewriter.Config) {
-			cfg.Header.Alignment.Global = tw.AlignLeft
-			cfg.Header.Formatting.AutoFormat = tw.Off
-			cfg.Row.Alignment.Global = tw.AlignLeft
-			cfg.Header.Padding.Global = tw.Padding{Left: "\t", Right: "", Overwrite: true}
-			cfg.Row.Padding.Global = tw.Padding{Left: "\t", Right: "", Overwrite: true}
-		})
+			},
+		})),
+	)
+	table.Configure(func(cfg *tablewriter.Config) {
+		cfg.Header.Alignment.Global = tw.AlignLeft
+		cfg.Header.Formatting.AutoFormat = tw.Off
+		cfg.Row.Alignment.Global = tw.AlignLeft
+		cfg.Header.Padding.Global = tw.Padding{Left: "\t", Right: "", Overwrite: true}
+		cfg.Row.Padding.Global = tw.Padding{Left: "\t", Right: "", Overwrite: true}
+	})
+
+	if wide {
+		table.Header([]string{"Type", "ARN", "Account", "Username", "Groups"})
+	} else {
 		table.Header([]string{"Type", "ARN", "Username", "Groups"})
+	}
 
-		for _, row := range d.MapRoles {
-			if err := table.Append([]string{"Role Mapping", row.RoleARN, row.Username, strings.Join(row.Groups, ", ")}); err != nil {
-				log.Fatal(err)
-			}
+	for _, m := range list.Items {
+		// account mappings show the account in the ARN column
+		arn := m.ARN
+		if m.Kind == mapper.AccountMappingKind {
+			arn = m.Account
 		}
 
-		for _, row := range d.MapUsers {
-			if err := table.Append([]string{"User Mapping", row.UserARN, row.Username, strings.Join(row.Groups, ", ")}); err != nil {
-				log.Fatal(err)
-			}
+		row := []string{mappingTypes[m.Kind], arn, m.Username, strings.Join(m.Groups, ", ")}
+		if wide {
+			row = []string{mappingTypes[m.Kind], arn, m.Account, m.Username, strings.Join(m.Groups, ", ")}
 		}
-
-		if err := table.Render(); err != nil {
-			log.Fatal(err)
+		if err := table.Append(row); err != nil {
+			return err
 		}
-	},
+	}
+
+	return table.Render()
 }
 
 func init() {
 	rootCmd.AddCommand(getCmd)
 	getCmd.Flags().StringVar(&getArgs.KubeconfigPath, "kubeconfig", "", "Path to kubeconfig")
-	getCmd.Flags().StringVar(&getArgs.Format, "format", "table", "The format in which to display results (currently only 'table' supported)")
+	getCmd.Flags().BoolVar(&getArgs.MapRoles, "maproles", false, "Only show role mappings")
+	getCmd.Flags().BoolVar(&getArgs.MapUsers, "mapusers", false, "Only show user mappings")
+	getCmd.Flags().BoolVar(&getArgs.MapAccounts, "mapaccounts", false, "Only show account mappings")
+	getCmd.Flags().StringVar(&getArgs.RoleARN, "rolearn", "", "Only show role mappings matching the ARN, '*' and '?' can be used as wildcards")
+	getCmd.Flags().StringVar(&getArgs.UserARN, "userarn", "", "Only show user mappings matching the ARN, '*' and '?' can be used as wildcards")
+	getCmd.Flags().StringVar(&getArgs.Username, "username", "", "Only show mappings matching the username, '*' and '?' can be used as wildcards")
+	getCmd.Flags().StringSliceVar(&getArgs.Groups, "group", []string{}, "Only show mappings which have the group, this flag can be repeated to require multiple groups")
+	getCmd.Flags().StringVar(&getArgs.AccountID, "account", "", "Only show mappings of the account ID")
+	getCmd.Flags().StringVar(&getArgs.Format, "format", "table", "The format in which to display results, one of: table, wide, json, yaml, name")
+	getCmd.Flags().DurationVar(&getArgs.Timeout, "timeout", 0, "Maximum duration of the operation, zero means no timeout")
+	addContextFlags(getCmd)
 	getCmd.Flags().StringVar(&getArgs.AsUser, "as", "", "Username to impersonate for the operation")
 	getCmd.Flags().StringSliceVar(&getArgs.AsGroups, "as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
 }
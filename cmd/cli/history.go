@@ -0,0 +1,127 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+var historyArgs = &mapper.MapperArguments{}
+
+var rollbackArgs = &mapper.MapperArguments{}
+
+var rollbackOptions = mapper.RestoreOptions{}
+
+var rollbackSteps int
+
+// historyCmd lists the change history
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "history lists the recent changes of the aws-auth configmap, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		worker := newWorker(historyArgs)
+
+		ctx, cancel := commandContext(historyArgs.Timeout)
+		defer cancel()
+
+		history, err := worker.HistoryWithContext(ctx)
+		exitOnError(err)
+		exitOnError(printHistory(os.Stdout, historyArgs.Format, history))
+	},
+}
+
+// rollbackCmd reverts changes of the change history
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "rollback reverts the last changes of the aws-auth configmap",
+	Long: `rollback reverts the last --steps changes recorded in the history of the aws-auth configmap, by
+restoring the backup taken before the oldest of them. The rollback is recorded in the history too, so it can
+be rolled back itself. If the configmap was modified outside of the history since the newest change, the
+rollback is refused unless --force is set.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		worker := newWorker(rollbackArgs)
+		worker.Backups = backupOptions
+		worker.Policies = mustReadPolicies()
+		worker.Actor = rollbackArgs.AsUser
+
+		ctx, cancel := commandContext(rollbackArgs.Timeout)
+		defer cancel()
+
+		rollbackOptions.DryRun = rollbackArgs.DryRun
+		plan, err := worker.RollbackWithContext(ctx, rollbackSteps, rollbackOptions)
+		exitOnError(err)
+
+		if rollbackArgs.DryRun != mapper.DryRunNone {
+			exitOnError(printPlan(os.Stdout, rollbackArgs.Format, plan))
+		}
+	},
+}
+
+// printHistory writes the change history to w as a table or as json
+func printHistory(w io.Writer, format string, history *mapper.History) error {
+	switch format {
+	case mapper.FormatJSON:
+		if history.Entries == nil {
+			history.Entries = []mapper.HistoryEntry{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(history)
+	case mapper.FormatTable:
+		if history.Modified {
+			fmt.Fprintln(w, "warning: aws-auth was modified outside of the history since the newest change")
+		}
+		fmt.Fprintf(w, "%-9v %-21v %-20v %-19v %-13v %v\n", "REVISION", "TIME", "OPERATION", "USER", "HASH", "BACKUP")
+		for _, e := range history.Entries {
+			revision := fmt.Sprint(e.Revision)
+			if e.ModifiedOutside {
+				revision += "*"
+			}
+			backup := e.Backup
+			if backup == "" {
+				backup = "-"
+			}
+			fmt.Fprintf(w, "%-9v %-21v %-20v %-19v %-13v %v\n", revision, e.Time.UTC().Format(time.RFC3339),
+				e.Operation, e.User, e.Hash[:min(len(e.Hash), 12)], backup)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %q, supported values are %v, %v", mapper.ErrUnsupportedFormat, format, mapper.FormatTable, mapper.FormatJSON)
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(rollbackCmd)
+
+	historyCmd.Flags().StringVar(&historyArgs.Format, "format", mapper.FormatTable, "Output format, one of table or json")
+	addClientFlags(historyCmd, historyArgs)
+
+	rollbackCmd.Flags().IntVar(&rollbackSteps, "steps", 1, "Number of changes to revert")
+	rollbackCmd.Flags().BoolVar(&rollbackOptions.Force, "force", false, "Roll back even if the configmap was modified outside of the history, or the result has no role mappings")
+	addDryRunFlags(rollbackCmd, rollbackArgs)
+	addBackupFlags(rollbackCmd)
+	addPolicyFlags(rollbackCmd)
+	addClientFlags(rollbackCmd, rollbackArgs)
+}
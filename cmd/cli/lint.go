@@ -0,0 +1,86 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+var lintArgs = &mapper.MapperArguments{}
+
+var lintThreshold string
+
+// lintCmd reports problems with the aws-auth configmap
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "lint reports problems with the mappings of the aws-auth configmap",
+	Long: `lint reads the aws-auth configmap and reports duplicate ARNs, missing usernames and groups, malformed ARNs
+and accounts, unsupported username placeholders, usernames shared by different ARNs, system:masters grants and
+documents which are not valid YAML. It exits with a non-zero code when a finding reaches --severity-threshold.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		threshold, err := mapper.ParseSeverity(lintThreshold)
+		exitOnError(err)
+
+		worker := newWorker(lintArgs)
+
+		ctx, cancel := commandContext(lintArgs.Timeout)
+		defer cancel()
+
+		report, err := worker.LintWithContext(ctx)
+		exitOnError(err)
+		exitOnError(printLintReport(os.Stdout, lintArgs.Format, report))
+		exitOnError(report.Check(threshold))
+	},
+}
+
+// printLintReport writes the findings to w as a table, as json or as a SARIF log
+func printLintReport(w io.Writer, format string, report *mapper.LintReport) error {
+	switch format {
+	case mapper.FormatJSON, mapper.FormatSARIF:
+		var out interface{} = report
+		if format == mapper.FormatSARIF {
+			out = report.SARIF()
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(out)
+	case mapper.FormatTable:
+		if len(report.Findings) == 0 {
+			fmt.Fprintln(w, "no problems found")
+			return nil
+		}
+		fmt.Fprintf(w, "%-8v %-18v %-15v %v\n", "SEVERITY", "RULE", "ENTRY", "MESSAGE")
+		for _, f := range report.Findings {
+			fmt.Fprintf(w, "%-8v %-18v %-15v %v\n", f.Severity, f.Rule, f.Entry, f.Message)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %q, supported values are %v, %v, %v", mapper.ErrUnsupportedFormat, format, mapper.FormatTable, mapper.FormatJSON, mapper.FormatSARIF)
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	lintCmd.Flags().StringVar(&lintArgs.Format, "format", mapper.FormatTable, "Output format, one of table, json or sarif")
+	lintCmd.Flags().StringVar(&lintThreshold, "severity-threshold", string(mapper.SeverityError), "Exit with a non-zero code when a finding has this severity or above, one of info, warning, error or none")
+	addClientFlags(lintCmd, lintArgs)
+}
@@ -0,0 +1,72 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+)
+
+var normalizeArgs = &mapper.MapperArguments{
+	OperationType: mapper.OperationNormalize,
+	IsGlobal:      true,
+}
+
+// normalizeCmd rewrites role ARNs into the form the authenticator matches
+var normalizeCmd = &cobra.Command{
+	Use:   "normalize",
+	Short: "normalize removes the IAM path from role ARNs in the aws-auth configmap",
+	Long: `normalize removes the IAM path from role ARNs in the aws-auth configmap, the authenticator ignores
+the path of roles so arn:aws:iam::123456789012:role/team/MyRole is matched as arn:aws:iam::123456789012:role/MyRole.
+Path variants of the same role which are mapped identically are merged, path variants which are mapped
+to different usernames or groups are reported and nothing is changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		worker := newWorker(normalizeArgs)
+		worker.Backups = backupOptions
+		worker.Policies = mustReadPolicies()
+		worker.Actor = normalizeArgs.AsUser
+		ctx, cancel := commandContext(normalizeArgs.Timeout)
+		defer cancel()
+
+		if normalizeArgs.DryRun != mapper.DryRunNone {
+			plan, err := worker.PlanWithContext(ctx, normalizeArgs)
+			exitOnError(err)
+			exitOnError(printPlan(os.Stdout, normalizeArgs.Format, plan))
+			return
+		}
+
+		exitOnError(worker.NormalizeWithContext(ctx, normalizeArgs))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(normalizeCmd)
+	normalizeCmd.Flags().StringVar(&normalizeArgs.KubeconfigPath, "kubeconfig", "", "Kubeconfig path")
+	normalizeCmd.Flags().BoolVar(&normalizeArgs.WithRetries, "retry", false, "Retry on failure with exponential backoff")
+	normalizeCmd.Flags().DurationVar(&normalizeArgs.MinRetryTime, "retry-min-time", time.Millisecond*200, "Minimum wait interval")
+	normalizeCmd.Flags().DurationVar(&normalizeArgs.MaxRetryTime, "retry-max-time", time.Second*30, "Maximum wait interval")
+	normalizeCmd.Flags().IntVar(&normalizeArgs.MaxRetryCount, "retry-max-count", 12, "Maximum number of retries before giving up")
+	normalizeCmd.Flags().DurationVar(&normalizeArgs.Timeout, "timeout", 0, "Maximum duration of the operation including retries, zero means no timeout")
+	addDryRunFlags(normalizeCmd, normalizeArgs)
+	addBackupFlags(normalizeCmd)
+	addPolicyFlags(normalizeCmd)
+	normalizeCmd.Flags().StringVar(&normalizeArgs.AsUser, "as", "", "Username to impersonate for the operation")
+	normalizeCmd.Flags().StringSliceVar(&normalizeArgs.AsGroups, "as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
+}
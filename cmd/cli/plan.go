@@ -0,0 +1,159 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+const (
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+	colorReset = "\033[0m"
+)
+
+// addDryRunFlags adds the --dry-run and --format flags of a mutating command
+func addDryRunFlags(cmd *cobra.Command, args *mapper.MapperArguments) {
+	cmd.Flags().StringVar((*string)(&args.DryRun), "dry-run", "", "Only print the changes, 'client' computes them locally, 'server' also submits them to the API server without persisting")
+	cmd.Flags().Lookup("dry-run").NoOptDefVal = string(mapper.DryRunClient)
+	cmd.Flags().StringVar(&args.Format, "format", mapper.FormatDiff, "The format in which to print the changes of a dry run, one of: diff, json")
+}
+
+// validatePlanFormat checks that format is one of the formats printPlan supports
+func validatePlanFormat(format string) error {
+	if !slices.Contains(mapper.SupportedPlanFormats, format) {
+		return fmt.Errorf("%w: %q, supported values are %v", mapper.ErrUnsupportedFormat, format, strings.Join(mapper.SupportedPlanFormats, ", "))
+	}
+	return nil
+}
+
+// printPlan writes the changes of a plan to w as a unified diff or as json
+func printPlan(w io.Writer, format string, plan *mapper.Plan) error {
+	if format == mapper.FormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	}
+
+	if plan.IsEmpty() {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+
+	color := useColor(w)
+	line := func(prefix, text string) {
+		switch {
+		case color && prefix == "-":
+			fmt.Fprintf(w, "%v- %v%v\n", colorRed, text, colorReset)
+		case color && prefix == "+":
+			fmt.Fprintf(w, "%v+ %v%v\n", colorGreen, text, colorReset)
+		case color && prefix == "@@":
+			fmt.Fprintf(w, "%v@@ %v @@%v\n", colorCyan, text, colorReset)
+		case prefix == "@@":
+			fmt.Fprintf(w, "@@ %v @@\n", text)
+		default:
+			fmt.Fprintf(w, "%v %v\n", prefix, text)
+		}
+	}
+
+	fmt.Fprintln(w, "--- aws-auth (current)")
+	fmt.Fprintln(w, "+++ aws-auth (planned)")
+	for _, c := range plan.Changes {
+		line("@@", c.Name)
+		switch c.Action {
+		case mapper.ChangeAdd:
+			for _, l := range mappingLines(c.After) {
+				line("+", l)
+			}
+		case mapper.ChangeRemove:
+			for _, l := range mappingLines(c.Before) {
+				line("-", l)
+			}
+		case mapper.ChangeModify:
+			line(" ", mappingLines(c.Before)[0])
+			if c.Before.Username != c.After.Username {
+				line("-", "username: "+c.Before.Username)
+				line("+", "username: "+c.After.Username)
+			} else {
+				line(" ", "username: "+c.After.Username)
+			}
+			if len(c.Before.Groups) > 0 || len(c.After.Groups) > 0 {
+				line(" ", "groups:")
+			}
+			for _, g := range c.Before.Groups {
+				if slices.Contains(c.After.Groups, g) {
+					line(" ", "  - "+g)
+				} else {
+					line("-", "  - "+g)
+				}
+			}
+			for _, g := range c.After.Groups {
+				if !slices.Contains(c.Before.Groups, g) {
+					line("+", "  - "+g)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mappingLines renders a mapping the way it appears in the aws-auth configmap
+func mappingLines(m *mapper.Mapping) []string {
+	switch m.Kind {
+	case mapper.RoleMappingKind:
+		return append([]string{"rolearn: " + m.ARN, "username: " + m.Username}, groupLines(m.Groups)...)
+	case mapper.UserMappingKind:
+		return append([]string{"userarn: " + m.ARN, "username: " + m.Username}, groupLines(m.Groups)...)
+	default:
+		return []string{fmt.Sprintf("%q", m.Account)}
+	}
+}
+
+func groupLines(groups []string) []string {
+	if len(groups) == 0 {
+		return nil
+	}
+	lines := []string{"groups:"}
+	for _, g := range groups {
+		lines = append(lines, "  - "+g)
+	}
+	return lines
+}
+
+// useColor returns true if w is a terminal and colors were not disabled with NO_COLOR
+func useColor(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,49 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+// policyFilename is shared by the mutating commands, only one command runs at a time
+var policyFilename string
+
+// addPolicyFlags adds the flag which selects the rules file a mutating command enforces
+func addPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&policyFilename, "policy", "", "Rules file the mappings are validated against before they are written")
+}
+
+// readPolicies loads the rules file, no policies are returned when filename is empty
+func readPolicies(filename string) ([]mapper.Policy, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	policy, err := mapper.LoadPolicyFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return []mapper.Policy{policy}, nil
+}
+
+// mustReadPolicies is like readPolicies for the --policy flag, it exits when the rules file cannot be loaded
+func mustReadPolicies() []mapper.Policy {
+	policies, err := readPolicies(policyFilename)
+	exitOnError(err)
+	return policies
+}
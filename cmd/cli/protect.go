@@ -0,0 +1,29 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+// overrideProtection is shared by the mutating commands, only one command runs at a time
+var overrideProtection bool
+
+// addProtectionFlags adds the flag which allows a mutating command to change protected mappings
+func addProtectionFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&overrideProtection, "override-protection", false, "Allow removing or modifying the mappings listed in the "+mapper.ProtectedAnnotation+" annotation")
+}
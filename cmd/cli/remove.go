@@ -16,7 +16,9 @@ limitations under the License.
 package cli
 
 import (
-	"log"
+	"context"
+	"io"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -31,48 +33,58 @@ var removeArgs = &mapper.MapperArguments{
 // deleteCmd represents the base command when called without any subcommands
 var removeCmd = &cobra.Command{
 	Use:   "remove",
-	Short: "remove removes a user or role from the aws-auth configmap",
-	Long:  `remove removes a user or role from the aws-auth configmap`,
+	Short: "remove removes a user, role or account from the aws-auth configmap",
+	Long:  `remove removes a user, role or account from the aws-auth configmap`,
 	Run: func(cmd *cobra.Command, args []string) {
-		options := kubeOptions{
-			AsUser:   removeArgs.AsUser,
-			AsGroups: removeArgs.AsGroups,
-		}
-
-		k, err := getKubernetesClient(removeArgs.KubeconfigPath, options)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		worker := mapper.New(k, true)
-		if err := worker.Remove(removeArgs); err != nil {
-			log.Fatal(err)
-		}
+		policies := mustReadPolicies()
+		ctx, cancel := commandContext(removeArgs.Timeout)
+		defer cancel()
+
+		exitOnError(forEachCluster(ctx, removeArgs, os.Stdout, func(ctx context.Context, worker *mapper.AuthMapper, args *mapper.MapperArguments, w io.Writer) error {
+			worker.Backups = backupOptions
+			worker.Policies = policies
+			worker.OverrideProtection = overrideProtection
+			worker.Actor = args.AsUser
+
+			if args.DryRun != mapper.DryRunNone {
+				plan, err := worker.PlanWithContext(ctx, args)
+				if err != nil {
+					return err
+				}
+				return printPlan(w, args.Format, plan)
+			}
+
+			return worker.RemoveWithContext(ctx, args)
+		}))
 	},
 }
 
 // removeByUsernameCmd removes all map roles and map users in an auth cm based on the input username
 func removeByUsernameCmd() *cobra.Command {
-	var removeArgs = &mapper.MapperArguments{}
+	var removeArgs = &mapper.MapperArguments{
+		OperationType: mapper.OperationRemoveByUsername,
+	}
 	var command = &cobra.Command{
 		Use:   "remove-by-username",
 		Short: "remove-by-username removes all map roles and map users from the aws-auth configmap",
 		Run: func(cmd *cobra.Command, args []string) {
-			options := kubeOptions{
-				AsUser:   removeArgs.AsUser,
-				AsGroups: removeArgs.AsGroups,
+			worker := newWorker(removeArgs)
+			worker.Backups = backupOptions
+			worker.Policies = mustReadPolicies()
+			worker.OverrideProtection = overrideProtection
+			worker.Actor = removeArgs.AsUser
+
+			ctx, cancel := commandContext(removeArgs.Timeout)
+			defer cancel()
+
+			if removeArgs.DryRun != mapper.DryRunNone {
+				plan, err := worker.PlanWithContext(ctx, removeArgs)
+				exitOnError(err)
+				exitOnError(printPlan(os.Stdout, removeArgs.Format, plan))
+				return
 			}
 
-			k, err := getKubernetesClient(removeArgs.KubeconfigPath, options)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			worker := mapper.New(k, true)
-
-			if err := worker.RemoveByUsername(removeArgs); err != nil {
-				log.Fatal(err)
-			}
+			exitOnError(worker.RemoveByUsernameWithContext(ctx, removeArgs))
 		},
 	}
 
@@ -82,6 +94,11 @@ func removeByUsernameCmd() *cobra.Command {
 	command.Flags().DurationVar(&removeArgs.MinRetryTime, "retry-min-time", time.Millisecond*200, "Minimum wait interval")
 	command.Flags().DurationVar(&removeArgs.MaxRetryTime, "retry-max-time", time.Second*30, "Maximum wait interval")
 	command.Flags().IntVar(&removeArgs.MaxRetryCount, "retry-max-count", 12, "Maximum number of retries before giving up")
+	command.Flags().DurationVar(&removeArgs.Timeout, "timeout", 0, "Maximum duration of the operation including retries, zero means no timeout")
+	addDryRunFlags(command, removeArgs)
+	addBackupFlags(command)
+	addPolicyFlags(command)
+	addProtectionFlags(command)
 	command.Flags().StringVar(&removeArgs.AsUser, "as", "", "Username to impersonate for the operation")
 	command.Flags().StringSliceVar(&removeArgs.AsGroups, "as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
 	return command
@@ -98,10 +115,19 @@ func init() {
 	removeCmd.Flags().BoolVar(&removeArgs.Force, "force", false, "Ignores not found errors")
 	removeCmd.Flags().BoolVar(&removeArgs.MapRoles, "maproles", false, "Removes a role")
 	removeCmd.Flags().BoolVar(&removeArgs.MapUsers, "mapusers", false, "Removes a user")
+	removeCmd.Flags().BoolVar(&removeArgs.MapAccounts, "mapaccounts", false, "Removes an account")
+	removeCmd.Flags().StringVar(&removeArgs.AccountID, "account", "", "Account ID to remove")
 	removeCmd.Flags().BoolVar(&removeArgs.WithRetries, "retry", false, "Retry on failure with exponential backoff")
 	removeCmd.Flags().DurationVar(&removeArgs.MinRetryTime, "retry-min-time", time.Millisecond*200, "Minimum wait interval")
 	removeCmd.Flags().DurationVar(&removeArgs.MaxRetryTime, "retry-max-time", time.Second*30, "Maximum wait interval")
 	removeCmd.Flags().IntVar(&removeArgs.MaxRetryCount, "retry-max-count", 12, "Maximum number of retries before giving up")
+	removeCmd.Flags().DurationVar(&removeArgs.Timeout, "timeout", 0, "Maximum duration of the operation including retries, zero means no timeout")
+	removeCmd.Flags().BoolVar(&removeArgs.IgnorePath, "ignore-path", false, "Remove all path variants of the role, as the authenticator does not distinguish them")
+	addDryRunFlags(removeCmd, removeArgs)
+	addBackupFlags(removeCmd)
+	addPolicyFlags(removeCmd)
+	addProtectionFlags(removeCmd)
+	addContextFlags(removeCmd)
 	removeCmd.Flags().StringVar(&removeArgs.AsUser, "as", "", "Username to impersonate for the operation")
 	removeCmd.Flags().StringSliceVar(&removeArgs.AsGroups, "as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
 }
@@ -0,0 +1,83 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+var renderArgs = &mapper.MapperArguments{}
+
+var renderCaller = &mapper.RenderArguments{}
+
+// renderCmd previews the username and groups a caller is mapped to
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "render shows the username and groups a caller ARN is mapped to",
+	Long: `render finds the mapping of a caller ARN in the aws-auth configmap and expands the placeholders of its
+username and groups the way the authenticator does. {{AccessKeyID}} and {{EC2PrivateDNSName}} are only known
+at login, they are left in place unless --access-key-id or --ec2-private-dns-name are given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		worker := newWorker(renderArgs)
+
+		ctx, cancel := commandContext(renderArgs.Timeout)
+		defer cancel()
+
+		identity, err := worker.RenderWithContext(ctx, renderCaller)
+		exitOnError(err)
+		exitOnError(printIdentity(os.Stdout, renderArgs.Format, identity))
+	},
+}
+
+// printIdentity writes the rendered identity to w as text or json
+func printIdentity(w io.Writer, format string, identity *mapper.Identity) error {
+	switch format {
+	case mapper.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(identity)
+	case mapper.FormatTable:
+		fmt.Fprintf(w, "mapping:  %v\n", identity.Mapping.Name())
+		fmt.Fprintf(w, "username: %v\n", identity.Username)
+		fmt.Fprintf(w, "groups:   %v\n", strings.Join(identity.Groups, ", "))
+		if len(identity.Unresolved) != 0 {
+			fmt.Fprintf(w, "unresolved placeholders: {{%v}}\n", strings.Join(identity.Unresolved, "}}, {{"))
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %q, supported values are %v, %v", mapper.ErrUnsupportedFormat, format, mapper.FormatTable, mapper.FormatJSON)
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	renderCmd.Flags().StringVar(&renderCaller.CallerARN, "arn", "", "ARN of the caller, an IAM role or user ARN or an STS assumed-role ARN")
+	renderCmd.Flags().StringVar(&renderCaller.SessionName, "session-name", "", "Session name of the caller, defaults to the session name of an assumed-role ARN")
+	renderCmd.Flags().StringVar(&renderCaller.AccessKeyID, "access-key-id", "", "Access key ID of the caller to render {{AccessKeyID}}")
+	renderCmd.Flags().StringVar(&renderCaller.EC2PrivateDNSName, "ec2-private-dns-name", "", "Private DNS name of the instance to render {{EC2PrivateDNSName}}")
+	renderCmd.Flags().StringVar(&renderArgs.Format, "format", mapper.FormatTable, "Output format, one of table or json")
+	renderCmd.Flags().StringVar(&renderArgs.KubeconfigPath, "kubeconfig", "", "Kubeconfig path")
+	renderCmd.Flags().DurationVar(&renderArgs.Timeout, "timeout", 0, "Maximum duration of the operation, zero means no timeout")
+	renderCmd.Flags().StringVar(&renderArgs.AsUser, "as", "", "Username to impersonate for the operation")
+	renderCmd.Flags().StringSliceVar(&renderArgs.AsGroups, "as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
+	_ = renderCmd.MarkFlagRequired("arn")
+}
@@ -0,0 +1,76 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+var resolveArgs = &mapper.MapperArguments{}
+
+var resolveCaller = &mapper.RenderArguments{}
+
+// resolveCmd shows which Kubernetes identity an IAM principal is mapped to
+var resolveCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "resolve shows the Kubernetes user and groups an IAM principal is mapped to",
+	Long: `resolve matches an IAM principal against the aws-auth configmap the way the authenticator does:
+first mapUsers, then mapRoles with assumed-role sessions converted to their role and role paths ignored,
+and last mapAccounts. It prints the matched mapping with the rendered username and groups, or why nothing matched.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		worker := newWorker(resolveArgs)
+
+		ctx, cancel := commandContext(resolveArgs.Timeout)
+		defer cancel()
+
+		identity, err := worker.ResolveWithContext(ctx, resolveCaller)
+		var noMatch *mapper.NoMatchError
+		if errors.As(err, &noMatch) {
+			printNoMatch(os.Stderr, noMatch)
+			os.Exit(exitCode(err))
+		}
+		exitOnError(err)
+		exitOnError(printIdentity(os.Stdout, resolveArgs.Format, identity))
+	},
+}
+
+// printNoMatch writes why no mapping matched a caller to w, one reason per line
+func printNoMatch(w io.Writer, err *mapper.NoMatchError) {
+	fmt.Fprintf(w, "%v for %v\n", mapper.ErrNoMatchingMapping, err.CallerARN)
+	for _, reason := range err.Reasons {
+		fmt.Fprintf(w, "  - %v\n", reason)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(resolveCmd)
+	resolveCmd.Flags().StringVar(&resolveCaller.CallerARN, "arn", "", "ARN of the IAM principal, an IAM role or user ARN or an STS assumed-role ARN")
+	resolveCmd.Flags().StringVar(&resolveCaller.SessionName, "session-name", "", "Session name of the caller, defaults to the session name of an assumed-role ARN")
+	resolveCmd.Flags().StringVar(&resolveCaller.AccessKeyID, "access-key-id", "", "Access key ID of the caller to render {{AccessKeyID}}")
+	resolveCmd.Flags().StringVar(&resolveCaller.EC2PrivateDNSName, "ec2-private-dns-name", "", "Private DNS name of the instance to render {{EC2PrivateDNSName}}")
+	resolveCmd.Flags().StringVar(&resolveArgs.Format, "format", mapper.FormatTable, "Output format, one of table or json")
+	resolveCmd.Flags().StringVar(&resolveArgs.KubeconfigPath, "kubeconfig", "", "Kubeconfig path")
+	resolveCmd.Flags().DurationVar(&resolveArgs.Timeout, "timeout", 0, "Maximum duration of the operation, zero means no timeout")
+	resolveCmd.Flags().StringVar(&resolveArgs.AsUser, "as", "", "Username to impersonate for the operation")
+	resolveCmd.Flags().StringSliceVar(&resolveArgs.AsGroups, "as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
+	_ = resolveCmd.MarkFlagRequired("arn")
+}
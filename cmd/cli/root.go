@@ -16,16 +16,177 @@ limitations under the License.
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/keikoproj/aws-auth/pkg/mapper"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// Exit codes returned by the commands, argument errors each have a distinct exit code
+const (
+	exitCodeError              = 1
+	exitCodeInvalidRetryCount  = 3
+	exitCodeMissingRoleARN     = 4
+	exitCodeMissingUserARN     = 5
+	exitCodeMissingAccountID   = 6
+	exitCodeMissingUsername    = 7
+	exitCodeMissingMapType     = 8
+	exitCodeMutuallyExclusive  = 9
+	exitCodeUnsupportedFormat  = 10
+	exitCodeDuplicateMapping   = 11
+	exitCodeInvalidDryRun      = 12
+	exitCodeInvalidARN         = 13
+	exitCodeInvalidAccountID   = 14
+	exitCodeConflictingARNs    = 15
+	exitCodeInvalidTemplate    = 16
+	exitCodeNoMatchingMapping  = 17
+	exitCodeBackupNotFound     = 18
+	exitCodeUnsafeRestore      = 19
+	exitCodeRevisionNotFound   = 20
+	exitCodeHistoryDiverged    = 21
+	exitCodeProtected          = 22
+	exitCodePolicyViolation    = 23
+	exitCodeInvalidPolicy      = 24
+	exitCodeLintFindings       = 25
+	exitCodeInvalidSeverity    = 26
+	exitCodeClusterRequired    = 27
+	exitCodePartialFailure     = 28
+	exitCodeInvalidConcurrency = 29
+	exitCodeAccessEntry        = 30
+	exitCodeTimeout            = 124
+	exitCodeInterrupted        = 130
+)
+
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{mapper.ErrInvalidRetryCount, exitCodeInvalidRetryCount},
+	{mapper.ErrMissingRoleARN, exitCodeMissingRoleARN},
+	{mapper.ErrMissingUserARN, exitCodeMissingUserARN},
+	{mapper.ErrMissingAccountID, exitCodeMissingAccountID},
+	{mapper.ErrMissingUsername, exitCodeMissingUsername},
+	{mapper.ErrMissingMapType, exitCodeMissingMapType},
+	{mapper.ErrMutuallyExclusive, exitCodeMutuallyExclusive},
+	{mapper.ErrUnsupportedFormat, exitCodeUnsupportedFormat},
+	{mapper.ErrDuplicateMapping, exitCodeDuplicateMapping},
+	{mapper.ErrInvalidDryRun, exitCodeInvalidDryRun},
+	{mapper.ErrInvalidARN, exitCodeInvalidARN},
+	{mapper.ErrInvalidAccountID, exitCodeInvalidAccountID},
+	{mapper.ErrConflictingRoleARNs, exitCodeConflictingARNs},
+	{mapper.ErrInvalidUsernameTemplate, exitCodeInvalidTemplate},
+	{mapper.ErrNoMatchingMapping, exitCodeNoMatchingMapping},
+	{mapper.ErrBackupNotFound, exitCodeBackupNotFound},
+	{mapper.ErrUnsafeRestore, exitCodeUnsafeRestore},
+	{mapper.ErrRevisionNotFound, exitCodeRevisionNotFound},
+	{mapper.ErrHistoryDiverged, exitCodeHistoryDiverged},
+	{mapper.ErrProtected, exitCodeProtected},
+	{mapper.ErrPolicyViolation, exitCodePolicyViolation},
+	{mapper.ErrInvalidPolicy, exitCodeInvalidPolicy},
+	{mapper.ErrLintFindings, exitCodeLintFindings},
+	{mapper.ErrInvalidSeverity, exitCodeInvalidSeverity},
+	{mapper.ErrClusterRequired, exitCodeClusterRequired},
+	{errPartialFailure, exitCodePartialFailure},
+	{errInvalidConcurrency, exitCodeInvalidConcurrency},
+	{mapper.ErrUnsupportedAccessEntry, exitCodeAccessEntry},
+	{context.DeadlineExceeded, exitCodeTimeout},
+	{context.Canceled, exitCodeInterrupted},
+}
+
+// exitCode returns the exit code for an error, when multiple errors are joined the first known error wins
+func exitCode(err error) int {
+	for _, e := range exitCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return exitCodeError
+}
+
+// exitOnError prints the error and exits with the matching exit code
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	log.Printf("error: %v", err)
+	os.Exit(exitCode(err))
+}
+
+// commandContext returns a context which is cancelled on SIGINT or SIGTERM, and after timeout if it is set
+func commandContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// manifestFile is the manifest the commands operate on instead of a cluster when it is set
+var manifestFile string
+
+// configMapNamespace and configMapName locate the aws-auth configmap
+var (
+	configMapNamespace string
+	configMapName      string
+)
+
+// newWorker returns a mapper for the manifest file when --file is set, or for the kubeconfig,
+// --context and impersonation of args
+func newWorker(args *mapper.MapperArguments) *mapper.AuthMapper {
+	worker, err := newClusterWorker(args, kubeContext)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return worker
+}
+
+// newClusterWorker is like newWorker for the cluster of a kubeconfig context
+func newClusterWorker(args *mapper.MapperArguments, kubeContext string) (*mapper.AuthMapper, error) {
+	opts := configMapOptions()
+	if manifestFile != "" {
+		return mapper.NewFromFile(manifestFile, true, opts...), nil
+	}
+
+	options := kubeOptions{
+		Context:  kubeContext,
+		AsUser:   args.AsUser,
+		AsGroups: args.AsGroups,
+	}
+
+	k, err := getKubernetesClient(args.KubeconfigPath, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapper.New(k, true, opts...), nil
+}
+
+// configMapOptions returns the mapper options which locate the aws-auth configmap
+func configMapOptions() []mapper.Option {
+	return []mapper.Option{
+		mapper.WithNamespace(configMapNamespace),
+		mapper.WithConfigMapName(configMapName),
+	}
+}
+
 type kubeOptions struct {
+	// Context is the kubeconfig context to use instead of the current context
+	Context  string
 	AsUser   string
 	AsGroups []string
 }
@@ -37,6 +198,13 @@ var rootCmd = &cobra.Command{
 	Long:  `aws-auth modifies the aws-auth configmap on eks clusters`,
 }
 
+func init() {
+	rootCmd.PersistentFlags().StringVar(&manifestFile, "file", "", "Path of an aws-auth manifest to operate on instead of a cluster, the file is edited in place")
+	rootCmd.PersistentFlags().StringVar(&configMapNamespace, "namespace", mapper.AwsAuthNamespace, "Namespace of the aws-auth configmap, backups are kept in the same namespace")
+	rootCmd.PersistentFlags().StringVar(&configMapName, "configmap-name", mapper.AwsAuthName, "Name of the aws-auth configmap")
+	rootCmd.PersistentFlags().StringVar(&kubeContext, "context", "", "Kubeconfig context of the cluster to operate on, the current context by default")
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -56,9 +224,15 @@ func getKubernetesConfig() (*rest.Config, error) {
 }
 
 func getKubernetesLocalConfig() (*rest.Config, error) {
+	return kubeconfigLoader("", "").ClientConfig()
+}
+
+// kubeconfigLoader loads the kubeconfig at kubePath, or the default kubeconfig when it is empty, with
+// kubeContext as the current context when it is set
+func kubeconfigLoader(kubePath, kubeContext string) clientcmd.ClientConfig {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	clientCfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
-	return clientCfg.ClientConfig()
+	loadingRules.ExplicitPath = kubePath
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext})
 }
 
 func getKubernetesClient(kubePath string, options kubeOptions) (kubernetes.Interface, error) {
@@ -67,14 +241,14 @@ func getKubernetesClient(kubePath string, options kubeOptions) (kubernetes.Inter
 		err    error
 	)
 
-	// if kubeconfig path is not provided, try to auto detect
-	if kubePath == "" {
+	// if kubeconfig path and context are not provided, try to auto detect
+	if kubePath == "" && options.Context == "" {
 		config, err = getKubernetesConfig()
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		config, err = clientcmd.BuildConfigFromFlags("", kubePath)
+		config, err = kubeconfigLoader(kubePath, options.Context).ClientConfig()
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,192 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/keikoproj/aws-auth/pkg/mapper"
+	"github.com/spf13/cobra"
+)
+
+// authSource is one side of a diff or sync, the aws-auth configmap of the cluster of a kubeconfig
+// context or of a manifest file. Without either it is the cluster of the current context.
+type authSource struct {
+	Context string
+	File    string
+}
+
+var (
+	diffArgs         = &mapper.MapperArguments{}
+	diffFrom, diffTo authSource
+	syncArgs         = &mapper.MapperArguments{}
+	syncFrom, syncTo authSource
+	syncOptions      = mapper.SyncOptions{}
+	syncConfirm      bool
+)
+
+// diffCmd compares the mappings of two clusters or files
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "diff shows the mappings which differ between two clusters or a cluster and a file",
+	Long: `diff shows the changes which would turn the mappings of --from-context or --from-file into the mappings of --to-context or --to-file.
+A side without a context or file is the cluster of the current context, the filter flags limit the comparison to the selected mappings.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(validateSources(diffFrom, diffTo))
+		exitOnError(validatePlanFormat(diffArgs.Format))
+
+		ctx, cancel := commandContext(diffArgs.Timeout)
+		defer cancel()
+
+		from, err := readAuthSource(ctx, diffArgs, diffFrom)
+		exitOnError(err)
+		to, err := readAuthSource(ctx, diffArgs, diffTo)
+		exitOnError(err)
+
+		filter := diffArgs.Filter()
+		from, to = from.Filter(filter), to.Filter(filter)
+		exitOnError(printPlan(os.Stdout, diffArgs.Format, mapper.DiffAuthData(&from, &to)))
+	},
+}
+
+// syncCmd copies mappings from one cluster or file to another
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "sync copies mappings from one cluster or file to another",
+	Long: `sync upserts the mappings of --from-context or --from-file which are selected by the filter flags into --to-context or --to-file.
+Mappings of the target which are not selected are left untouched. The changes are only printed unless --confirm is passed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(validateSources(syncFrom, syncTo))
+		exitOnError(validatePlanFormat(syncArgs.Format))
+		if syncConfirm {
+			if cmd.Flags().Changed("dry-run") {
+				exitOnError(&mapper.MutuallyExclusiveError{Flags: []string{"--confirm", "--dry-run"}})
+			}
+			syncOptions.DryRun = mapper.DryRunNone
+		}
+		policies := mustReadPolicies()
+
+		ctx, cancel := commandContext(syncArgs.Timeout)
+		defer cancel()
+
+		source, err := readAuthSource(ctx, syncArgs, syncFrom)
+		exitOnError(err)
+
+		worker, err := sourceWorker(syncArgs, syncTo)
+		exitOnError(err)
+		worker.Backups = backupOptions
+		worker.Policies = policies
+		worker.OverrideProtection = overrideProtection
+		worker.Actor = syncArgs.AsUser
+
+		syncOptions.Filter = syncArgs.Filter()
+		plan, err := worker.SyncWithContext(ctx, &source, syncOptions)
+		exitOnError(err)
+
+		exitOnError(printPlan(os.Stdout, syncArgs.Format, plan))
+		if syncOptions.DryRun != mapper.DryRunNone && !plan.IsEmpty() {
+			log.Printf("dry run, pass --confirm to sync the changes")
+		}
+	},
+}
+
+// validateSources checks that each side of a diff or sync is either a context or a file. The global
+// --file and --context flags do not select a side and are rejected instead of being ignored.
+func validateSources(from, to authSource) error {
+	if manifestFile != "" {
+		return &mapper.MutuallyExclusiveError{Flags: []string{"--file", "--from-file or --to-file"}}
+	}
+	if kubeContext != "" {
+		return &mapper.MutuallyExclusiveError{Flags: []string{"--context", "--from-context or --to-context"}}
+	}
+	if from.Context != "" && from.File != "" {
+		return &mapper.MutuallyExclusiveError{Flags: []string{"--from-context", "--from-file"}}
+	}
+	if to.Context != "" && to.File != "" {
+		return &mapper.MutuallyExclusiveError{Flags: []string{"--to-context", "--to-file"}}
+	}
+	return nil
+}
+
+// sourceWorker returns a mapper for the file or the cluster of the context of s
+func sourceWorker(args *mapper.MapperArguments, s authSource) (*mapper.AuthMapper, error) {
+	if s.File != "" {
+		return mapper.NewFromFile(s.File, true, configMapOptions()...), nil
+	}
+
+	k, err := getKubernetesClient(args.KubeconfigPath, kubeOptions{
+		Context:  s.Context,
+		AsUser:   args.AsUser,
+		AsGroups: args.AsGroups,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mapper.New(k, true, configMapOptions()...), nil
+}
+
+// readAuthSource returns the mappings of s, a missing configmap or file has none and is not created
+func readAuthSource(ctx context.Context, args *mapper.MapperArguments, s authSource) (mapper.AwsAuthData, error) {
+	worker, err := sourceWorker(args, s)
+	if err != nil {
+		return mapper.AwsAuthData{}, err
+	}
+	return worker.GetWithContext(ctx, &mapper.MapperArguments{})
+}
+
+// addSourceFlags adds the flags which select the two sides of a diff or sync
+func addSourceFlags(cmd *cobra.Command, from, to *authSource) {
+	cmd.Flags().StringVar(&from.Context, "from-context", "", "Kubeconfig context of the cluster to read the mappings from")
+	cmd.Flags().StringVar(&from.File, "from-file", "", "Manifest file to read the mappings from")
+	cmd.Flags().StringVar(&to.Context, "to-context", "", "Kubeconfig context of the cluster to compare with or sync to")
+	cmd.Flags().StringVar(&to.File, "to-file", "", "Manifest file to compare with or sync to, it is created by sync when it does not exist")
+}
+
+// addFilterFlags adds the flags which select the mappings a diff or sync works on
+func addFilterFlags(cmd *cobra.Command, args *mapper.MapperArguments) {
+	cmd.Flags().BoolVar(&args.MapRoles, "maproles", false, "Only select role mappings")
+	cmd.Flags().BoolVar(&args.MapUsers, "mapusers", false, "Only select user mappings")
+	cmd.Flags().BoolVar(&args.MapAccounts, "mapaccounts", false, "Only select account mappings")
+	cmd.Flags().StringVar(&args.RoleARN, "rolearn", "", "Only select role mappings matching the ARN, '*' and '?' can be used as wildcards")
+	cmd.Flags().StringVar(&args.UserARN, "userarn", "", "Only select user mappings matching the ARN, '*' and '?' can be used as wildcards")
+	cmd.Flags().StringVar(&args.Username, "username", "", "Only select mappings matching the username, '*' and '?' can be used as wildcards")
+	cmd.Flags().StringSliceVar(&args.Groups, "group", []string{}, "Only select mappings which have the group, this flag can be repeated to require multiple groups")
+	cmd.Flags().StringVar(&args.AccountID, "account", "", "Only select mappings of the account ID")
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(syncCmd)
+
+	addSourceFlags(diffCmd, &diffFrom, &diffTo)
+	addFilterFlags(diffCmd, diffArgs)
+	diffCmd.Flags().StringVar(&diffArgs.Format, "format", mapper.FormatDiff, "The format in which to print the differences, one of: diff, json")
+	addClientFlags(diffCmd, diffArgs)
+
+	addSourceFlags(syncCmd, &syncFrom, &syncTo)
+	addFilterFlags(syncCmd, syncArgs)
+	syncCmd.Flags().BoolVar(&syncOptions.Append, "append", false, "Add the groups of the source to the groups of existing mappings instead of replacing them")
+	syncCmd.Flags().BoolVar(&syncConfirm, "confirm", false, "Sync the changes, without it they are only printed")
+	syncCmd.Flags().StringVar((*string)(&syncOptions.DryRun), "dry-run", string(mapper.DryRunClient), "How the changes are computed when they are only printed, 'client' computes them locally, 'server' also submits them to the API server without persisting")
+	syncCmd.Flags().Lookup("dry-run").NoOptDefVal = string(mapper.DryRunClient)
+	syncCmd.Flags().StringVar(&syncArgs.Format, "format", mapper.FormatDiff, "The format in which to print the changes, one of: diff, json")
+	addBackupFlags(syncCmd)
+	addPolicyFlags(syncCmd)
+	addProtectionFlags(syncCmd)
+	addClientFlags(syncCmd, syncArgs)
+}
@@ -16,7 +16,9 @@ limitations under the License.
 package cli
 
 import (
-	"log"
+	"context"
+	"io"
+	"os"
 	"time"
 
 	"github.com/keikoproj/aws-auth/pkg/mapper"
@@ -31,23 +33,29 @@ var upsertArgs = &mapper.MapperArguments{
 // upsertCmd represents the base command when called without any subcommands
 var upsertCmd = &cobra.Command{
 	Use:   "upsert",
-	Short: "upsert updates or inserts a user or role to the aws-auth configmap",
-	Long:  `upsert updates or inserts a user or role to the aws-auth configmap`,
+	Short: "upsert updates or inserts a user, role or account to the aws-auth configmap",
+	Long:  `upsert updates or inserts a user, role or account to the aws-auth configmap`,
 	Run: func(cmd *cobra.Command, args []string) {
-		options := kubeOptions{
-			AsUser:   upsertArgs.AsUser,
-			AsGroups: upsertArgs.AsGroups,
-		}
+		policies := mustReadPolicies()
+		ctx, cancel := commandContext(upsertArgs.Timeout)
+		defer cancel()
 
-		k, err := getKubernetesClient(upsertArgs.KubeconfigPath, options)
-		if err != nil {
-			log.Fatal(err)
-		}
+		exitOnError(forEachCluster(ctx, upsertArgs, os.Stdout, func(ctx context.Context, worker *mapper.AuthMapper, args *mapper.MapperArguments, w io.Writer) error {
+			worker.Backups = backupOptions
+			worker.Policies = policies
+			worker.OverrideProtection = overrideProtection
+			worker.Actor = args.AsUser
 
-		worker := mapper.New(k, true)
-		if err := worker.Upsert(upsertArgs); err != nil {
-			log.Fatal(err)
-		}
+			if args.DryRun != mapper.DryRunNone {
+				plan, err := worker.PlanWithContext(ctx, args)
+				if err != nil {
+					return err
+				}
+				return printPlan(w, args.Format, plan)
+			}
+
+			return worker.UpsertWithContext(ctx, args)
+		}))
 	},
 }
 
@@ -60,12 +68,21 @@ func init() {
 	upsertCmd.Flags().StringSliceVar(&upsertArgs.Groups, "groups", []string{}, "Groups to upsert")
 	upsertCmd.Flags().BoolVar(&upsertArgs.MapRoles, "maproles", false, "Upsert a role")
 	upsertCmd.Flags().BoolVar(&upsertArgs.MapUsers, "mapusers", false, "Upsert a user")
+	upsertCmd.Flags().BoolVar(&upsertArgs.MapAccounts, "mapaccounts", false, "Upsert an account")
+	upsertCmd.Flags().StringVar(&upsertArgs.AccountID, "account", "", "Account ID to upsert")
 	upsertCmd.Flags().BoolVar(&upsertArgs.WithRetries, "retry", false, "Retry on failure with exponential backoff")
 	upsertCmd.Flags().DurationVar(&upsertArgs.MinRetryTime, "retry-min-time", time.Millisecond*200, "Minimum wait interval")
 	upsertCmd.Flags().DurationVar(&upsertArgs.MaxRetryTime, "retry-max-time", time.Second*30, "Maximum wait interval")
 	upsertCmd.Flags().IntVar(&upsertArgs.MaxRetryCount, "retry-max-count", 12, "Maximum number of retries before giving up")
+	upsertCmd.Flags().DurationVar(&upsertArgs.Timeout, "timeout", 0, "Maximum duration of the operation including retries, zero means no timeout")
 	upsertCmd.Flags().BoolVar(&upsertArgs.Append, "append", false, "append to a existing group list")
 	upsertCmd.Flags().BoolVar(upsertArgs.UpdateUsername, "update-username", true, "set to false to not overwite username")
+	upsertCmd.Flags().BoolVar(&upsertArgs.IgnorePath, "ignore-path", false, "Match an existing role regardless of its IAM path, as the authenticator does")
+	addDryRunFlags(upsertCmd, upsertArgs)
+	addBackupFlags(upsertCmd)
+	addPolicyFlags(upsertCmd)
+	addProtectionFlags(upsertCmd)
+	addContextFlags(upsertCmd)
 	upsertCmd.Flags().StringVar(&upsertArgs.AsUser, "as", "", "Username to impersonate for the operation")
 	upsertCmd.Flags().StringSliceVar(&upsertArgs.AsGroups, "as-group", []string{}, "Group to impersonate for the operation, this flag can be repeated to specify multiple groups")
 }
@@ -0,0 +1,340 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	yaml "gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+)
+
+// AppliedAnnotation is the ownership marker of Apply, it records the ARNs and accounts applied so far.
+// An Apply without prune adds its desired state to the recorded mappings, a pruning Apply replaces them.
+// A pruning Apply only deletes mappings which are recorded in it, so mappings added by upsert or by
+// other tools are never deleted.
+const AppliedAnnotation = "aws-auth.keikoproj.io/last-applied"
+
+// ApplyOptions are the options of Apply
+type ApplyOptions struct {
+	// Prune deletes mappings which were applied before and are no longer in the desired state
+	Prune bool
+	// DryRun computes the result without persisting it
+	DryRun DryRunMode
+}
+
+// ApplyResult lists the mappings which were added, updated and deleted by Apply
+type ApplyResult struct {
+	Added   []Mapping
+	Updated []Mapping
+	Deleted []Mapping
+}
+
+// Changed returns true if any mapping was added, updated or deleted
+func (r *ApplyResult) Changed() bool {
+	return len(r.Added) > 0 || len(r.Updated) > 0 || len(r.Deleted) > 0
+}
+
+// appliedMappings is the content of the AppliedAnnotation
+type appliedMappings struct {
+	MapRoles    []string `json:"mapRoles,omitempty"`
+	MapUsers    []string `json:"mapUsers,omitempty"`
+	MapAccounts []string `json:"mapAccounts,omitempty"`
+}
+
+// UnmarshalAuthData parses a desired state document with mapRoles, mapUsers and mapAccounts lists.
+// Unknown fields are rejected so that typos are not silently ignored.
+func UnmarshalAuthData(data []byte) (AwsAuthData, error) {
+	var authData AwsAuthData
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&authData); err != nil && err != io.EOF {
+		return AwsAuthData{}, err
+	}
+	return authData, nil
+}
+
+// Validate checks that every mapping of a desired state has a valid ARN or account and a valid
+// username, and that no ARN or account is listed twice
+func (m *AwsAuthData) Validate() error {
+	var errs []error
+
+	roles := map[string]bool{}
+	for i, r := range m.MapRoles {
+		if r.RoleARN == "" {
+			errs = append(errs, fmt.Errorf("mapRoles[%d]: %w", i, ErrMissingRoleARN))
+		} else if err := ValidateRoleARN(r.RoleARN); err != nil {
+			errs = append(errs, fmt.Errorf("mapRoles[%d]: %w", i, err))
+		} else if roles[r.RoleARN] {
+			errs = append(errs, fmt.Errorf("mapRoles[%d]: %w %v", i, ErrDuplicateMapping, r.RoleARN))
+		}
+		if r.Username == "" {
+			errs = append(errs, fmt.Errorf("mapRoles[%d]: %w", i, ErrMissingUsername))
+		} else if err := ValidateUsernameTemplate(r.Username); err != nil {
+			errs = append(errs, fmt.Errorf("mapRoles[%d]: %w", i, err))
+		}
+		roles[r.RoleARN] = true
+	}
+
+	users := map[string]bool{}
+	for i, u := range m.MapUsers {
+		if u.UserARN == "" {
+			errs = append(errs, fmt.Errorf("mapUsers[%d]: %w", i, ErrMissingUserARN))
+		} else if err := ValidateUserARN(u.UserARN); err != nil {
+			errs = append(errs, fmt.Errorf("mapUsers[%d]: %w", i, err))
+		} else if users[u.UserARN] {
+			errs = append(errs, fmt.Errorf("mapUsers[%d]: %w %v", i, ErrDuplicateMapping, u.UserARN))
+		}
+		if u.Username == "" {
+			errs = append(errs, fmt.Errorf("mapUsers[%d]: %w", i, ErrMissingUsername))
+		} else if err := ValidateUsernameTemplate(u.Username); err != nil {
+			errs = append(errs, fmt.Errorf("mapUsers[%d]: %w", i, err))
+		}
+		users[u.UserARN] = true
+	}
+
+	accounts := map[string]bool{}
+	for i, a := range m.MapAccounts {
+		if a == "" {
+			errs = append(errs, fmt.Errorf("mapAccounts[%d]: %w", i, ErrMissingAccountID))
+		} else if err := ValidateAccountID(a); err != nil {
+			errs = append(errs, fmt.Errorf("mapAccounts[%d]: %w", i, err))
+		} else if accounts[a] {
+			errs = append(errs, fmt.Errorf("mapAccounts[%d]: %w %v", i, ErrDuplicateMapping, a))
+		}
+		accounts[a] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// Apply makes the aws-auth configmap match the desired state. Mappings in the desired state are added
+// or updated, other mappings are left untouched unless opts.Prune is set and they were applied before.
+// The configmap is not updated when it already matches.
+func (b *AuthMapper) Apply(desired *AwsAuthData, opts ApplyOptions) (*ApplyResult, error) {
+	return b.ApplyWithContext(context.Background(), desired, opts)
+}
+
+// ApplyWithContext is like Apply but uses the given context for the API calls
+func (b *AuthMapper) ApplyWithContext(ctx context.Context, desired *AwsAuthData, opts ApplyOptions) (*ApplyResult, error) {
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun != DryRunNone && opts.DryRun != DryRunClient && opts.DryRun != DryRunServer {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDryRun, opts.DryRun)
+	}
+
+	var result *ApplyResult
+	fn := func(authData *AwsAuthData, cm *v1.ConfigMap) (bool, error) {
+		var applied appliedMappings
+		if v, ok := cm.Annotations[AppliedAnnotation]; ok {
+			if err := json.Unmarshal([]byte(v), &applied); err != nil {
+				return false, fmt.Errorf("failed to parse annotation %v: %w", AppliedAnnotation, err)
+			}
+		}
+
+		result = applyAuthData(authData, desired, applied, opts.Prune)
+
+		// without prune the mappings of earlier applies stay owned so that a later prune deletes them
+		owned := desiredMappings(desired)
+		if !opts.Prune {
+			owned = applied.present(authData).union(owned)
+		}
+		marker, err := json.Marshal(owned)
+		if err != nil {
+			return false, err
+		}
+
+		if !result.Changed() && cm.Annotations[AppliedAnnotation] == string(marker) {
+			b.logf("found zero changes to apply, configmap is not changed \n")
+			return false, nil
+		}
+
+		if cm.Annotations == nil {
+			cm.Annotations = make(map[string]string)
+		}
+		cm.Annotations[AppliedAnnotation] = string(marker)
+		return true, nil
+	}
+
+	if opts.DryRun != DryRunNone {
+		if _, err := b.planConfigMap(ctx, OperationApply, fn, opts.DryRun); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	if err := b.mutateConfigMap(ctx, OperationApply, fn); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func desiredMappings(desired *AwsAuthData) appliedMappings {
+	var applied appliedMappings
+	for _, r := range desired.MapRoles {
+		applied.MapRoles = append(applied.MapRoles, r.RoleARN)
+	}
+	for _, u := range desired.MapUsers {
+		applied.MapUsers = append(applied.MapUsers, u.UserARN)
+	}
+	applied.MapAccounts = append(applied.MapAccounts, desired.MapAccounts...)
+	return applied
+}
+
+// present returns the recorded mappings which are still in authData
+func (a appliedMappings) present(authData *AwsAuthData) appliedMappings {
+	var roles, users []string
+	for _, r := range authData.MapRoles {
+		roles = append(roles, r.RoleARN)
+	}
+	for _, u := range authData.MapUsers {
+		users = append(users, u.UserARN)
+	}
+
+	keep := func(recorded, existing []string) []string {
+		var kept []string
+		for _, v := range recorded {
+			if slices.Contains(existing, v) {
+				kept = append(kept, v)
+			}
+		}
+		return kept
+	}
+	return appliedMappings{
+		MapRoles:    keep(a.MapRoles, roles),
+		MapUsers:    keep(a.MapUsers, users),
+		MapAccounts: keep(a.MapAccounts, authData.MapAccounts),
+	}
+}
+
+// union returns the recorded mappings of a followed by those of other which are not in a
+func (a appliedMappings) union(other appliedMappings) appliedMappings {
+	add := func(recorded, more []string) []string {
+		for _, v := range more {
+			if !slices.Contains(recorded, v) {
+				recorded = append(recorded, v)
+			}
+		}
+		return recorded
+	}
+	return appliedMappings{
+		MapRoles:    add(a.MapRoles, other.MapRoles),
+		MapUsers:    add(a.MapUsers, other.MapUsers),
+		MapAccounts: add(a.MapAccounts, other.MapAccounts),
+	}
+}
+
+// applyAuthData updates authData in place to match the desired state and returns the changes made
+func applyAuthData(authData *AwsAuthData, desired *AwsAuthData, applied appliedMappings, prune bool) *ApplyResult {
+	result := &ApplyResult{}
+
+	desiredRoles := make(map[string]*RolesAuthMap, len(desired.MapRoles))
+	for _, r := range desired.MapRoles {
+		desiredRoles[r.RoleARN] = r
+	}
+
+	var mapRoles []*RolesAuthMap
+	foundRoles := map[string]bool{}
+	for _, existing := range authData.MapRoles {
+		d, ok := desiredRoles[existing.RoleARN]
+		if !ok {
+			if prune && slices.Contains(applied.MapRoles, existing.RoleARN) {
+				result.Deleted = append(result.Deleted, newMapping(RoleMappingKind, existing.RoleARN, existing.Username, existing.Groups))
+				continue
+			}
+			mapRoles = append(mapRoles, existing)
+			continue
+		}
+
+		foundRoles[existing.RoleARN] = true
+		if existing.Username != d.Username || !groupsEqual(existing.Groups, d.Groups) {
+			existing.SetUsername(d.Username).SetGroups(d.Groups)
+			result.Updated = append(result.Updated, newMapping(RoleMappingKind, d.RoleARN, d.Username, d.Groups))
+		}
+		mapRoles = append(mapRoles, existing)
+	}
+
+	for _, d := range desired.MapRoles {
+		if !foundRoles[d.RoleARN] {
+			mapRoles = append(mapRoles, NewRolesAuthMap(d.RoleARN, d.Username, d.Groups))
+			result.Added = append(result.Added, newMapping(RoleMappingKind, d.RoleARN, d.Username, d.Groups))
+		}
+	}
+
+	desiredUsers := make(map[string]*UsersAuthMap, len(desired.MapUsers))
+	for _, u := range desired.MapUsers {
+		desiredUsers[u.UserARN] = u
+	}
+
+	var mapUsers []*UsersAuthMap
+	foundUsers := map[string]bool{}
+	for _, existing := range authData.MapUsers {
+		d, ok := desiredUsers[existing.UserARN]
+		if !ok {
+			if prune && slices.Contains(applied.MapUsers, existing.UserARN) {
+				result.Deleted = append(result.Deleted, newMapping(UserMappingKind, existing.UserARN, existing.Username, existing.Groups))
+				continue
+			}
+			mapUsers = append(mapUsers, existing)
+			continue
+		}
+
+		foundUsers[existing.UserARN] = true
+		if existing.Username != d.Username || !groupsEqual(existing.Groups, d.Groups) {
+			existing.SetUsername(d.Username).SetGroups(d.Groups)
+			result.Updated = append(result.Updated, newMapping(UserMappingKind, d.UserARN, d.Username, d.Groups))
+		}
+		mapUsers = append(mapUsers, existing)
+	}
+
+	for _, d := range desired.MapUsers {
+		if !foundUsers[d.UserARN] {
+			mapUsers = append(mapUsers, NewUsersAuthMap(d.UserARN, d.Username, d.Groups))
+			result.Added = append(result.Added, newMapping(UserMappingKind, d.UserARN, d.Username, d.Groups))
+		}
+	}
+
+	var mapAccounts []string
+	for _, existing := range authData.MapAccounts {
+		if !slices.Contains(desired.MapAccounts, existing) && prune && slices.Contains(applied.MapAccounts, existing) {
+			result.Deleted = append(result.Deleted, Mapping{Kind: AccountMappingKind, Groups: []string{}, Account: existing})
+			continue
+		}
+		mapAccounts = append(mapAccounts, existing)
+	}
+
+	for _, d := range desired.MapAccounts {
+		if !slices.Contains(authData.MapAccounts, d) {
+			mapAccounts = append(mapAccounts, d)
+			result.Added = append(result.Added, Mapping{Kind: AccountMappingKind, Groups: []string{}, Account: d})
+		}
+	}
+
+	authData.SetMapRoles(mapRoles)
+	authData.SetMapUsers(mapUsers)
+	authData.SetMapAccounts(mapAccounts)
+	return result
+}
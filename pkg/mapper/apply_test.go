@@ -0,0 +1,244 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const desiredState = `mapRoles:
+  - rolearn: arn:aws:iam::000000000000:role/node-1
+    username: system:node:{{EC2PrivateDNSName}}
+    groups:
+      - system:bootstrappers
+      - system:nodes
+  - rolearn: arn:aws:iam::000000000000:role/ci
+    username: ci
+    groups:
+      - deployers
+mapUsers:
+  - userarn: arn:aws:iam::000000000000:user/user-1
+    username: admin
+    groups:
+      - system:masters
+      - auditors
+mapAccounts:
+  - "111111111111"
+`
+
+func count_Updates(client *fake.Clientset) int {
+	var n int
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestUnmarshalAuthData(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	desired, err := UnmarshalAuthData([]byte(desiredState))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(desired.MapRoles).To(gomega.HaveLen(2))
+	g.Expect(desired.MapUsers).To(gomega.HaveLen(1))
+	g.Expect(desired.MapAccounts).To(gomega.Equal([]string{"111111111111"}))
+
+	desired, err = UnmarshalAuthData(nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(desired).To(gomega.Equal(AwsAuthData{}))
+
+	_, err = UnmarshalAuthData([]byte("mapRoles:\n  - rolearn: arn:aws:iam::000000000000:role/ci\n    usernme: ci\n"))
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(err.Error()).To(gomega.ContainSubstring("usernme"))
+}
+
+func TestAwsAuthData_Validate(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	desired := &AwsAuthData{
+		MapRoles: []*RolesAuthMap{
+			NewRolesAuthMap("arn:aws:iam::000000000000:role/ci", "ci", nil),
+			NewRolesAuthMap("arn:aws:iam::000000000000:role/ci", "ci", nil),
+			NewRolesAuthMap("", "ci", nil),
+		},
+		MapUsers: []*UsersAuthMap{
+			NewUsersAuthMap("arn:aws:iam::000000000000:user/user-1", "", nil),
+		},
+		MapAccounts: []string{""},
+	}
+
+	err := desired.Validate()
+	g.Expect(err).To(gomega.MatchError(ErrDuplicateMapping))
+	g.Expect(err).To(gomega.MatchError(ErrMissingRoleARN))
+	g.Expect(err).To(gomega.MatchError(ErrMissingUsername))
+	g.Expect(err).To(gomega.MatchError(ErrMissingAccountID))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("mapRoles[1]: duplicate mapping arn:aws:iam::000000000000:role/ci"))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("mapUsers[0]: --username not provided"))
+
+	g.Expect((&AwsAuthData{}).Validate()).To(gomega.Succeed())
+}
+
+func TestMapper_Apply(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	desired, err := UnmarshalAuthData([]byte(desiredState))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	result, err := mapper.Apply(&desired, ApplyOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(result.Added).To(gomega.HaveLen(2))
+	g.Expect(result.Added[0].ARN).To(gomega.Equal("arn:aws:iam::000000000000:role/ci"))
+	g.Expect(result.Added[1].Account).To(gomega.Equal("111111111111"))
+	g.Expect(result.Updated).To(gomega.HaveLen(1))
+	g.Expect(result.Updated[0].Groups).To(gomega.Equal([]string{"system:masters", "auditors"}))
+	g.Expect(result.Deleted).To(gomega.BeEmpty())
+
+	auth, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth).To(gomega.Equal(desired))
+	g.Expect(cm.Annotations[AppliedAnnotation]).To(gomega.Equal(`{"mapRoles":["arn:aws:iam::000000000000:role/node-1","arn:aws:iam::000000000000:role/ci"],"mapUsers":["arn:aws:iam::000000000000:user/user-1"],"mapAccounts":["111111111111"]}`))
+}
+
+func TestMapper_ApplyNoChanges(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	desired, err := UnmarshalAuthData([]byte(desiredState))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, err = mapper.Apply(&desired, ApplyOptions{Prune: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(count_Updates(client)).To(gomega.Equal(1))
+
+	result, err := mapper.Apply(&desired, ApplyOptions{Prune: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(result.Changed()).To(gomega.BeFalse())
+	g.Expect(count_Updates(client)).To(gomega.Equal(1))
+}
+
+func TestMapper_ApplyPruneOnlyDeletesAppliedMappings(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+
+	desired := &AwsAuthData{
+		MapRoles: []*RolesAuthMap{
+			NewRolesAuthMap("arn:aws:iam::000000000000:role/a", "a", nil),
+			NewRolesAuthMap("arn:aws:iam::000000000000:role/b", "b", nil),
+		},
+		MapAccounts: []string{"111111111111"},
+	}
+	_, err := mapper.Apply(desired, ApplyOptions{Prune: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// c is not managed by apply
+	err = mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/c",
+		Username: "c",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	desired = &AwsAuthData{
+		MapRoles: []*RolesAuthMap{
+			NewRolesAuthMap("arn:aws:iam::000000000000:role/a", "a", nil),
+		},
+	}
+
+	// without prune nothing is deleted
+	result, err := mapper.Apply(desired, ApplyOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(result.Deleted).To(gomega.BeEmpty())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(3))
+
+	// b and the account are still recorded from the first apply, re-applying them keeps them managed
+	desired.MapRoles = append(desired.MapRoles, NewRolesAuthMap("arn:aws:iam::000000000000:role/b", "b", nil))
+	desired.MapAccounts = []string{"111111111111"}
+	_, err = mapper.Apply(desired, ApplyOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	desired.MapRoles = desired.MapRoles[:1]
+	desired.MapAccounts = nil
+	result, err = mapper.Apply(desired, ApplyOptions{Prune: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(result.Deleted).To(gomega.HaveLen(2))
+	g.Expect(result.Deleted[0].Name()).To(gomega.Equal("role/arn:aws:iam::000000000000:role/b"))
+	g.Expect(result.Deleted[1].Name()).To(gomega.Equal("account/111111111111"))
+
+	auth, _, err = ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(2))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/a"))
+	g.Expect(auth.MapRoles[1].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/c"))
+	g.Expect(auth.MapAccounts).To(gomega.BeEmpty())
+}
+
+func TestMapper_ApplyPruneDeletesMappingsOfEarlierApplies(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+
+	a := &AwsAuthData{MapRoles: []*RolesAuthMap{NewRolesAuthMap("arn:aws:iam::000000000000:role/a", "a", nil)}}
+	b := &AwsAuthData{MapRoles: []*RolesAuthMap{NewRolesAuthMap("arn:aws:iam::000000000000:role/b", "b", nil)}}
+
+	_, err := mapper.Apply(a, ApplyOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	result, err := mapper.Apply(b, ApplyOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(result.Deleted).To(gomega.BeEmpty())
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Annotations[AppliedAnnotation]).To(gomega.Equal(`{"mapRoles":["arn:aws:iam::000000000000:role/a","arn:aws:iam::000000000000:role/b"]}`))
+
+	result, err = mapper.Apply(b, ApplyOptions{Prune: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(result.Deleted).To(gomega.HaveLen(1))
+	g.Expect(result.Deleted[0].Name()).To(gomega.Equal("role/arn:aws:iam::000000000000:role/a"))
+
+	auth, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/b"))
+	g.Expect(cm.Annotations[AppliedAnnotation]).To(gomega.Equal(`{"mapRoles":["arn:aws:iam::000000000000:role/b"]}`))
+}
+
+func TestMapper_ApplyInvalid(t *testing.T) {
+	g := gomega.NewWithT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, false)
+
+	_, err := mapper.Apply(&AwsAuthData{MapUsers: []*UsersAuthMap{NewUsersAuthMap("", "admin", nil)}}, ApplyOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrMissingUserARN))
+	g.Expect(client.Actions()).To(gomega.BeEmpty())
+}
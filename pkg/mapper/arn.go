@@ -0,0 +1,213 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Resource types of the ARNs which can be mapped or resolved
+const (
+	ResourceTypeRole        = "role"
+	ResourceTypeUser        = "user"
+	ResourceTypeAssumedRole = "assumed-role"
+	// ResourceTypeRoot is the root user of an account, its ARN has no name and is mapped as a user
+	ResourceTypeRoot = "root"
+)
+
+// Partitions are the AWS partitions accepted in ARNs
+var Partitions = []string{"aws", "aws-cn", "aws-us-gov", "aws-iso", "aws-iso-b"}
+
+var (
+	accountIDPattern = regexp.MustCompile(`^[0-9]{12}$`)
+	iamNamePattern   = regexp.MustCompile(`^[\w+=,.@-]+$`)
+)
+
+// ARN is a parsed IAM role, user or root ARN, or an STS assumed-role session ARN
+type ARN struct {
+	Partition string
+	// Service is iam for roles and users, and sts for assumed-role sessions
+	Service      string
+	AccountID    string
+	ResourceType string
+	// Path is the IAM path including the leading and trailing slash, "/" when there is none
+	Path string
+	// Name is the name of the role or user, for assumed-role sessions the name of the role. The root
+	// user has no name.
+	Name string
+	// SessionName is the session name of an assumed-role session
+	SessionName string
+}
+
+// ParseARN parses and validates an IAM role, user or root ARN, or an STS assumed-role session ARN
+func ParseARN(s string) (*ARN, error) {
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return nil, &ARNError{ARN: s, Reason: "must have the form arn:partition:service::account:resource"}
+	}
+
+	a := &ARN{
+		Partition: parts[1],
+		Service:   parts[2],
+		AccountID: parts[4],
+		Path:      "/",
+	}
+
+	if !slices.Contains(Partitions, a.Partition) {
+		return nil, &ARNError{ARN: s, Reason: fmt.Sprintf("unknown partition %q", a.Partition)}
+	}
+
+	if a.Service != "iam" && a.Service != "sts" {
+		return nil, &ARNError{ARN: s, Reason: fmt.Sprintf("service must be iam or sts, got %q", a.Service)}
+	}
+
+	if parts[3] != "" {
+		return nil, &ARNError{ARN: s, Reason: fmt.Sprintf("%v ARNs have no region, got %q", a.Service, parts[3])}
+	}
+
+	if !accountIDPattern.MatchString(a.AccountID) {
+		return nil, &ARNError{ARN: s, Reason: fmt.Sprintf("account %q must be 12 digits", a.AccountID)}
+	}
+
+	resource := strings.Split(parts[5], "/")
+	a.ResourceType = resource[0]
+
+	switch {
+	case a.Service == "iam" && (a.ResourceType == ResourceTypeRole || a.ResourceType == ResourceTypeUser):
+		if len(resource) < 2 {
+			return nil, &ARNError{ARN: s, Reason: fmt.Sprintf("%v name is missing", a.ResourceType)}
+		}
+		a.Name = resource[len(resource)-1]
+		if len(resource) > 2 {
+			a.Path = "/" + strings.Join(resource[1:len(resource)-1], "/") + "/"
+		}
+		for _, segment := range resource[1:] {
+			if !iamNamePattern.MatchString(segment) {
+				return nil, &ARNError{ARN: s, Reason: fmt.Sprintf("invalid %v name or path %q", a.ResourceType, strings.Join(resource[1:], "/"))}
+			}
+		}
+	case a.Service == "iam" && a.ResourceType == ResourceTypeRoot:
+		if len(resource) != 1 {
+			return nil, &ARNError{ARN: s, Reason: "the root user has no name or path"}
+		}
+	case a.Service == "sts" && a.ResourceType == ResourceTypeAssumedRole:
+		if len(resource) != 3 || !iamNamePattern.MatchString(resource[1]) || resource[2] == "" {
+			return nil, &ARNError{ARN: s, Reason: "must have the form assumed-role/role-name/session-name"}
+		}
+		a.Name = resource[1]
+		a.SessionName = resource[2]
+	default:
+		return nil, &ARNError{ARN: s, Reason: fmt.Sprintf("unsupported %v resource type %q", a.Service, a.ResourceType)}
+	}
+
+	return a, nil
+}
+
+// String returns the ARN in its canonical form
+func (a *ARN) String() string {
+	if a.ResourceType == ResourceTypeRoot {
+		return fmt.Sprintf("arn:%v:iam::%v:%v", a.Partition, a.AccountID, a.ResourceType)
+	}
+	if a.ResourceType == ResourceTypeAssumedRole {
+		return fmt.Sprintf("arn:%v:sts::%v:%v/%v/%v", a.Partition, a.AccountID, a.ResourceType, a.Name, a.SessionName)
+	}
+	return fmt.Sprintf("arn:%v:%v::%v:%v%v%v", a.Partition, a.Service, a.AccountID, a.ResourceType, a.Path, a.Name)
+}
+
+// isRole returns true for role ARNs and assumed-role sessions, which are matched by mapRoles
+func (a *ARN) isRole() bool {
+	return a.ResourceType == ResourceTypeRole || a.ResourceType == ResourceTypeAssumedRole
+}
+
+// RoleARN returns the IAM role ARN of an assumed-role session. The path of the role is not part of
+// a session ARN, so the returned ARN has none.
+func (a *ARN) RoleARN() *ARN {
+	return &ARN{
+		Partition:    a.Partition,
+		Service:      "iam",
+		AccountID:    a.AccountID,
+		ResourceType: ResourceTypeRole,
+		Path:         "/",
+		Name:         a.Name,
+	}
+}
+
+// WithoutPath returns a copy of the ARN with the IAM path removed
+func (a *ARN) WithoutPath() *ARN {
+	c := *a
+	c.Path = "/"
+	return &c
+}
+
+// CanonicalRoleARN returns a role ARN in the form the authenticator matches it, which ignores
+// the IAM path of roles. ARNs which are not valid role ARNs are returned unchanged.
+func CanonicalRoleARN(s string) string {
+	a, err := ParseARN(s)
+	if err != nil || a.ResourceType != ResourceTypeRole {
+		return s
+	}
+	return a.WithoutPath().String()
+}
+
+// roleARNEqual returns true if the role ARNs are the same, or only differ by path when ignorePath is set
+func roleARNEqual(a, b string, ignorePath bool) bool {
+	if a == b {
+		return true
+	}
+	return ignorePath && CanonicalRoleARN(a) == CanonicalRoleARN(b)
+}
+
+// ValidateRoleARN returns an error if s is not a valid IAM role ARN
+func ValidateRoleARN(s string) error {
+	return validateARN(s, ResourceTypeRole)
+}
+
+// ValidateUserARN returns an error if s is not a valid IAM user ARN, the root user of an account
+// is a user too
+func ValidateUserARN(s string) error {
+	if a, err := ParseARN(s); err == nil && a.ResourceType == ResourceTypeRoot {
+		return nil
+	}
+	return validateARN(s, ResourceTypeUser)
+}
+
+func validateARN(s, resourceType string) error {
+	a, err := ParseARN(s)
+	if err != nil {
+		return err
+	}
+
+	if a.ResourceType == ResourceTypeAssumedRole && resourceType == ResourceTypeRole {
+		return &ARNError{ARN: s, Reason: fmt.Sprintf("is an assumed-role session, map the role %v instead", a.RoleARN())}
+	}
+
+	if a.ResourceType != resourceType {
+		return &ARNError{ARN: s, Reason: fmt.Sprintf("is a %v ARN, expected a %v ARN", a.ResourceType, resourceType)}
+	}
+
+	return nil
+}
+
+// ValidateAccountID returns an error if s is not a 12 digit AWS account ID
+func ValidateAccountID(s string) error {
+	if !accountIDPattern.MatchString(s) {
+		return fmt.Errorf("%w %q: must be 12 digits", ErrInvalidAccountID, s)
+	}
+	return nil
+}
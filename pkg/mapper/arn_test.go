@@ -0,0 +1,194 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseARN(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	tests := []struct {
+		arn      string
+		expected ARN
+	}{
+		{
+			arn:      "arn:aws:iam::123456789012:role/MyRole",
+			expected: ARN{Partition: "aws", Service: "iam", AccountID: "123456789012", ResourceType: ResourceTypeRole, Path: "/", Name: "MyRole"},
+		},
+		{
+			arn:      "arn:aws:iam::123456789012:role/team/x/MyRole",
+			expected: ARN{Partition: "aws", Service: "iam", AccountID: "123456789012", ResourceType: ResourceTypeRole, Path: "/team/x/", Name: "MyRole"},
+		},
+		{
+			arn:      "arn:aws-cn:iam::123456789012:user/ops@example.com",
+			expected: ARN{Partition: "aws-cn", Service: "iam", AccountID: "123456789012", ResourceType: ResourceTypeUser, Path: "/", Name: "ops@example.com"},
+		},
+		{
+			arn:      "arn:aws-us-gov:iam::123456789012:role/eks-node",
+			expected: ARN{Partition: "aws-us-gov", Service: "iam", AccountID: "123456789012", ResourceType: ResourceTypeRole, Path: "/", Name: "eks-node"},
+		},
+		{
+			arn:      "arn:aws-cn:iam::123456789012:root",
+			expected: ARN{Partition: "aws-cn", Service: "iam", AccountID: "123456789012", ResourceType: ResourceTypeRoot, Path: "/"},
+		},
+		{
+			arn:      "arn:aws:sts::123456789012:assumed-role/Deploy/session@ci",
+			expected: ARN{Partition: "aws", Service: "sts", AccountID: "123456789012", ResourceType: ResourceTypeAssumedRole, Path: "/", Name: "Deploy", SessionName: "session@ci"},
+		},
+	}
+
+	for _, tc := range tests {
+		a, err := ParseARN(tc.arn)
+		g.Expect(err).NotTo(gomega.HaveOccurred(), tc.arn)
+		g.Expect(*a).To(gomega.Equal(tc.expected), tc.arn)
+		g.Expect(a.String()).To(gomega.Equal(tc.arn))
+	}
+}
+
+func TestParseARN_Invalid(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	tests := map[string]string{
+		"MyRole":                    "must have the form",
+		"arn:aws:iam::123456789012": "must have the form",
+		"arn:aws-eu:iam::123456789012:role/MyRole":  "unknown partition",
+		"arn:aws:s3::123456789012:role/MyRole":      "service must be iam or sts",
+		"arn:aws:iam:us-east-1:123456789012:role/x": "have no region",
+		"arn:aws:iam::12345678901:role/MyRole":      "must be 12 digits",
+		"arn:aws:iam::123456789012:role/":           "invalid role name",
+		"arn:aws:iam::123456789012:role":            "role name is missing",
+		"arn:aws:iam::123456789012:role/My Role":    "invalid role name",
+		"arn:aws:iam::123456789012:group/admins":    "unsupported iam resource type",
+		"arn:aws:iam::123456789012:root/admin":      "root user has no name or path",
+		"arn:aws:sts::123456789012:assumed-role/x":  "assumed-role/role-name/session-name",
+		"arn:aws:sts::123456789012:role/MyRole":     "unsupported sts resource type",
+	}
+
+	for arn, reason := range tests {
+		_, err := ParseARN(arn)
+		g.Expect(err).To(gomega.MatchError(ErrInvalidARN), arn)
+		g.Expect(err.Error()).To(gomega.ContainSubstring(reason), arn)
+	}
+}
+
+func TestValidateARN(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(ValidateRoleARN("arn:aws:iam::123456789012:role/team/MyRole")).To(gomega.Succeed())
+	g.Expect(ValidateUserARN("arn:aws:iam::123456789012:user/alice")).To(gomega.Succeed())
+	g.Expect(ValidateUserARN("arn:aws:iam::123456789012:root")).To(gomega.Succeed())
+
+	err := ValidateRoleARN("arn:aws:iam::123456789012:user/alice")
+	g.Expect(err).To(gomega.MatchError(ErrInvalidARN))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("is a user ARN, expected a role ARN"))
+
+	err = ValidateUserARN("arn:aws:iam::123456789012:role/MyRole")
+	g.Expect(err.Error()).To(gomega.ContainSubstring("is a role ARN, expected a user ARN"))
+
+	err = ValidateRoleARN("arn:aws:iam::123456789012:root")
+	g.Expect(err.Error()).To(gomega.ContainSubstring("is a root ARN, expected a role ARN"))
+
+	err = ValidateRoleARN("arn:aws:sts::123456789012:assumed-role/Deploy/session")
+	g.Expect(err).To(gomega.MatchError(ErrInvalidARN))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("map the role arn:aws:iam::123456789012:role/Deploy instead"))
+
+	g.Expect(ValidateAccountID("123456789012")).To(gomega.Succeed())
+	g.Expect(ValidateAccountID("1234")).To(gomega.MatchError(ErrInvalidAccountID))
+}
+
+func TestCanonicalRoleARN(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(CanonicalRoleARN("arn:aws:iam::123456789012:role/team/x/MyRole")).To(gomega.Equal("arn:aws:iam::123456789012:role/MyRole"))
+	g.Expect(CanonicalRoleARN("arn:aws-cn:iam::123456789012:role/MyRole")).To(gomega.Equal("arn:aws-cn:iam::123456789012:role/MyRole"))
+
+	// users are matched including their path, malformed ARNs are left alone
+	g.Expect(CanonicalRoleARN("arn:aws:iam::123456789012:user/team/alice")).To(gomega.Equal("arn:aws:iam::123456789012:user/team/alice"))
+	g.Expect(CanonicalRoleARN("arn:aws:iam::123:role/team/MyRole")).To(gomega.Equal("arn:aws:iam::123:role/team/MyRole"))
+
+	g.Expect(roleARNEqual("arn:aws:iam::123456789012:role/a/MyRole", "arn:aws:iam::123456789012:role/MyRole", true)).To(gomega.BeTrue())
+	g.Expect(roleARNEqual("arn:aws:iam::123456789012:role/a/MyRole", "arn:aws:iam::123456789012:role/MyRole", false)).To(gomega.BeFalse())
+	g.Expect(roleARNEqual("arn:aws:iam::123456789012:role/a/MyRole", "arn:aws:iam::123456789012:role/a/Other", true)).To(gomega.BeFalse())
+}
+
+func TestMapper_UpsertRejectsInvalidARN(t *testing.T) {
+	g := gomega.NewWithT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, false)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::123456789012:user/alice",
+		Username: "alice",
+	})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidARN))
+
+	err = mapper.Upsert(&MapperArguments{
+		MapUsers: true,
+		UserARN:  "arn:aws:iam::123456789012:usr/alice",
+		Username: "alice",
+	})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidARN))
+
+	err = mapper.Upsert(&MapperArguments{
+		MapAccounts: true,
+		AccountID:   "12345",
+	})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidAccountID))
+
+	err = mapper.UpsertMultiple([]*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:sts::123456789012:assumed-role/Deploy/session", "deploy", nil),
+	}, nil)
+	g.Expect(err).To(gomega.MatchError(ErrInvalidARN))
+
+	_, err = mapper.Apply(&AwsAuthData{
+		MapRoles: []*RolesAuthMap{NewRolesAuthMap("arn:aws:iam::123:role/ci", "ci", nil)},
+	}, ApplyOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidARN))
+	g.Expect(err.Error()).To(gomega.HavePrefix("mapRoles[0]: invalid ARN"))
+
+	g.Expect(client.Actions()).To(gomega.BeEmpty())
+}
+
+func TestMapper_RemoveAcceptsInvalidARN(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, false)
+	create_MockConfigMap(client)
+
+	err := mapper.UpsertMultiple([]*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/node-2", "node", nil),
+	}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// malformed entries which are already in the configmap can still be removed
+	auth, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	auth.MapRoles[1].RoleARN = "arn:aws:iam::000000000000:role/node 2"
+	g.Expect(UpdateAuthMap(client, auth, cm)).To(gomega.Succeed())
+
+	err = mapper.Remove(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node 2",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+}
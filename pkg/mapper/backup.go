@@ -0,0 +1,343 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BackupNamePrefix is the prefix of the names of the backup configmaps of the aws-auth configmap,
+	// followed by the backup ID. Backups of another configmap are named after it, e.g. aws-auth-staging-backup-.
+	BackupNamePrefix = AwsAuthName + backupNameInfix
+	// BackupLabel marks the configmaps which are backups of the aws-auth configmap
+	BackupLabel = "aws-auth.keikoproj.io/backup"
+	// BackupSourceLabel is the name of the configmap a backup was taken of, names longer than a label
+	// value are hashed. Backups without it were taken before it existed and belong to aws-auth.
+	BackupSourceLabel = "aws-auth.keikoproj.io/backup-source"
+	// BackupSourceAnnotation is the resourceVersion of the aws-auth configmap that was backed up
+	BackupSourceAnnotation = "aws-auth.keikoproj.io/backup-source-resource-version"
+
+	// DefaultBackupRetention is the number of backups kept when BackupOptions.Retain is zero
+	DefaultBackupRetention = 10
+
+	backupIDFormat  = "20060102-150405.000000000"
+	backupNameInfix = "-backup-"
+
+	// maxLabelValueLength is the maximum length of a label value
+	maxLabelValueLength = 63
+)
+
+// authDataKeys are the keys of the aws-auth configmap which are backed up and restored
+var authDataKeys = []string{"mapRoles", "mapUsers", "mapAccounts"}
+
+// BackupOptions configure the backups taken before every write of the aws-auth configmap
+type BackupOptions struct {
+	// Disabled turns off backups
+	Disabled bool
+	// Retain is the number of backups to keep, the oldest are deleted after a write unless an entry of
+	// the change history still needs them for a rollback. Zero keeps DefaultBackupRetention backups, a
+	// negative value keeps all of them.
+	Retain int
+}
+
+// Backup is a snapshot of the mapRoles, mapUsers and mapAccounts of the aws-auth configmap
+type Backup struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	// SourceResourceVersion is the resourceVersion of the aws-auth configmap that was backed up
+	SourceResourceVersion string      `json:"sourceResourceVersion"`
+	AuthData              AwsAuthData `json:"-"`
+
+	data map[string]string
+}
+
+// RestoreOptions configure a restore
+type RestoreOptions struct {
+	// Force allows restoring a backup without role mappings over a configmap which has some,
+	// which would lock out all nodes
+	Force  bool
+	DryRun DryRunMode
+}
+
+// ListBackups returns the backups of the aws-auth configmap, newest first. Backups of other configmaps
+// in the same namespace are not listed.
+func (b *AuthMapper) ListBackups() ([]*Backup, error) {
+	return b.ListBackupsWithContext(context.Background())
+}
+
+// ListBackupsWithContext is like ListBackups but uses the given context for the API calls
+func (b *AuthMapper) ListBackupsWithContext(ctx context.Context) ([]*Backup, error) {
+	if err := b.clusterRequired("backups"); err != nil {
+		return nil, err
+	}
+
+	selectors := []string{BackupLabel + "=true," + BackupSourceLabel + "=" + b.backupSource()}
+	if b.configMapRef().name == AwsAuthName {
+		selectors = append(selectors, BackupLabel+"=true,!"+BackupSourceLabel)
+	}
+
+	var backups []*Backup
+	for _, selector := range selectors {
+		list, err := b.KubernetesClient.CoreV1().ConfigMaps(b.configMapRef().namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: selector,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range list.Items {
+			backup, err := newBackup(&list.Items[i], b.backupNamePrefix())
+			if err != nil {
+				return nil, err
+			}
+			backups = append(backups, backup)
+		}
+	}
+
+	// IDs are timestamps of a fixed width, they sort in the order the backups were taken
+	slices.SortFunc(backups, func(a, b *Backup) int {
+		return strings.Compare(b.ID, a.ID)
+	})
+	return backups, nil
+}
+
+// GetBackup returns the backup with the given ID
+func (b *AuthMapper) GetBackup(id string) (*Backup, error) {
+	return b.GetBackupWithContext(context.Background(), id)
+}
+
+// GetBackupWithContext is like GetBackup but uses the given context for the API calls
+func (b *AuthMapper) GetBackupWithContext(ctx context.Context, id string) (*Backup, error) {
+	if err := b.clusterRequired("backups"); err != nil {
+		return nil, err
+	}
+
+	cm, err := b.KubernetesClient.CoreV1().ConfigMaps(b.configMapRef().namespace).Get(ctx, b.backupNamePrefix()+id, metav1.GetOptions{})
+	if errors.IsNotFound(err) || (err == nil && cm.Labels[BackupLabel] != "true") {
+		return nil, fmt.Errorf("%w: %v", ErrBackupNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !b.ownsBackup(cm) {
+		return nil, fmt.Errorf("%w: %v is a backup of %v, not of %v", ErrBackupNotFound, id, cm.Labels[BackupSourceLabel], b.configMapRef().name)
+	}
+	return newBackup(cm, b.backupNamePrefix())
+}
+
+// backupNamePrefix is the prefix of the names of the backups of the configmap, followed by the backup ID
+func (b *AuthMapper) backupNamePrefix() string {
+	return b.configMapRef().name + backupNameInfix
+}
+
+// backupSource is the value of the BackupSourceLabel of the backups of the configmap
+func (b *AuthMapper) backupSource() string {
+	name := b.configMapRef().name
+	if len(name) <= maxLabelValueLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])[:maxLabelValueLength]
+}
+
+// ownsBackup returns true if cm is a backup of the configmap
+func (b *AuthMapper) ownsBackup(cm *v1.ConfigMap) bool {
+	source, ok := cm.Labels[BackupSourceLabel]
+	if !ok {
+		return b.configMapRef().name == AwsAuthName
+	}
+	return source == b.backupSource()
+}
+
+// Restore replaces the mapRoles, mapUsers and mapAccounts of the aws-auth configmap with those of
+// the backup with the given ID, and returns the changes made. The current data is backed up first,
+// so a restore can be undone by restoring that backup. Restoring a backup without role mappings
+// over a configmap which has some fails with ErrUnsafeRestore unless opts.Force is set.
+func (b *AuthMapper) Restore(id string, opts RestoreOptions) (*Plan, error) {
+	return b.RestoreWithContext(context.Background(), id, opts)
+}
+
+// RestoreWithContext is like Restore but uses the given context for the API calls
+func (b *AuthMapper) RestoreWithContext(ctx context.Context, id string, opts RestoreOptions) (*Plan, error) {
+	if opts.DryRun != DryRunNone && opts.DryRun != DryRunClient && opts.DryRun != DryRunServer {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDryRun, opts.DryRun)
+	}
+
+	backup, err := b.GetBackupWithContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	fn := b.restoreMutation(backup, opts.Force, plan)
+
+	if opts.DryRun != DryRunNone {
+		return b.planConfigMap(ctx, OperationRestore, fn, opts.DryRun)
+	}
+
+	if err := b.mutateConfigMap(ctx, OperationRestore, fn); err != nil {
+		return nil, err
+	}
+	b.logf("restored backup %v\n", id)
+	return plan, nil
+}
+
+// restoreMutation returns the mutation which replaces the data of the configmap with the data of the
+// backup, the changes are stored in plan
+func (b *AuthMapper) restoreMutation(backup *Backup, force bool, plan *Plan) configMapMutation {
+	return func(authData *AwsAuthData, cm *v1.ConfigMap) (bool, error) {
+		if len(backup.AuthData.MapRoles) == 0 && len(authData.MapRoles) != 0 && !force {
+			return false, fmt.Errorf("%w: backup %v has no role mappings, restoring it would remove all %d role mappings", ErrUnsafeRestore, backup.ID, len(authData.MapRoles))
+		}
+
+		*plan = *DiffAuthData(authData, &backup.AuthData)
+		if plan.IsEmpty() {
+			b.logf("aws-auth already matches backup %v, configmap is not changed\n", backup.ID)
+			return false, nil
+		}
+
+		// the documents of the backup are restored as they were, including their comments
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		for _, key := range authDataKeys {
+			if v, ok := backup.data[key]; ok {
+				cm.Data[key] = v
+			} else {
+				delete(cm.Data, key)
+			}
+		}
+		*authData = backup.AuthData
+		return true, nil
+	}
+}
+
+// newBackup returns the Backup of a backup configmap whose name is prefix followed by the backup ID
+func newBackup(cm *v1.ConfigMap, prefix string) (*Backup, error) {
+	authData, err := decodeAuthData(cm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse backup %v: %w", cm.Name, err)
+	}
+
+	data := make(map[string]string)
+	for _, key := range authDataKeys {
+		if v, ok := cm.Data[key]; ok {
+			data[key] = v
+		}
+	}
+
+	return &Backup{
+		ID:                    strings.TrimPrefix(cm.Name, prefix),
+		Name:                  cm.Name,
+		Created:               cm.CreationTimestamp.Time,
+		SourceResourceVersion: cm.Annotations[BackupSourceAnnotation],
+		AuthData:              authData,
+		data:                  data,
+	}, nil
+}
+
+// createBackup saves the mapRoles, mapUsers and mapAccounts of cm to a new backup configmap. Nothing is
+// saved when backups are disabled, the mapper operates on a file or cm has no data, the returned configmap is nil then.
+func (b *AuthMapper) createBackup(ctx context.Context, cm *v1.ConfigMap) (*v1.ConfigMap, error) {
+	if b.Backups.Disabled || b.KubernetesClient == nil {
+		return nil, nil
+	}
+
+	data := make(map[string]string)
+	for _, key := range authDataKeys {
+		if v, ok := cm.Data[key]; ok && strings.TrimSpace(v) != "" {
+			data[key] = v
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	for {
+		backup := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        b.backupNamePrefix() + strings.ReplaceAll(time.Now().UTC().Format(backupIDFormat), ".", "-"),
+				Namespace:   b.configMapRef().namespace,
+				Labels:      map[string]string{BackupLabel: "true", BackupSourceLabel: b.backupSource()},
+				Annotations: map[string]string{BackupSourceAnnotation: cm.ResourceVersion},
+			},
+			Data: data,
+		}
+
+		created, err := b.KubernetesClient.CoreV1().ConfigMaps(b.configMapRef().namespace).Create(ctx, backup, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up aws-auth: %w", err)
+		}
+		return created, nil
+	}
+}
+
+// deleteBackup deletes a backup which is no longer needed, failures are only logged
+func (b *AuthMapper) deleteBackup(ctx context.Context, name string) {
+	err := b.KubernetesClient.CoreV1().ConfigMaps(b.configMapRef().namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		b.logf("failed to delete backup %v: %v\n", name, err)
+	}
+}
+
+// pruneBackups deletes the oldest backups of the configmap beyond the retention, except those which the
+// change history of cm still rolls back to. Failures are only logged.
+func (b *AuthMapper) pruneBackups(ctx context.Context, cm *v1.ConfigMap) {
+	retain := b.Backups.Retain
+	if retain == 0 {
+		retain = DefaultBackupRetention
+	}
+	if b.Backups.Disabled || b.KubernetesClient == nil || retain < 0 {
+		return
+	}
+
+	entries, err := decodeHistory(cm)
+	if err != nil {
+		b.logf("failed to prune backups: %v\n", err)
+		return
+	}
+	referenced := make(map[string]bool)
+	for _, entry := range entries {
+		referenced[entry.Backup] = true
+	}
+
+	backups, err := b.ListBackupsWithContext(ctx)
+	if err != nil {
+		b.logf("failed to list backups: %v\n", err)
+		return
+	}
+
+	for _, backup := range backups[min(retain, len(backups)):] {
+		if !referenced[backup.ID] {
+			b.deleteBackup(ctx, backup.Name)
+		}
+	}
+}
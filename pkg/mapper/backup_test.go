@@ -0,0 +1,276 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestMapper_BackupBeforeWrite(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	err = mapper.RemoveByUsername(&MapperArguments{Username: "admin"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	backups, err := mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backups).To(gomega.HaveLen(1))
+	g.Expect(backups[0].Name).To(gomega.Equal(BackupNamePrefix + backups[0].ID))
+	g.Expect(backups[0].AuthData.MapUsers).To(gomega.HaveLen(1))
+
+	// the documents are saved as they were
+	backup, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Get(context.Background(), backups[0].Name, metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backup.Data).To(gomega.Equal(cm.Data))
+	g.Expect(backup.Labels).To(gomega.HaveKeyWithValue(BackupLabel, "true"))
+
+	// no backup is taken when nothing changes
+	err = mapper.RemoveByUsername(&MapperArguments{Username: "admin", Force: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	backups, err = mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backups).To(gomega.HaveLen(1))
+}
+
+func TestMapper_BackupRetention(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	mapper.Backups.Retain = 2
+	create_MockConfigMap(client)
+
+	for i := 0; i < HistoryLength+2; i++ {
+		err := mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: fmt.Sprintf("%012d", i+1)})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+
+	// the backups of the two changes which fell out of the history are deleted
+	backups, err := mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backups).To(gomega.HaveLen(HistoryLength))
+	g.Expect(backups[0].ID > backups[1].ID).To(gomega.BeTrue())
+	g.Expect(backups[0].AuthData.MapAccounts).To(gomega.HaveLen(HistoryLength + 1))
+	g.Expect(backups[HistoryLength-1].AuthData.MapAccounts).To(gomega.HaveLen(2))
+
+	// backups which no change of the history references are deleted beyond the retention
+	auth, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	delete(cm.Annotations, HistoryAnnotation)
+	g.Expect(UpdateAuthMap(client, auth, cm)).To(gomega.Succeed())
+	g.Expect(mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "999999999999"})).To(gomega.Succeed())
+
+	backups, err = mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backups).To(gomega.HaveLen(2))
+	g.Expect(backups[0].AuthData.MapAccounts).To(gomega.HaveLen(HistoryLength + 2))
+}
+
+func TestMapper_BackupsOfConfigMapsInSameNamespace(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	create_MockConfigMap(client)
+	mapper := New(client, true)
+	mapper.Backups.Retain = 1
+	staging := New(client, true, WithConfigMapName("aws-auth-staging"))
+	staging.Backups.Retain = 1
+
+	for _, account := range []string{"111111111111", "222222222222"} {
+		g.Expect(staging.Upsert(&MapperArguments{MapAccounts: true, AccountID: account})).To(gomega.Succeed())
+	}
+	g.Expect(mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "333333333333"})).To(gomega.Succeed())
+
+	// the retention of one configmap does not delete the backups of the other
+	backups, err := mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backups).To(gomega.HaveLen(1))
+	g.Expect(backups[0].Name).To(gomega.Equal(BackupNamePrefix + backups[0].ID))
+	g.Expect(backups[0].AuthData.MapRoles).NotTo(gomega.BeEmpty())
+
+	stagingBackups, err := staging.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(stagingBackups).To(gomega.HaveLen(1))
+	g.Expect(stagingBackups[0].Name).To(gomega.Equal("aws-auth-staging-backup-" + stagingBackups[0].ID))
+	g.Expect(stagingBackups[0].AuthData.MapAccounts).To(gomega.Equal([]string{"111111111111"}))
+
+	backup, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Get(context.Background(), stagingBackups[0].Name, metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backup.Labels).To(gomega.HaveKeyWithValue(BackupSourceLabel, "aws-auth-staging"))
+
+	_, err = mapper.Restore(stagingBackups[0].ID, RestoreOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrBackupNotFound))
+
+	// a backup is only restored into the configmap it was taken of
+	foreign := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BackupNamePrefix + "20200101-000000-000000000",
+			Namespace: AwsAuthNamespace,
+			Labels:    map[string]string{BackupLabel: "true", BackupSourceLabel: "aws-auth-staging"},
+		},
+		Data: map[string]string{"mapAccounts": "- \"444444444444\"\n"},
+	}
+	_, err = client.CoreV1().ConfigMaps(AwsAuthNamespace).Create(context.Background(), foreign, metav1.CreateOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, err = mapper.Restore("20200101-000000-000000000", RestoreOptions{Force: true})
+	g.Expect(err).To(gomega.MatchError("backup not found: 20200101-000000-000000000 is a backup of aws-auth-staging, not of aws-auth"))
+}
+
+func TestMapper_BackupDisabled(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	mapper.Backups.Disabled = true
+	create_MockConfigMap(client)
+
+	err := mapper.RemoveByUsername(&MapperArguments{Username: "admin"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	backups, err := mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backups).To(gomega.BeEmpty())
+}
+
+func TestMapper_BackupDeletedOnFailedUpdate(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("admission webhook denied the request")
+	})
+
+	err := mapper.RemoveByUsername(&MapperArguments{Username: "admin"})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	backups, err := mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backups).To(gomega.BeEmpty())
+}
+
+func TestMapper_Restore(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	_, original, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	err = mapper.RemoveByUsername(&MapperArguments{Username: "admin"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	backups, err := mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	id := backups[0].ID
+
+	updates := count_Updates(client)
+	plan, err := mapper.Restore(id, RestoreOptions{DryRun: DryRunClient})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(1))
+	g.Expect(plan.Changes[0].Action).To(gomega.Equal(ChangeAdd))
+	g.Expect(count_Updates(client)).To(gomega.Equal(updates))
+
+	plan, err = mapper.Restore(id, RestoreOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(1))
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data).To(gomega.Equal(original.Data))
+
+	// the data replaced by the restore was backed up too
+	backups, err = mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backups).To(gomega.HaveLen(2))
+	g.Expect(backups[0].AuthData.MapUsers).To(gomega.BeEmpty())
+
+	// restoring again changes nothing
+	plan, err = mapper.Restore(id, RestoreOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.IsEmpty()).To(gomega.BeTrue())
+}
+
+func TestMapper_RestoreUnsafe(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+
+	backup := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BackupNamePrefix + "20200101-000000-000000000",
+			Namespace: AwsAuthNamespace,
+			Labels:    map[string]string{BackupLabel: "true"},
+		},
+		Data: map[string]string{"mapAccounts": "- \"111111111111\"\n"},
+	}
+	_, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Create(context.Background(), backup, metav1.CreateOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	create_MockConfigMap(client)
+
+	_, err = mapper.Restore("20200101-000000-000000000", RestoreOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrUnsafeRestore))
+
+	_, err = mapper.Restore("20200101-000000-000000000", RestoreOptions{Force: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.BeEmpty())
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"111111111111"}))
+}
+
+func TestMapper_RestoreNotFound(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	_, err := mapper.Restore("20200101-000000-000000000", RestoreOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrBackupNotFound))
+
+	// only configmaps labelled as backups can be restored
+	other := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: BackupNamePrefix + "other", Namespace: AwsAuthNamespace}}
+	_, err = client.CoreV1().ConfigMaps(AwsAuthNamespace).Create(context.Background(), other, metav1.CreateOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, err = mapper.GetBackup("other")
+	g.Expect(err).To(gomega.MatchError(ErrBackupNotFound))
+
+	_, err = mapper.Restore("20200101-000000-000000000", RestoreOptions{DryRun: "yes"})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidDryRun))
+}
@@ -17,27 +17,87 @@ package mapper
 
 import (
 	"context"
+	"time"
 
-	yaml "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 )
 
+// AwsAuthNamespace and AwsAuthName locate the aws-auth configmap unless WithNamespace or
+// WithConfigMapName is given
 const (
 	AwsAuthNamespace = "kube-system"
 	AwsAuthName      = "aws-auth"
 )
 
-// ReadAuthMap reads the aws-auth config map and returns an AwsAuthData and the actually ConfigMap objects
-func ReadAuthMap(k kubernetes.Interface) (AwsAuthData, *v1.ConfigMap, error) {
+// configMapRef is the namespace and name of the aws-auth configmap
+type configMapRef struct {
+	namespace string
+	name      string
+}
+
+func (r configMapRef) String() string {
+	return r.namespace + "/" + r.name
+}
+
+// WithNamespace sets the namespace of the aws-auth configmap, backups are kept in the same namespace
+func WithNamespace(namespace string) Option {
+	return func(b *AuthMapper) {
+		b.configMap.namespace = namespace
+	}
+}
+
+// WithConfigMapName sets the name of the aws-auth configmap
+func WithConfigMapName(name string) Option {
+	return func(b *AuthMapper) {
+		b.configMap.name = name
+	}
+}
+
+// configMapRef returns the configmap of the mapper, AwsAuthNamespace and AwsAuthName unless they were set
+func (b *AuthMapper) configMapRef() configMapRef {
+	ref := b.configMap
+	if ref.namespace == "" {
+		ref.namespace = AwsAuthNamespace
+	}
+	if ref.name == "" {
+		ref.name = AwsAuthName
+	}
+	return ref
+}
+
+// newConfigMapRef returns the configmap set by the WithNamespace and WithConfigMapName options
+func newConfigMapRef(opts []Option) configMapRef {
+	b := &AuthMapper{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b.configMapRef()
+}
+
+// ReadAuthMap reads the aws-auth config map and returns an AwsAuthData and the actually ConfigMap objects.
+// The configmap is created when it does not exist, WithNamespace and WithConfigMapName locate it elsewhere.
+func ReadAuthMap(k kubernetes.Interface, opts ...Option) (AwsAuthData, *v1.ConfigMap, error) {
+	return ReadAuthMapWithContext(context.Background(), k, opts...)
+}
+
+// ReadAuthMapWithContext is like ReadAuthMap but uses the given context for the API calls
+func ReadAuthMapWithContext(ctx context.Context, k kubernetes.Interface, opts ...Option) (AwsAuthData, *v1.ConfigMap, error) {
 	var authData AwsAuthData
+	ref := newConfigMapRef(opts)
 
-	cm, err := k.CoreV1().ConfigMaps(AwsAuthNamespace).Get(context.Background(), AwsAuthName, metav1.GetOptions{})
+	cm, err := k.CoreV1().ConfigMaps(ref.namespace).Get(ctx, ref.name, metav1.GetOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
-			cm, err = CreateAuthMap(k)
+			cm, err = CreateAuthMapWithContext(ctx, k, opts...)
+			if errors.IsAlreadyExists(err) {
+				// Another writer created the configmap since it was read
+				cm, err = k.CoreV1().ConfigMaps(ref.namespace).Get(ctx, ref.name, metav1.GetOptions{})
+			}
 			if err != nil {
 				return authData, cm, err
 			}
@@ -46,55 +106,188 @@ func ReadAuthMap(k kubernetes.Interface) (AwsAuthData, *v1.ConfigMap, error) {
 		}
 	}
 
-	err = yaml.Unmarshal([]byte(cm.Data["mapRoles"]), &authData.MapRoles)
+	authData, err = decodeAuthData(cm)
+	return authData, cm, err
+}
+
+// decodeAuthData parses the mapRoles, mapUsers and mapAccounts documents of a configmap
+func decodeAuthData(cm *v1.ConfigMap) (AwsAuthData, error) {
+	var authData AwsAuthData
+
+	err := yaml.Unmarshal([]byte(cm.Data["mapRoles"]), &authData.MapRoles)
 	if err != nil {
-		return authData, cm, err
+		return authData, err
 	}
 
 	err = yaml.Unmarshal([]byte(cm.Data["mapUsers"]), &authData.MapUsers)
 	if err != nil {
-		return authData, cm, err
+		return authData, err
+	}
+
+	err = yaml.Unmarshal([]byte(cm.Data["mapAccounts"]), &authData.MapAccounts)
+	if err != nil {
+		return authData, err
 	}
 
-	return authData, cm, nil
+	return authData, nil
+}
+
+// authMapMutation modifies the given AwsAuthData in place and reports whether the configmap needs to be updated
+type authMapMutation func(authData *AwsAuthData) (bool, error)
+
+// configMapMutation is like authMapMutation but can also modify the metadata of the configmap
+type configMapMutation func(authData *AwsAuthData, cm *v1.ConfigMap) (bool, error)
+
+// mutateAuthMap reads the aws-auth configmap, applies fn and writes the result back. The update is made
+// against the resourceVersion that was read, if another writer changed the configmap in the meantime the
+// update is rejected with a conflict, and fn is applied again on freshly read data.
+func (b *AuthMapper) mutateAuthMap(ctx context.Context, op OperationType, fn authMapMutation) error {
+	return b.mutateConfigMap(ctx, op, func(authData *AwsAuthData, _ *v1.ConfigMap) (bool, error) {
+		return fn(authData)
+	})
+}
+
+// mutateConfigMap is like mutateAuthMap but passes the configmap that was read to fn. Unless backups are
+// disabled, the data that was read is saved to a backup configmap before it is replaced, and every write is
+// recorded in the change history of the configmap.
+func (b *AuthMapper) mutateConfigMap(ctx context.Context, op OperationType, fn configMapMutation) error {
+	fn = b.enforcePolicies(b.guardProtection(op, fn))
+
+	return retryOnConflict(ctx, DefaultConflictRetryBackoff, func() error {
+		configMap, revision, err := b.store().Load(ctx)
+		if err != nil {
+			return err
+		}
+
+		authData, err := decodeAuthData(configMap)
+		if err != nil {
+			return err
+		}
+
+		original := configMap.DeepCopy()
+		updated, err := fn(&authData, configMap)
+		if err != nil || !updated {
+			return err
+		}
+
+		backup, err := b.createBackup(ctx, original)
+		if err != nil {
+			return err
+		}
+
+		if err := encodeAuthData(authData, configMap); err != nil {
+			return err
+		}
+
+		// the history is recorded from the encoded data, so its hashes match what is written
+		if err := b.recordHistory(ctx, op, original, configMap, backup); err != nil {
+			return err
+		}
+
+		_, err = b.store().Save(ctx, configMap, revision, false)
+		if err != nil {
+			// the backup would be a copy of data that was not replaced
+			if backup != nil {
+				b.deleteBackup(ctx, backup.Name)
+			}
+			return err
+		}
+
+		b.pruneBackups(ctx, configMap)
+		return nil
+	})
 }
 
-func CreateAuthMap(k kubernetes.Interface) (*v1.ConfigMap, error) {
-	configMapObject := &v1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      AwsAuthName,
-			Namespace: AwsAuthNamespace,
-		},
+// retryOnConflict is like retry.RetryOnConflict of client-go but stops waiting between attempts when
+// ctx is done. When the backoff is exhausted the last conflict is returned.
+func retryOnConflict(ctx context.Context, backoff wait.Backoff, fn func() error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := fn()
+		if !errors.IsConflict(err) || backoff.Steps <= 1 {
+			return err
+		}
+
+		timer := time.NewTimer(backoff.Step())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
 	}
-	configMap, err := k.CoreV1().ConfigMaps(AwsAuthNamespace).Create(context.Background(), configMapObject, metav1.CreateOptions{})
+}
+
+// CreateAuthMap creates an empty aws-auth configmap, WithNamespace and WithConfigMapName create it elsewhere
+func CreateAuthMap(k kubernetes.Interface, opts ...Option) (*v1.ConfigMap, error) {
+	return CreateAuthMapWithContext(context.Background(), k, opts...)
+}
+
+// CreateAuthMapWithContext is like CreateAuthMap but uses the given context for the API call
+func CreateAuthMapWithContext(ctx context.Context, k kubernetes.Interface, opts ...Option) (*v1.ConfigMap, error) {
+	ref := newConfigMapRef(opts)
+	configMapObject := newAuthConfigMap(ref)
+	configMap, err := k.CoreV1().ConfigMaps(ref.namespace).Create(ctx, configMapObject, metav1.CreateOptions{})
 	if err != nil {
 		return configMap, err
 	}
 	return configMap, nil
 }
 
-// UpdateAuthMap updates a given ConfigMap
-func UpdateAuthMap(k kubernetes.Interface, authData AwsAuthData, cm *v1.ConfigMap) error {
+// UpdateAuthMap updates the mapRoles, mapUsers and mapAccounts keys of a given ConfigMap.
+// The existing documents are edited in place, so comments, ordering, formatting and unknown
+// fields of entries which did not change are preserved. WithNamespace selects the namespace of the
+// configmap, which must match the one it was read from.
+func UpdateAuthMap(k kubernetes.Interface, authData AwsAuthData, cm *v1.ConfigMap, opts ...Option) error {
+	return UpdateAuthMapWithContext(context.Background(), k, authData, cm, opts...)
+}
 
-	mapRoles, err := yaml.Marshal(authData.MapRoles)
-	if err != nil {
+// UpdateAuthMapWithContext is like UpdateAuthMap but uses the given context for the API call
+func UpdateAuthMapWithContext(ctx context.Context, k kubernetes.Interface, authData AwsAuthData, cm *v1.ConfigMap, opts ...Option) error {
+	if err := encodeAuthData(authData, cm); err != nil {
 		return err
 	}
 
-	mapUsers, err := yaml.Marshal(authData.MapUsers)
+	_, err := k.CoreV1().ConfigMaps(newConfigMapRef(opts).namespace).Update(ctx, cm, metav1.UpdateOptions{})
 	if err != nil {
 		return err
 	}
 
-	cm.Data = map[string]string{
-		"mapRoles": string(mapRoles),
-		"mapUsers": string(mapUsers),
+	return nil
+}
+
+// encodeAuthData writes the AwsAuthData into the mapRoles, mapUsers and mapAccounts documents of a configmap
+func encodeAuthData(authData AwsAuthData, cm *v1.ConfigMap) error {
+	mapRoles, err := editDocument(cm.Data["mapRoles"], roleEntries(authData.MapRoles))
+	if err != nil {
+		return err
 	}
 
-	_, err = k.CoreV1().ConfigMaps(AwsAuthNamespace).Update(context.Background(), cm, metav1.UpdateOptions{})
+	mapUsers, err := editDocument(cm.Data["mapUsers"], userEntries(authData.MapUsers))
 	if err != nil {
 		return err
 	}
 
+	// Only the keys owned by this library are written, any other data, labels or
+	// annotations on the configmap are left untouched
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data["mapRoles"] = mapRoles
+	cm.Data["mapUsers"] = mapUsers
+
+	if len(authData.MapAccounts) > 0 {
+		mapAccounts, err := editDocument(cm.Data["mapAccounts"], accountEntries(authData.MapAccounts))
+		if err != nil {
+			return err
+		}
+		cm.Data["mapAccounts"] = mapAccounts
+	} else {
+		delete(cm.Data, "mapAccounts")
+	}
+
 	return nil
 }
@@ -18,21 +18,28 @@ package mapper
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/onsi/gomega"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func create_MockConfigMap(client kubernetes.Interface) {
-	role := NewRolesAuthMap("arn:aws:iam::00000000000:role/node-1",
+	role := NewRolesAuthMap("arn:aws:iam::000000000000:role/node-1",
 		"system:node:{{EC2PrivateDNSName}}",
 		[]string{"system:bootstrappers", "system:nodes"})
 
-	user := NewUsersAuthMap("arn:aws:iam::00000000000:user/user-1",
+	user := NewUsersAuthMap("arn:aws:iam::000000000000:user/user-1",
 		"admin",
 		[]string{"system:masters"})
 
@@ -66,6 +73,92 @@ func create_MockMalformedConfigMap(client kubernetes.Interface) {
 
 }
 
+func create_MockConfigMapWithAccounts(client kubernetes.Interface) {
+	role := NewRolesAuthMap("arn:aws:iam::000000000000:role/node-1",
+		"system:node:{{EC2PrivateDNSName}}",
+		[]string{"system:bootstrappers", "system:nodes"})
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AwsAuthName,
+			Namespace: AwsAuthNamespace,
+		},
+		Data: map[string]string{
+			"mapRoles":    role.String(),
+			"mapAccounts": "- \"111111111111\"\n- \"222222222222\"\n",
+		},
+	}
+	_, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func create_MockConfigMapWithForeignData(client kubernetes.Interface) {
+	role := NewRolesAuthMap("arn:aws:iam::000000000000:role/node-1",
+		"system:node:{{EC2PrivateDNSName}}",
+		[]string{"system:bootstrappers", "system:nodes"})
+
+	user := NewUsersAuthMap("arn:aws:iam::000000000000:user/user-1",
+		"admin",
+		[]string{"system:masters"})
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AwsAuthName,
+			Namespace: AwsAuthNamespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "some-other-tool",
+			},
+			Annotations: map[string]string{
+				"example.com/owner": "platform-team",
+			},
+		},
+		Data: map[string]string{
+			"mapRoles":    role.String(),
+			"mapUsers":    user.String(),
+			"otherTool":   "  keep: me\n# exactly as written\n",
+			"clusterName": "my-cluster",
+		},
+		BinaryData: map[string][]byte{
+			"blob": {0x00, 0x01, 0x02, 0xff},
+		},
+	}
+	_, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func expect_ForeignDataPreserved(g *gomega.WithT, client kubernetes.Interface) {
+	cm, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Get(context.Background(), AwsAuthName, metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data).To(gomega.HaveKeyWithValue("otherTool", "  keep: me\n# exactly as written\n"))
+	g.Expect(cm.Data).To(gomega.HaveKeyWithValue("clusterName", "my-cluster"))
+	g.Expect(cm.Labels).To(gomega.HaveKeyWithValue("app.kubernetes.io/managed-by", "some-other-tool"))
+	g.Expect(cm.Annotations).To(gomega.HaveKeyWithValue("example.com/owner", "platform-team"))
+	g.Expect(cm.BinaryData).To(gomega.HaveKeyWithValue("blob", []byte{0x00, 0x01, 0x02, 0xff}))
+}
+
+// add_ResourceVersionReactor makes the fake clientset reject updates made against a stale
+// resourceVersion with a conflict, the same way the API server does
+func add_ResourceVersionReactor(client *fake.Clientset) {
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		cm := action.(k8stesting.UpdateAction).GetObject().(*v1.ConfigMap)
+		gvr := v1.SchemeGroupVersion.WithResource("configmaps")
+
+		existing, err := client.Tracker().Get(gvr, cm.Namespace, cm.Name)
+		if err != nil {
+			return true, nil, err
+		}
+
+		if existing.(*v1.ConfigMap).ResourceVersion != cm.ResourceVersion {
+			return true, nil, apierrors.NewConflict(gvr.GroupResource(), cm.Name, fmt.Errorf("the object has been modified"))
+		}
+
+		version, _ := strconv.Atoi(cm.ResourceVersion)
+		updated := cm.DeepCopy()
+		updated.ResourceVersion = strconv.Itoa(version + 1)
+		return true, updated, client.Tracker().Update(gvr, updated, cm.Namespace)
+	})
+}
+
 func TestConfigMaps_Update(t *testing.T) {
 	g := gomega.NewWithT(t)
 	gomega.RegisterTestingT(t)
@@ -75,10 +168,10 @@ func TestConfigMaps_Update(t *testing.T) {
 	auth, cm, err := ReadAuthMap(client)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 
-	role := NewRolesAuthMap("arn:aws:iam::00000000000:role/node-2",
+	role := NewRolesAuthMap("arn:aws:iam::000000000000:role/node-2",
 		"system:node:{{EC2PrivateDNSName}}",
 		[]string{"system:bootstrappers", "system:nodes"})
-	user := NewUsersAuthMap("arn:aws:iam::00000000000:user/user-2",
+	user := NewUsersAuthMap("arn:aws:iam::000000000000:user/user-2",
 		"ops-user",
 		[]string{"system:masters"})
 
@@ -105,12 +198,12 @@ func TestConfigMaps_Read(t *testing.T) {
 	auth, _, err := ReadAuthMap(client)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("system:node:{{EC2PrivateDNSName}}"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:bootstrappers", "system:nodes"}))
 
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("admin"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.Equal([]string{"system:masters"}))
 }
@@ -137,3 +230,311 @@ func TestCreateAuthMap_AlreadyExists(t *testing.T) {
 	_, err = CreateAuthMap(client)
 	g.Expect(err).To(gomega.HaveOccurred())
 }
+
+func TestConfigMaps_CustomConfigMap(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	create_MockConfigMap(client)
+	opts := []Option{WithNamespace("auth"), WithConfigMapName("aws-auth-staging")}
+
+	auth, cm, err := ReadAuthMap(client, opts...)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.BeEmpty())
+	g.Expect(cm.Namespace).To(gomega.Equal("auth"))
+	g.Expect(cm.Name).To(gomega.Equal("aws-auth-staging"))
+
+	auth.MapAccounts = []string{"111111111111"}
+	g.Expect(UpdateAuthMap(client, auth, cm, opts...)).To(gomega.Succeed())
+
+	auth, _, err = ReadAuthMap(client, opts...)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"111111111111"}))
+
+	_, err = CreateAuthMap(client, opts...)
+	g.Expect(apierrors.IsAlreadyExists(err)).To(gomega.BeTrue())
+
+	// the default configmap is untouched
+	auth, _, err = ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(auth.MapAccounts).To(gomega.BeEmpty())
+}
+
+func TestMapper_CustomConfigMap(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	create_MockConfigMap(client)
+	mapper := New(client, true, WithNamespace("auth"), WithConfigMapName("aws-auth-staging"))
+
+	err := mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	err = mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "222222222222"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	cm, err := client.CoreV1().ConfigMaps("auth").Get(context.Background(), "aws-auth-staging", metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data["mapAccounts"]).To(gomega.ContainSubstring("222222222222"))
+
+	// backups are kept next to the configmap
+	backups, err := mapper.ListBackups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(backups).To(gomega.HaveLen(1))
+	_, err = client.CoreV1().ConfigMaps("auth").Get(context.Background(), backups[0].Name, metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	report, err := mapper.Lint()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	report.Findings = []Finding{{Rule: LintEmptyGroups, Severity: SeverityWarning, Entry: "mapRoles[0]"}}
+	g.Expect(report.SARIF().Runs[0].Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName).To(gomega.Equal("auth/aws-auth-staging/mapRoles[0]"))
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapAccounts).To(gomega.BeEmpty())
+}
+
+func TestConfigMaps_ReadAccounts(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	create_MockConfigMapWithAccounts(client)
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"111111111111", "222222222222"}))
+}
+
+func TestConfigMaps_UpdatePreservesAccounts(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	create_MockConfigMapWithAccounts(client)
+
+	auth, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth.MapRoles = append(auth.MapRoles, NewRolesAuthMap("arn:aws:iam::000000000000:role/node-2",
+		"system:node:{{EC2PrivateDNSName}}",
+		[]string{"system:bootstrappers", "system:nodes"}))
+
+	err = UpdateAuthMap(client, auth, cm)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err = ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(2))
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"111111111111", "222222222222"}))
+}
+
+func TestConfigMaps_UpdatePreservesForeignData(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	create_MockConfigMapWithForeignData(client)
+
+	auth, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	err = UpdateAuthMap(client, auth, cm)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	expect_ForeignDataPreserved(g, client)
+}
+
+func TestConfigMaps_UpsertPreservesForeignData(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMapWithForeignData(client)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node-2",
+		Username: "system:node:{{EC2PrivateDNSName}}",
+		Groups:   []string{"system:bootstrappers", "system:nodes"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	expect_ForeignDataPreserved(g, client)
+
+	err = mapper.UpsertMultiple([]*RolesAuthMap{}, []*UsersAuthMap{
+		NewUsersAuthMap("arn:aws:iam::000000000000:user/user-2", "ops-user", []string{"system:masters"}),
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	expect_ForeignDataPreserved(g, client)
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(2))
+	g.Expect(len(auth.MapUsers)).To(gomega.Equal(2))
+}
+
+func TestConfigMaps_RemovePreservesForeignData(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMapWithForeignData(client)
+
+	err := mapper.Remove(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	expect_ForeignDataPreserved(g, client)
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(0))
+	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
+}
+
+func TestConfigMaps_RemoveByUsernamePreservesForeignData(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMapWithForeignData(client)
+
+	err := mapper.RemoveByUsername(&MapperArguments{
+		Username: "admin",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	expect_ForeignDataPreserved(g, client)
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
+	g.Expect(len(auth.MapUsers)).To(gomega.Equal(0))
+}
+
+func TestConfigMaps_UpdateConflict(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	add_ResourceVersionReactor(client)
+	create_MockConfigMap(client)
+
+	auth, staleConfigMap, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	err = UpdateAuthMap(client, auth, cm)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	err = UpdateAuthMap(client, auth, staleConfigMap)
+	g.Expect(apierrors.IsConflict(err)).To(gomega.BeTrue())
+}
+
+func TestMapper_UpsertReappliesOnConflict(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	add_ResourceVersionReactor(client)
+	create_MockConfigMap(client)
+	mapper := New(client, true)
+
+	// Another writer changes the configmap between the first read and write of the upsert
+	var once sync.Once
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		once.Do(func() {
+			gvr := v1.SchemeGroupVersion.WithResource("configmaps")
+			obj, err := client.Tracker().Get(gvr, AwsAuthNamespace, AwsAuthName)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+			cm := obj.(*v1.ConfigMap)
+			cm.Data["mapUsers"] += NewUsersAuthMap("arn:aws:iam::000000000000:user/other-writer", "other", []string{"system:masters"}).String()
+			cm.ResourceVersion = "100"
+			gomega.Expect(client.Tracker().Update(gvr, cm, AwsAuthNamespace)).To(gomega.Succeed())
+		})
+		return false, nil, nil
+	})
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node-2",
+		Username: "system:node:{{EC2PrivateDNSName}}",
+		Groups:   []string{"system:bootstrappers", "system:nodes"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(2))
+	g.Expect(len(auth.MapUsers)).To(gomega.Equal(2))
+	g.Expect(auth.MapUsers[1].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/other-writer"))
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	g := gomega.NewWithT(t)
+	conflict := apierrors.NewConflict(v1.Resource("configmaps"), AwsAuthName, fmt.Errorf("modified"))
+
+	// the last conflict is returned when the backoff is exhausted
+	var attempts int
+	err := retryOnConflict(context.Background(), wait.Backoff{Steps: 3, Duration: time.Millisecond}, func() error {
+		attempts++
+		return conflict
+	})
+	g.Expect(err).To(gomega.Equal(conflict))
+	g.Expect(attempts).To(gomega.Equal(3))
+
+	// the wait between attempts ends when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts = 0
+	start := time.Now()
+	err = retryOnConflict(ctx, wait.Backoff{Steps: 3, Duration: time.Hour}, func() error {
+		attempts++
+		cancel()
+		return conflict
+	})
+	g.Expect(err).To(gomega.MatchError(context.Canceled))
+	g.Expect(attempts).To(gomega.Equal(1))
+	g.Expect(time.Since(start)).To(gomega.BeNumerically("<", time.Minute))
+}
+
+func TestMapper_ConcurrentUpserts(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	add_ResourceVersionReactor(client)
+	create_MockConfigMap(client)
+
+	const writers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*2)
+
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			errs <- New(client, false).Upsert(&MapperArguments{
+				MapRoles: true,
+				RoleARN:  fmt.Sprintf("arn:aws:iam::000000000000:role/concurrent-%d", i),
+				Username: "system:node:{{EC2PrivateDNSName}}",
+				Groups:   []string{"system:bootstrappers", "system:nodes"},
+			})
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			errs <- New(client, false).Upsert(&MapperArguments{
+				MapUsers: true,
+				UserARN:  fmt.Sprintf("arn:aws:iam::000000000000:user/concurrent-%d", i),
+				Username: fmt.Sprintf("user-%d", i),
+				Groups:   []string{"system:masters"},
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(writers + 1))
+	g.Expect(len(auth.MapUsers)).To(gomega.Equal(writers + 1))
+}
@@ -0,0 +1,556 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// documentEntry is a single desired element of a mapRoles, mapUsers or mapAccounts document
+type documentEntry interface {
+	// key returns the identity of the entry, e.g. the rolearn of a mapRoles entry
+	key() string
+	// nodeKey returns the identity of an existing node
+	nodeKey(n *yaml.Node) string
+	// equal returns true if the fields owned by the entry are identical in the node
+	equal(n *yaml.Node) bool
+	// apply writes the fields owned by the entry into an existing node and reports if it changed
+	apply(n *yaml.Node) bool
+	// node returns a new node representing the entry
+	node() *yaml.Node
+}
+
+type roleEntry struct {
+	*RolesAuthMap
+}
+
+func (e roleEntry) key() string { return e.RoleARN }
+
+func (e roleEntry) nodeKey(n *yaml.Node) string { return scalarValue(mappingValue(n, "rolearn")) }
+
+func (e roleEntry) equal(n *yaml.Node) bool {
+	var existing RolesAuthMap
+	if n.Kind != yaml.MappingNode || n.Decode(&existing) != nil {
+		return false
+	}
+	return existing.RoleARN == e.RoleARN && existing.Username == e.Username && groupsEqual(existing.Groups, e.Groups)
+}
+
+func (e roleEntry) apply(n *yaml.Node) bool {
+	return applyMapping(n, "rolearn", e.RoleARN, e.Username, e.Groups)
+}
+
+func (e roleEntry) node() *yaml.Node {
+	n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	applyMapping(n, "rolearn", e.RoleARN, e.Username, e.Groups)
+	return n
+}
+
+type userEntry struct {
+	*UsersAuthMap
+}
+
+func (e userEntry) key() string { return e.UserARN }
+
+func (e userEntry) nodeKey(n *yaml.Node) string { return scalarValue(mappingValue(n, "userarn")) }
+
+func (e userEntry) equal(n *yaml.Node) bool {
+	var existing UsersAuthMap
+	if n.Kind != yaml.MappingNode || n.Decode(&existing) != nil {
+		return false
+	}
+	return existing.UserARN == e.UserARN && existing.Username == e.Username && groupsEqual(existing.Groups, e.Groups)
+}
+
+func (e userEntry) apply(n *yaml.Node) bool {
+	return applyMapping(n, "userarn", e.UserARN, e.Username, e.Groups)
+}
+
+func (e userEntry) node() *yaml.Node {
+	n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	applyMapping(n, "userarn", e.UserARN, e.Username, e.Groups)
+	return n
+}
+
+type accountEntry string
+
+func (e accountEntry) key() string { return string(e) }
+
+func (e accountEntry) nodeKey(n *yaml.Node) string { return scalarValue(n) }
+
+func (e accountEntry) equal(n *yaml.Node) bool { return scalarValue(n) == string(e) }
+
+func (e accountEntry) apply(n *yaml.Node) bool {
+	if n.Kind == yaml.ScalarNode && n.Value == string(e) {
+		return false
+	}
+	*n = *newScalar(string(e))
+	return true
+}
+
+func (e accountEntry) node() *yaml.Node { return newScalar(string(e)) }
+
+func roleEntries(roles []*RolesAuthMap) []documentEntry {
+	entries := make([]documentEntry, 0, len(roles))
+	for _, r := range roles {
+		entries = append(entries, roleEntry{r})
+	}
+	return entries
+}
+
+func userEntries(users []*UsersAuthMap) []documentEntry {
+	entries := make([]documentEntry, 0, len(users))
+	for _, u := range users {
+		entries = append(entries, userEntry{u})
+	}
+	return entries
+}
+
+func accountEntries(accounts []string) []documentEntry {
+	entries := make([]documentEntry, 0, len(accounts))
+	for _, a := range accounts {
+		entries = append(entries, accountEntry(a))
+	}
+	return entries
+}
+
+// editDocument rewrites a yaml sequence document so that it contains exactly the given entries.
+// Entries which are unchanged keep their original text, including comments and unknown fields,
+// changed entries are re-rendered from their existing node, and removed entries are dropped
+// together with the comments directly above them.
+func editDocument(src string, entries []documentEntry) (string, error) {
+	if strings.TrimSpace(src) == "" {
+		return renderDocument(entries)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		return "", err
+	}
+
+	if len(doc.Content) == 0 {
+		return renderDocument(entries)
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.SequenceNode || root.Style&yaml.FlowStyle != 0 || len(root.Content) == 0 {
+		return renderDocument(entries)
+	}
+
+	if !strings.HasSuffix(src, "\n") {
+		src += "\n"
+	}
+	lines := strings.SplitAfter(src, "\n")
+	lines = lines[:len(lines)-1]
+
+	items := root.Content
+	matches := matchEntries(items, entries)
+	blocks := itemBlocks(lines, items)
+	seqIndent := sequenceIndent(items)
+
+	var out strings.Builder
+	for _, l := range lines[:blocks[0].start] {
+		out.WriteString(l)
+	}
+
+	for i, entry := range entries {
+		j := matches[i]
+		if j < 0 {
+			rendered, err := renderItem(entry.node(), blocks[0].indent, seqIndent)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(rendered)
+			continue
+		}
+
+		block := blocks[j]
+		if entry.equal(items[j]) || !entry.apply(items[j]) {
+			for _, l := range lines[block.start:block.end] {
+				out.WriteString(l)
+			}
+			continue
+		}
+
+		for _, l := range lines[block.start:block.item] {
+			out.WriteString(l)
+		}
+		rendered, err := renderItem(items[j], block.indent, seqIndent)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(rendered)
+	}
+
+	if len(entries) == 0 {
+		out.WriteString(strings.Repeat(" ", blocks[0].indent) + "[]\n")
+	}
+
+	for _, l := range lines[blocks[len(blocks)-1].end:] {
+		out.WriteString(l)
+	}
+
+	return out.String(), nil
+}
+
+// renameEntries rewrites the values of arnKey in the items of a yaml sequence document from the keys of
+// renames to their values. Only the value is replaced in the text, so the items keep their comments and
+// unknown fields. Values which are not found on the line of their node are left for editDocument.
+func renameEntries(src, arnKey string, renames map[string]string) (string, error) {
+	if len(renames) == 0 || strings.TrimSpace(src) == "" {
+		return src, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		return "", err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+		return src, nil
+	}
+
+	lines := strings.SplitAfter(src, "\n")
+	for _, item := range doc.Content[0].Content {
+		v := mappingValue(item, arnKey)
+		if v == nil || v.Kind != yaml.ScalarNode {
+			continue
+		}
+		to, ok := renames[v.Value]
+		if !ok || v.Line < 1 || v.Line > len(lines) {
+			continue
+		}
+
+		// the column counts characters, it is never past the byte offset of the value
+		l := lines[v.Line-1]
+		col := min(v.Column-1, len(l))
+		if i := strings.Index(l[col:], v.Value); i >= 0 {
+			lines[v.Line-1] = l[:col+i] + to + l[col+i+len(v.Value):]
+		}
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// matchEntries returns, for every entry, the index of the existing item it replaces or -1.
+// Exact matches are preferred so that duplicate keys keep their own text.
+func matchEntries(items []*yaml.Node, entries []documentEntry) []int {
+	matches := make([]int, len(entries))
+	used := make([]bool, len(items))
+
+	for i, entry := range entries {
+		matches[i] = -1
+		for j, item := range items {
+			if !used[j] && entry.equal(item) {
+				matches[i] = j
+				used[j] = true
+				break
+			}
+		}
+	}
+
+	for i, entry := range entries {
+		if matches[i] >= 0 {
+			continue
+		}
+		for j, item := range items {
+			if !used[j] && entry.nodeKey(item) == entry.key() {
+				matches[i] = j
+				used[j] = true
+				break
+			}
+		}
+	}
+
+	return matches
+}
+
+// itemBlock is the range of lines an item of a sequence document occupies
+type itemBlock struct {
+	// start is the first line of the block, including comments and blank lines above the item
+	start int
+	// item is the line of the item's dash
+	item int
+	// end is the line after the last line of the item
+	end int
+	// indent is the column of the item's dash
+	indent int
+}
+
+func itemBlocks(lines []string, items []*yaml.Node) []itemBlock {
+	blocks := make([]itemBlock, len(items))
+
+	for j, item := range items {
+		b := itemBlock{item: item.Line - 1}
+		// the dash of an item may be on its own line above the item's content
+		for b.item > 0 && !strings.HasPrefix(strings.TrimSpace(lines[b.item]), "-") {
+			b.item--
+		}
+		b.indent = indentOf(lines[b.item])
+
+		b.end = b.item + 1
+		for b.end < len(lines) {
+			l := lines[b.end]
+			if strings.TrimSpace(l) != "" && indentOf(l) <= b.indent {
+				break
+			}
+			b.end++
+		}
+		for b.end > b.item+1 && strings.TrimSpace(lines[b.end-1]) == "" {
+			b.end--
+		}
+
+		if j == 0 {
+			b.start = b.item
+			for b.start > 0 && strings.HasPrefix(strings.TrimSpace(lines[b.start-1]), "#") {
+				b.start--
+			}
+		} else {
+			b.start = blocks[j-1].end
+		}
+		blocks[j] = b
+	}
+
+	return blocks
+}
+
+func renderDocument(entries []documentEntry) (string, error) {
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, entry := range entries {
+		seq.Content = append(seq.Content, entry.node())
+	}
+	return encodeNode(seq)
+}
+
+// renderItem renders a single sequence item at the given indentation, with the sequences in its mappings
+// indented seqIndent columns below their key. Head comments are dropped as they are carried over from the
+// original text.
+func renderItem(n *yaml.Node, indent, seqIndent int) (string, error) {
+	n.HeadComment = ""
+	if n.Kind == yaml.MappingNode && len(n.Content) > 0 {
+		n.Content[0].HeadComment = ""
+	}
+
+	out, err := encodeNode(&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{n}})
+	if err != nil {
+		return "", err
+	}
+	out, err = indentSequences(out, seqIndent)
+	if err != nil {
+		return "", err
+	}
+
+	var s strings.Builder
+	prefix := strings.Repeat(" ", indent)
+	for _, l := range strings.SplitAfter(out, "\n") {
+		if strings.TrimSpace(l) != "" {
+			s.WriteString(prefix)
+		}
+		s.WriteString(l)
+	}
+	return s.String(), nil
+}
+
+// encodedSequenceIndent is how many columns encodeNode indents a sequence below its mapping key
+const encodedSequenceIndent = 2
+
+// sequenceIndent returns how many columns the first sequence in the mappings of items is indented below
+// its key, e.g. 0 for "groups:\n- a" and 2 for "groups:\n  - a", or the indentation of encodeNode when
+// there is none
+func sequenceIndent(items []*yaml.Node) int {
+	var walk func(n *yaml.Node) (int, bool)
+	walk = func(n *yaml.Node) (int, bool) {
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if isBlockSequence(n.Content[i+1]) {
+					return n.Content[i+1].Column - n.Content[i].Column, true
+				}
+			}
+		}
+		for _, c := range n.Content {
+			if indent, ok := walk(c); ok {
+				return indent, true
+			}
+		}
+		return 0, false
+	}
+
+	for _, item := range items {
+		if indent, ok := walk(item); ok {
+			return indent
+		}
+	}
+	return encodedSequenceIndent
+}
+
+// indentSequences moves the sequences in the mappings of the output of encodeNode to seqIndent columns
+// below their key
+func indentSequences(out string, seqIndent int) (string, error) {
+	if seqIndent == encodedSequenceIndent {
+		return out, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(out), &doc); err != nil {
+		return "", err
+	}
+
+	// a line is moved once for every sequence it is part of
+	lines := strings.SplitAfter(out, "\n")
+	shift := make([]int, len(lines))
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				seq := n.Content[i+1]
+				if !isBlockSequence(seq) {
+					continue
+				}
+				for l := seq.Line - 1; l < len(lines); l++ {
+					if l >= seq.Line && strings.TrimSpace(lines[l]) != "" && indentOf(lines[l]) < seq.Column-1 {
+						break
+					}
+					shift[l] += seqIndent - encodedSequenceIndent
+				}
+			}
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(&doc)
+
+	var s strings.Builder
+	for i, l := range lines {
+		switch {
+		case strings.TrimSpace(l) == "" || shift[i] == 0:
+		case shift[i] > 0:
+			l = strings.Repeat(" ", shift[i]) + l
+		default:
+			l = l[min(-shift[i], indentOf(l)):]
+		}
+		s.WriteString(l)
+	}
+	return s.String(), nil
+}
+
+func isBlockSequence(n *yaml.Node) bool {
+	return n.Kind == yaml.SequenceNode && n.Style&yaml.FlowStyle == 0 && len(n.Content) > 0
+}
+
+func encodeNode(n *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(n); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func applyMapping(n *yaml.Node, arnKey, arn, username string, groups []string) bool {
+	var updated bool
+
+	if scalarValue(mappingValue(n, arnKey)) != arn {
+		setMappingValue(n, arnKey, newScalar(arn))
+		updated = true
+	}
+
+	// a missing username or groups is the same as an empty one
+	if scalarValue(mappingValue(n, "username")) != username {
+		setMappingValue(n, "username", newScalar(username))
+		updated = true
+	}
+
+	var existing []string
+	if v := mappingValue(n, "groups"); v != nil {
+		_ = v.Decode(&existing)
+	}
+
+	if !groupsEqual(existing, groups) {
+		if len(groups) == 0 {
+			deleteMappingKey(n, "groups")
+		} else {
+			seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			for _, g := range groups {
+				seq.Content = append(seq.Content, newScalar(g))
+			}
+			setMappingValue(n, "groups", seq)
+		}
+		updated = true
+	}
+
+	return updated
+}
+
+func groupsEqual(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func newScalar(v string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+}
+
+func scalarValue(n *yaml.Node) string {
+	if n == nil || n.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return n.Value
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(n *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			value.LineComment = n.Content[i+1].LineComment
+			n.Content[i+1] = value
+			return
+		}
+	}
+	n.Content = append(n.Content, newScalar(key), value)
+}
+
+func deleteMappingKey(n *yaml.Node, key string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
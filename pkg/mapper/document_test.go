@@ -0,0 +1,294 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const commentedMapRoles = `# Roles managed by the platform team
+- rolearn: arn:aws:iam::000000000000:role/node-1
+  # node instance role, do not remove
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+  - system:bootstrappers
+  - system:nodes
+
+# break-glass access, see runbook
+- rolearn: arn:aws:iam::000000000000:role/admin
+  username: admin # audited
+  groups:
+  - system:masters
+  team: sre
+# ci deployments
+- rolearn: arn:aws:iam::000000000000:role/ci
+  username: ci
+  groups:
+  - deployers
+`
+
+func create_MockCommentedConfigMap(client kubernetes.Interface) {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AwsAuthName,
+			Namespace: AwsAuthNamespace,
+		},
+		Data: map[string]string{
+			"mapRoles": commentedMapRoles,
+		},
+	}
+	_, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func TestEditDocument_Unchanged(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	create_MockCommentedConfigMap(client)
+
+	auth, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	out, err := editDocument(cm.Data["mapRoles"], roleEntries(auth.MapRoles))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(out).To(gomega.Equal(commentedMapRoles))
+}
+
+func TestEditDocument_UpdateOnlyChangesEntryLines(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockCommentedConfigMap(client)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/admin",
+		Username: "admin",
+		Groups:   []string{"system:masters", "auditors"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data["mapRoles"]).To(gomega.Equal(`# Roles managed by the platform team
+- rolearn: arn:aws:iam::000000000000:role/node-1
+  # node instance role, do not remove
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+  - system:bootstrappers
+  - system:nodes
+
+# break-glass access, see runbook
+- rolearn: arn:aws:iam::000000000000:role/admin
+  username: admin # audited
+  groups:
+  - system:masters
+  - auditors
+  team: sre
+# ci deployments
+- rolearn: arn:aws:iam::000000000000:role/ci
+  username: ci
+  groups:
+  - deployers
+`))
+}
+
+func TestEditDocument_EmptyFieldsUnchanged(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+
+	mapRoles := "- rolearn: arn:aws:iam::000000000000:role/a\n  groups: []\n"
+	_, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Create(context.Background(), &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: AwsAuthName, Namespace: AwsAuthNamespace},
+		Data:       map[string]string{"mapRoles": mapRoles},
+	}, metav1.CreateOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	err = mapper.Upsert(&MapperArguments{
+		MapUsers: true,
+		UserARN:  "arn:aws:iam::000000000000:user/alice",
+		Username: "alice",
+		Groups:   []string{"viewers"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data["mapRoles"]).To(gomega.Equal(mapRoles))
+
+	err = mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/b",
+		Username: "b",
+		Groups:   []string{"viewers"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, cm, err = ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data["mapRoles"]).To(gomega.HavePrefix(mapRoles + "- rolearn: arn:aws:iam::000000000000:role/b\n"))
+}
+
+func TestEditDocument_InsertAppendsEntry(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockCommentedConfigMap(client)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node-2",
+		Username: "system:node:{{EC2PrivateDNSName}}",
+		Groups:   []string{"system:bootstrappers", "system:nodes"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data["mapRoles"]).To(gomega.Equal(commentedMapRoles + `- rolearn: arn:aws:iam::000000000000:role/node-2
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+  - system:bootstrappers
+  - system:nodes
+`))
+}
+
+func TestEditDocument_RemoveDropsEntryAndComment(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockCommentedConfigMap(client)
+
+	err := mapper.Remove(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/admin",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data["mapRoles"]).To(gomega.Equal(`# Roles managed by the platform team
+- rolearn: arn:aws:iam::000000000000:role/node-1
+  # node instance role, do not remove
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+  - system:bootstrappers
+  - system:nodes
+# ci deployments
+- rolearn: arn:aws:iam::000000000000:role/ci
+  username: ci
+  groups:
+  - deployers
+`))
+}
+
+func TestEditDocument_PreservesUnknownFields(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockCommentedConfigMap(client)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/admin",
+		Username: "break-glass",
+		Groups:   []string{"system:masters"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data["mapRoles"]).To(gomega.ContainSubstring("  username: break-glass # audited\n"))
+	g.Expect(cm.Data["mapRoles"]).To(gomega.ContainSubstring("  team: sre\n"))
+}
+
+func TestEditDocument_RemoveAll(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	src := "# managed by the platform team\n\n# node role\n- rolearn: arn:aws:iam::000000000000:role/node-1\n  username: node\n"
+	out, err := editDocument(src, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(out).To(gomega.Equal("# managed by the platform team\n\n[]\n"))
+}
+
+func TestEditDocument_Accounts(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	src := "# trusted accounts\n- \"111111111111\" # prod\n- \"222222222222\"\n"
+	out, err := editDocument(src, accountEntries([]string{"111111111111", "333333333333"}))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(out).To(gomega.Equal("# trusted accounts\n- \"111111111111\" # prod\n- \"333333333333\"\n"))
+}
+
+func TestEditDocument_SequenceIndent(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	// inserted and changed entries indent their sequences like the document
+	src := `- rolearn: arn:aws:iam::000000000000:role/admin
+  username: admin
+  groups:
+      - system:masters
+  extra:
+      - owners:
+            - sre
+`
+	admin := NewRolesAuthMap("arn:aws:iam::000000000000:role/admin", "admin", []string{"system:masters", "auditors"})
+	node := NewRolesAuthMap("arn:aws:iam::000000000000:role/node-1", "node", []string{"system:bootstrappers", "system:nodes"})
+	out, err := editDocument(src, roleEntries([]*RolesAuthMap{admin, node}))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(out).To(gomega.Equal(`- rolearn: arn:aws:iam::000000000000:role/admin
+  username: admin
+  groups:
+      - system:masters
+      - auditors
+  extra:
+      - owners:
+            - sre
+- rolearn: arn:aws:iam::000000000000:role/node-1
+  username: node
+  groups:
+      - system:bootstrappers
+      - system:nodes
+`))
+}
+
+func TestEditDocument_EmptySource(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	out, err := editDocument("", roleEntries([]*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/node-1", "system:node:{{EC2PrivateDNSName}}", []string{"system:nodes"}),
+	}))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(out).To(gomega.Equal(`- rolearn: arn:aws:iam::000000000000:role/node-1
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+    - system:nodes
+`))
+}
@@ -0,0 +1,185 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by MapperArguments.Validate, they can be matched with errors.Is
+var (
+	ErrInvalidRetryCount = errors.New("--retry-max-count is invalid, must be greater than zero")
+	ErrMissingRoleARN    = errors.New("--rolearn not provided")
+	ErrMissingUserARN    = errors.New("--userarn not provided")
+	ErrMissingAccountID  = errors.New("--account not provided")
+	ErrMissingUsername   = errors.New("--username not provided")
+	ErrMissingMapType    = errors.New("must select --mapusers, --maproles or --mapaccounts")
+	ErrMutuallyExclusive = errors.New("mutually exclusive arguments")
+	ErrUnsupportedFormat = errors.New("unsupported --format")
+	ErrInvalidDryRun     = errors.New("--dry-run must be 'client' or 'server'")
+)
+
+// ErrDuplicateMapping is returned when a desired state contains the same ARN or account more than once
+var ErrDuplicateMapping = errors.New("duplicate mapping")
+
+// Errors returned when a mapping to be written has a malformed ARN or account
+var (
+	ErrInvalidARN       = errors.New("invalid ARN")
+	ErrInvalidAccountID = errors.New("invalid account")
+)
+
+// ErrInvalidUsernameTemplate is returned when a username to be written has a placeholder which is
+// not supported by the authenticator
+var ErrInvalidUsernameTemplate = errors.New("invalid username template")
+
+// ErrNoMatchingMapping is returned when no mapping matches a caller
+var ErrNoMatchingMapping = errors.New("no mapping matches")
+
+// Errors returned when restoring a backup
+var (
+	ErrBackupNotFound = errors.New("backup not found")
+	ErrUnsafeRestore  = errors.New("unsafe restore")
+)
+
+// Errors returned when rolling back to a revision of the change history
+var (
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrHistoryDiverged  = errors.New("aws-auth was modified outside of the change history")
+)
+
+// ErrConflictingRoleARNs is returned by Normalize when role ARNs which only differ by path are
+// mapped to different usernames or groups, so they cannot be merged without losing one of them
+var ErrConflictingRoleARNs = errors.New("role ARNs differ only by path but are mapped differently")
+
+// ErrProtected is returned when an operation would remove or modify a protected mapping
+var ErrProtected = errors.New("protected mapping")
+
+// Errors returned by policies, ErrInvalidPolicy when a rules file cannot be loaded and
+// ErrPolicyViolation when the data to be written breaks a rule
+var (
+	ErrInvalidPolicy   = errors.New("invalid policy")
+	ErrPolicyViolation = errors.New("policy violation")
+)
+
+// Errors returned by lint, ErrInvalidSeverity for an unknown severity and ErrLintFindings when
+// findings reach the severity threshold
+var (
+	ErrInvalidSeverity = errors.New("invalid severity")
+	ErrLintFindings    = errors.New("lint findings")
+)
+
+// ErrClusterRequired is returned by backups, restores and rollbacks when the mapper operates on a file
+var ErrClusterRequired = errors.New("needs a cluster, not supported on a file")
+
+// ErrUnsupportedAccessEntry is returned when a mapping cannot be exported as or imported from an EKS
+// access entry, e.g. because of a system: group other than system:masters
+var ErrUnsupportedAccessEntry = errors.New("not supported by EKS access entries")
+
+// MutuallyExclusiveError is returned when arguments which cannot be combined are provided together
+type MutuallyExclusiveError struct {
+	Flags []string
+}
+
+func (e *MutuallyExclusiveError) Error() string {
+	return fmt.Sprintf("%v are mutually exclusive", strings.Join(e.Flags, ", "))
+}
+
+// Is allows matching a MutuallyExclusiveError with ErrMutuallyExclusive
+func (e *MutuallyExclusiveError) Is(target error) bool {
+	return target == ErrMutuallyExclusive
+}
+
+// ARNError is returned when an ARN is malformed or is not of the expected type
+type ARNError struct {
+	ARN    string
+	Reason string
+}
+
+func (e *ARNError) Error() string {
+	return fmt.Sprintf("invalid ARN %q: %v", e.ARN, e.Reason)
+}
+
+// Is allows matching an ARNError with ErrInvalidARN
+func (e *ARNError) Is(target error) bool {
+	return target == ErrInvalidARN
+}
+
+// TemplateError is returned when a username template has an unknown placeholder or unbalanced braces
+type TemplateError struct {
+	Template string
+	Reason   string
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("invalid username template %q: %v", e.Template, e.Reason)
+}
+
+// Is allows matching a TemplateError with ErrInvalidUsernameTemplate
+func (e *TemplateError) Is(target error) bool {
+	return target == ErrInvalidUsernameTemplate
+}
+
+// NoMatchError is returned when no mapping matches a caller, Reasons explain why
+type NoMatchError struct {
+	CallerARN string
+	Reasons   []string
+}
+
+func (e *NoMatchError) Error() string {
+	return fmt.Sprintf("%v for %v: %v", ErrNoMatchingMapping, e.CallerARN, strings.Join(e.Reasons, "; "))
+}
+
+// Is allows matching a NoMatchError with ErrNoMatchingMapping
+func (e *NoMatchError) Is(target error) bool {
+	return target == ErrNoMatchingMapping
+}
+
+// ProtectedError is returned when an operation would remove or modify mappings which are protected
+// by the ProtectedAnnotation, and protection is not overridden
+type ProtectedError struct {
+	Operation OperationType
+	// Mappings are the names of the protected mappings, as in Mapping.Name
+	Mappings []string
+}
+
+func (e *ProtectedError) Error() string {
+	return fmt.Sprintf("%v: %v would remove or modify %v, override the protection to change them", ErrProtected, e.Operation, strings.Join(e.Mappings, ", "))
+}
+
+// Is allows matching a ProtectedError with ErrProtected
+func (e *ProtectedError) Is(target error) bool {
+	return target == ErrProtected
+}
+
+// PolicyError is returned when the data to be written violates the policies of the AuthMapper
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	violations := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		violations[i] = v.String()
+	}
+	return fmt.Sprintf("%v: %v", ErrPolicyViolation, strings.Join(violations, "; "))
+}
+
+// Is allows matching a PolicyError with ErrPolicyViolation
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
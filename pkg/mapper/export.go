@@ -0,0 +1,445 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Formats supported by export and import next to yaml, which is a ConfigMap manifest
+const (
+	FormatEksctl         = "eksctl"
+	FormatTerraform      = "terraform"
+	FormatCloudFormation = "cloudformation"
+)
+
+// ExportFormats are the formats supported by Export and ImportAuthData
+var ExportFormats = []string{FormatEksctl, FormatTerraform, FormatCloudFormation, FormatYAML}
+
+// ExportOptions are the options of Export
+type ExportOptions struct {
+	// Format is one of ExportFormats
+	Format string
+	// ClusterName names the cluster in the eksctl ClusterConfig and is the default of the ClusterName
+	// parameter of the CloudFormation template
+	ClusterName string
+	// Filter selects the mappings which are exported, all of them when it is nil
+	Filter *MappingFilter
+}
+
+// Export writes the mappings of the aws-auth configmap to w in opts.Format, see ExportAuthData
+func (b *AuthMapper) Export(w io.Writer, opts ExportOptions) error {
+	return b.ExportWithContext(context.Background(), w, opts)
+}
+
+// ExportWithContext is like Export but uses the given context for the API calls
+func (b *AuthMapper) ExportWithContext(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	authData, err := b.getAuth(ctx)
+	if err != nil {
+		return err
+	}
+	return exportAuthData(w, &authData, opts, b.configMapRef())
+}
+
+// ExportAuthData writes authData to w in opts.Format:
+//   - eksctl is a ClusterConfig with the iamIdentityMappings of the mappings
+//   - terraform are the aws_auth_roles, aws_auth_users and aws_auth_accounts variables of the
+//     terraform-aws-modules/eks aws-auth module as a tfvars file
+//   - cloudformation is a template with an AWS::EKS::AccessEntry per role and user mapping, node roles
+//     become EC2_LINUX entries and system:masters the cluster admin access policy. Other system: groups
+//     fail with ErrUnsupportedAccessEntry. Account mappings have no access entry and are listed in a comment.
+//   - yaml is a manifest of the kube-system/aws-auth ConfigMap
+func ExportAuthData(w io.Writer, authData *AwsAuthData, opts ExportOptions) error {
+	return exportAuthData(w, authData, opts, newConfigMapRef(nil))
+}
+
+func exportAuthData(w io.Writer, authData *AwsAuthData, opts ExportOptions, ref configMapRef) error {
+	if opts.Filter != nil {
+		filtered := authData.Filter(opts.Filter)
+		authData = &filtered
+	}
+
+	switch opts.Format {
+	case FormatEksctl:
+		return encodeYAML(w, eksctlConfig(authData, opts.ClusterName))
+	case FormatTerraform:
+		return writeTerraform(w, authData)
+	case FormatCloudFormation:
+		template, err := accessEntryTemplate(authData, opts.ClusterName)
+		if err != nil {
+			return err
+		}
+		if len(authData.MapAccounts) > 0 {
+			fmt.Fprintf(w, "# mapAccounts have no access entry and are not exported: %v\n", strings.Join(authData.MapAccounts, ", "))
+		}
+		return encodeYAML(w, template)
+	case FormatYAML:
+		return writeManifest(w, authData, ref)
+	}
+	return fmt.Errorf("%w: %q, supported values are %v", ErrUnsupportedFormat, opts.Format, strings.Join(ExportFormats, ", "))
+}
+
+// ImportAuthData parses mappings in one of ExportFormats. The yaml format also accepts a desired state
+// document as read by UnmarshalAuthData. The result is not validated, see AwsAuthData.Validate.
+func ImportAuthData(data []byte, format string) (AwsAuthData, error) {
+	switch format {
+	case FormatEksctl:
+		return importEksctl(data)
+	case FormatTerraform:
+		return parseTerraform(data)
+	case FormatCloudFormation:
+		return importCloudFormation(data)
+	case FormatYAML:
+		return importManifest(data)
+	}
+	return AwsAuthData{}, fmt.Errorf("%w: %q, supported values are %v", ErrUnsupportedFormat, format, strings.Join(ExportFormats, ", "))
+}
+
+func encodeYAML(w io.Writer, v interface{}) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// eksctlClusterConfig is the part of an eksctl ClusterConfig which holds the aws-auth mappings
+type eksctlClusterConfig struct {
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty"`
+	Metadata   *struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata,omitempty"`
+	IAMIdentityMappings []eksctlIdentityMapping `yaml:"iamIdentityMappings"`
+}
+
+// eksctlIdentityMapping is an iamIdentityMappings item, rolearn and userarn are read too as they are
+// used by eksctl get iamidentitymapping
+type eksctlIdentityMapping struct {
+	ARN      string   `yaml:"arn,omitempty"`
+	RoleARN  string   `yaml:"rolearn,omitempty"`
+	UserARN  string   `yaml:"userarn,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Groups   []string `yaml:"groups,omitempty"`
+	Account  string   `yaml:"account,omitempty"`
+}
+
+func eksctlConfig(authData *AwsAuthData, clusterName string) *eksctlClusterConfig {
+	config := &eksctlClusterConfig{
+		APIVersion:          "eksctl.io/v1alpha5",
+		Kind:                "ClusterConfig",
+		IAMIdentityMappings: []eksctlIdentityMapping{},
+	}
+	if clusterName != "" {
+		config.Metadata = &struct {
+			Name string `yaml:"name"`
+		}{Name: clusterName}
+	}
+
+	for _, r := range authData.MapRoles {
+		config.IAMIdentityMappings = append(config.IAMIdentityMappings, eksctlIdentityMapping{ARN: r.RoleARN, Username: r.Username, Groups: r.Groups})
+	}
+	for _, u := range authData.MapUsers {
+		config.IAMIdentityMappings = append(config.IAMIdentityMappings, eksctlIdentityMapping{ARN: u.UserARN, Username: u.Username, Groups: u.Groups})
+	}
+	for _, a := range authData.MapAccounts {
+		config.IAMIdentityMappings = append(config.IAMIdentityMappings, eksctlIdentityMapping{Account: a})
+	}
+	return config
+}
+
+// importEksctl reads the iamIdentityMappings of a ClusterConfig, or a list of them
+func importEksctl(data []byte) (AwsAuthData, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return AwsAuthData{}, err
+	}
+
+	var mappings []eksctlIdentityMapping
+	if len(doc.Content) > 0 {
+		var err error
+		if doc.Content[0].Kind == yaml.SequenceNode {
+			err = doc.Content[0].Decode(&mappings)
+		} else {
+			var config eksctlClusterConfig
+			err = doc.Content[0].Decode(&config)
+			mappings = config.IAMIdentityMappings
+		}
+		if err != nil {
+			return AwsAuthData{}, err
+		}
+	}
+
+	var authData AwsAuthData
+	for i, m := range mappings {
+		if m.Account != "" {
+			authData.MapAccounts = append(authData.MapAccounts, m.Account)
+			continue
+		}
+
+		arn := m.ARN
+		switch {
+		case m.RoleARN != "":
+			arn = m.RoleARN
+		case m.UserARN != "":
+			arn = m.UserARN
+		}
+		if err := authData.addPrincipal(arn, m.Username, m.Groups); err != nil {
+			return AwsAuthData{}, fmt.Errorf("iamIdentityMappings[%d]: %w", i, err)
+		}
+	}
+	return authData, nil
+}
+
+// addPrincipal adds a role or user mapping depending on the resource type of arn
+func (m *AwsAuthData) addPrincipal(arn, username string, groups []string) error {
+	switch arnResourceType(arn) {
+	case ResourceTypeRole:
+		m.MapRoles = append(m.MapRoles, NewRolesAuthMap(arn, username, groups))
+	case ResourceTypeUser, ResourceTypeRoot:
+		m.MapUsers = append(m.MapUsers, NewUsersAuthMap(arn, username, groups))
+	default:
+		return fmt.Errorf("%w: %q is neither a role nor a user ARN", ErrInvalidARN, arn)
+	}
+	return nil
+}
+
+// arnResourceType returns the resource type of an ARN, e.g. role, or an empty string if it has none
+func arnResourceType(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	return strings.SplitN(parts[5], "/", 2)[0]
+}
+
+const (
+	accessEntryType     = "AWS::EKS::AccessEntry"
+	accessEntryEC2Linux = "EC2_LINUX"
+	nodeUsername        = "system:node:{{EC2PrivateDNSName}}"
+	mastersGroup        = "system:masters"
+	accessScopeCluster  = "cluster"
+)
+
+// clusterAdminPolicy returns the ARN of the cluster admin access policy in the partition of principalARN,
+// e.g. aws-cn or aws-us-gov
+func clusterAdminPolicy(principalARN string) string {
+	partition := "aws"
+	if parts := strings.SplitN(principalARN, ":", 3); len(parts) == 3 && parts[1] != "" {
+		partition = parts[1]
+	}
+	return fmt.Sprintf("arn:%v:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy", partition)
+}
+
+// nodeGroups are the groups of a node role mapping, which is an EC2_LINUX access entry
+var nodeGroups = []string{"system:bootstrappers", "system:nodes"}
+
+type cloudFormationTemplate struct {
+	AWSTemplateFormatVersion string                               `yaml:"AWSTemplateFormatVersion"`
+	Description              string                               `yaml:"Description"`
+	Parameters               map[string]cloudFormationParameter   `yaml:"Parameters"`
+	Resources                map[string]cloudFormationAccessEntry `yaml:"Resources"`
+}
+
+type cloudFormationParameter struct {
+	Type    string `yaml:"Type"`
+	Default string `yaml:"Default,omitempty"`
+}
+
+type cloudFormationAccessEntry struct {
+	Type       string `yaml:"Type"`
+	Properties struct {
+		ClusterName      map[string]string `yaml:"ClusterName,omitempty"`
+		PrincipalArn     string            `yaml:"PrincipalArn"`
+		Type             string            `yaml:"Type,omitempty"`
+		Username         string            `yaml:"Username,omitempty"`
+		KubernetesGroups []string          `yaml:"KubernetesGroups,omitempty"`
+		AccessPolicies   []accessPolicy    `yaml:"AccessPolicies,omitempty"`
+	} `yaml:"Properties"`
+}
+
+// accessPolicy associates an EKS access policy with an access entry
+type accessPolicy struct {
+	PolicyArn   string `yaml:"PolicyArn"`
+	AccessScope struct {
+		Type       string   `yaml:"Type"`
+		Namespaces []string `yaml:"Namespaces,omitempty"`
+	} `yaml:"AccessScope"`
+}
+
+var logicalIDInvalid = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// accessEntryTemplate returns a CloudFormation template with the access entries of the role and user
+// mappings. EKS rejects kubernetes groups starting with system:, system:masters is associated with the
+// cluster admin access policy instead and the other system: groups cannot be exported.
+func accessEntryTemplate(authData *AwsAuthData, clusterName string) (*cloudFormationTemplate, error) {
+	template := &cloudFormationTemplate{
+		AWSTemplateFormatVersion: "2010-09-09",
+		Description:              "EKS access entries of the aws-auth configmap",
+		Parameters:               map[string]cloudFormationParameter{"ClusterName": {Type: "String", Default: clusterName}},
+		Resources:                map[string]cloudFormationAccessEntry{},
+	}
+
+	add := func(prefix, arn, username string, groups []string) error {
+		var entry cloudFormationAccessEntry
+		entry.Type = accessEntryType
+		entry.Properties.ClusterName = map[string]string{"Ref": "ClusterName"}
+		entry.Properties.PrincipalArn = arn
+		if username == nodeUsername && slices.Contains(groups, "system:nodes") {
+			entry.Properties.Type = accessEntryEC2Linux
+		} else {
+			entry.Properties.Username = username
+			for _, group := range groups {
+				switch {
+				case group == mastersGroup:
+					var policy accessPolicy
+					policy.PolicyArn = clusterAdminPolicy(arn)
+					policy.AccessScope.Type = accessScopeCluster
+					entry.Properties.AccessPolicies = []accessPolicy{policy}
+				case strings.HasPrefix(group, "system:"):
+					return fmt.Errorf("%v: group %v is %w", arn, group, ErrUnsupportedAccessEntry)
+				default:
+					entry.Properties.KubernetesGroups = append(entry.Properties.KubernetesGroups, group)
+				}
+			}
+		}
+
+		// logical IDs are alphanumeric, names which only differ in other characters are numbered
+		name := prefix + logicalIDInvalid.ReplaceAllString(arn[strings.LastIndex(arn, "/")+1:], "")
+		id := name
+		for i := 2; ; i++ {
+			if _, ok := template.Resources[id]; !ok {
+				break
+			}
+			id = fmt.Sprintf("%v%d", name, i)
+		}
+		template.Resources[id] = entry
+		return nil
+	}
+
+	for _, r := range authData.MapRoles {
+		if err := add("Role", r.RoleARN, r.Username, r.Groups); err != nil {
+			return nil, err
+		}
+	}
+	for _, u := range authData.MapUsers {
+		if err := add("User", u.UserARN, u.Username, u.Groups); err != nil {
+			return nil, err
+		}
+	}
+	return template, nil
+}
+
+// importCloudFormation reads the AWS::EKS::AccessEntry resources of a template in logical ID order. The
+// cluster admin access policy with cluster scope becomes the system:masters group, other access policies
+// have no aws-auth mapping and fail with ErrUnsupportedAccessEntry.
+func importCloudFormation(data []byte) (AwsAuthData, error) {
+	var template struct {
+		Resources map[string]struct {
+			Type       string `yaml:"Type"`
+			Properties struct {
+				PrincipalArn     string         `yaml:"PrincipalArn"`
+				Type             string         `yaml:"Type"`
+				Username         string         `yaml:"Username"`
+				KubernetesGroups []string       `yaml:"KubernetesGroups"`
+				AccessPolicies   []accessPolicy `yaml:"AccessPolicies"`
+			} `yaml:"Properties"`
+		} `yaml:"Resources"`
+	}
+	if err := yaml.Unmarshal(data, &template); err != nil {
+		return AwsAuthData{}, err
+	}
+
+	ids := make([]string, 0, len(template.Resources))
+	for id, r := range template.Resources {
+		if r.Type == accessEntryType {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var authData AwsAuthData
+	for _, id := range ids {
+		p := template.Resources[id].Properties
+		username, groups := p.Username, p.KubernetesGroups
+		if p.Type == accessEntryEC2Linux {
+			username, groups = nodeUsername, slices.Clone(nodeGroups)
+		}
+		for _, policy := range p.AccessPolicies {
+			if policy.PolicyArn != clusterAdminPolicy(p.PrincipalArn) || policy.AccessScope.Type != accessScopeCluster {
+				return AwsAuthData{}, fmt.Errorf("%v: access policy %v with %v scope is %w", id, policy.PolicyArn, policy.AccessScope.Type, ErrUnsupportedAccessEntry)
+			}
+			if !slices.Contains(groups, mastersGroup) {
+				groups = append([]string{mastersGroup}, groups...)
+			}
+		}
+		if err := authData.addPrincipal(p.PrincipalArn, username, groups); err != nil {
+			return AwsAuthData{}, fmt.Errorf("%v: %w", id, err)
+		}
+	}
+	return authData, nil
+}
+
+// writeManifest writes authData as a ConfigMap manifest
+func writeManifest(w io.Writer, authData *AwsAuthData, ref configMapRef) error {
+	cm := newAuthConfigMap(ref)
+	if err := encodeAuthData(*authData, cm); err != nil {
+		return err
+	}
+	for key, value := range cm.Data {
+		if strings.TrimSpace(value) == "" || strings.TrimSpace(value) == "[]" {
+			delete(cm.Data, key)
+		}
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(fmt.Sprintf(newManifest, ref.name, ref.namespace)), doc); err != nil {
+		return err
+	}
+	syncStrings(doc.Content[0], "data", cm.Data, authDataKeys)
+	return encodeYAML(w, doc)
+}
+
+// importManifest reads the first ConfigMap of a manifest, or a desired state document when it has none
+func importManifest(data []byte) (AwsAuthData, error) {
+	docs, err := parseManifest("manifest", data)
+	if err != nil {
+		return AwsAuthData{}, err
+	}
+
+	for _, doc := range docs {
+		if scalarValue(mappingValue(doc.Content[0], "kind")) != "ConfigMap" {
+			continue
+		}
+		var manifest manifestConfigMap
+		if err := doc.Content[0].Decode(&manifest); err != nil {
+			return AwsAuthData{}, err
+		}
+		return decodeAuthData(&v1.ConfigMap{Data: manifest.Data})
+	}
+
+	return UnmarshalAuthData(data)
+}
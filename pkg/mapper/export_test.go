@@ -0,0 +1,201 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func export_MockAuthData(t *testing.T) AwsAuthData {
+	authData, err := UnmarshalAuthData([]byte(desiredState))
+	gomega.NewWithT(t).Expect(err).NotTo(gomega.HaveOccurred())
+	return authData
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	g := gomega.NewWithT(t)
+	authData := export_MockAuthData(t)
+
+	for _, format := range []string{FormatEksctl, FormatTerraform, FormatYAML} {
+		var buf bytes.Buffer
+		g.Expect(ExportAuthData(&buf, &authData, ExportOptions{Format: format, ClusterName: "prod"})).To(gomega.Succeed(), format)
+
+		imported, err := ImportAuthData(buf.Bytes(), format)
+		g.Expect(err).NotTo(gomega.HaveOccurred(), format)
+		g.Expect(imported).To(gomega.Equal(authData), format)
+	}
+}
+
+func TestExport_Eksctl(t *testing.T) {
+	g := gomega.NewWithT(t)
+	authData := export_MockAuthData(t)
+
+	var buf bytes.Buffer
+	g.Expect(ExportAuthData(&buf, &authData, ExportOptions{Format: FormatEksctl, ClusterName: "prod"})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.HavePrefix(`apiVersion: eksctl.io/v1alpha5
+kind: ClusterConfig
+metadata:
+  name: prod
+iamIdentityMappings:
+  - arn: arn:aws:iam::000000000000:role/node-1
+    username: system:node:{{EC2PrivateDNSName}}
+    groups:
+      - system:bootstrappers
+      - system:nodes
+`))
+	g.Expect(buf.String()).To(gomega.HaveSuffix("  - account: \"111111111111\"\n"))
+
+	// the output of eksctl get iamidentitymapping is accepted too
+	imported, err := ImportAuthData([]byte(`- rolearn: arn:aws:iam::000000000000:role/ci
+  username: ci
+  groups:
+  - deployers
+- userarn: arn:aws:iam::000000000000:user/user-1
+  username: admin
+- account: "111111111111"
+`), FormatEksctl)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(imported.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(imported.MapUsers).To(gomega.HaveLen(1))
+	g.Expect(imported.MapAccounts).To(gomega.Equal([]string{"111111111111"}))
+
+	_, err = ImportAuthData([]byte("iamIdentityMappings:\n  - arn: arn:aws:iam::000000000000:group/admins\n"), FormatEksctl)
+	g.Expect(err).To(gomega.MatchError(ErrInvalidARN))
+}
+
+func TestExport_CloudFormation(t *testing.T) {
+	g := gomega.NewWithT(t)
+	authData := export_MockAuthData(t)
+
+	var buf bytes.Buffer
+	g.Expect(ExportAuthData(&buf, &authData, ExportOptions{Format: FormatCloudFormation, ClusterName: "prod"})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.HavePrefix("# mapAccounts have no access entry and are not exported: 111111111111\n"))
+	g.Expect(buf.String()).To(gomega.ContainSubstring(`  Rolenode1:
+    Type: AWS::EKS::AccessEntry
+    Properties:
+      ClusterName:
+        Ref: ClusterName
+      PrincipalArn: arn:aws:iam::000000000000:role/node-1
+      Type: EC2_LINUX
+`))
+	g.Expect(buf.String()).To(gomega.ContainSubstring(`  Useruser1:
+    Type: AWS::EKS::AccessEntry
+    Properties:
+      ClusterName:
+        Ref: ClusterName
+      PrincipalArn: arn:aws:iam::000000000000:user/user-1
+      Username: admin
+      KubernetesGroups:
+        - auditors
+      AccessPolicies:
+        - PolicyArn: arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy
+          AccessScope:
+            Type: cluster
+`))
+
+	imported, err := ImportAuthData(buf.Bytes(), FormatCloudFormation)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(imported.MapRoles).To(gomega.ConsistOf(authData.MapRoles))
+	g.Expect(imported.MapUsers).To(gomega.Equal(authData.MapUsers))
+	g.Expect(imported.MapAccounts).To(gomega.BeEmpty())
+
+	// EKS rejects system: groups, only system:masters has an access policy
+	authData.MapUsers[0].Groups = []string{"system:monitoring"}
+	buf.Reset()
+	err = ExportAuthData(&buf, &authData, ExportOptions{Format: FormatCloudFormation})
+	g.Expect(err).To(gomega.MatchError(ErrUnsupportedAccessEntry))
+	g.Expect(buf.String()).To(gomega.BeEmpty())
+
+	_, err = ImportAuthData([]byte(`Resources:
+  Userviewer:
+    Type: AWS::EKS::AccessEntry
+    Properties:
+      PrincipalArn: arn:aws:iam::000000000000:user/viewer
+      AccessPolicies:
+        - PolicyArn: arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy
+          AccessScope:
+            Type: cluster
+`), FormatCloudFormation)
+	g.Expect(err).To(gomega.MatchError(ErrUnsupportedAccessEntry))
+}
+
+func TestExport_CloudFormationPartition(t *testing.T) {
+	g := gomega.NewWithT(t)
+	authData := AwsAuthData{MapRoles: []*RolesAuthMap{
+		NewRolesAuthMap("arn:aws-cn:iam::000000000000:role/admin", "admin", []string{"system:masters"}),
+		NewRolesAuthMap("arn:aws-us-gov:iam::000000000000:role/ops", "ops", []string{"system:masters"}),
+	}}
+
+	// the access policy is in the partition of the principal
+	var buf bytes.Buffer
+	g.Expect(ExportAuthData(&buf, &authData, ExportOptions{Format: FormatCloudFormation})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.ContainSubstring("PolicyArn: arn:aws-cn:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy\n"))
+	g.Expect(buf.String()).To(gomega.ContainSubstring("PolicyArn: arn:aws-us-gov:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy\n"))
+
+	imported, err := ImportAuthData(buf.Bytes(), FormatCloudFormation)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(imported.MapRoles).To(gomega.ConsistOf(authData.MapRoles))
+
+	// a policy of another partition does not apply to the principal
+	_, err = ImportAuthData([]byte(`Resources:
+  Roleadmin:
+    Type: AWS::EKS::AccessEntry
+    Properties:
+      PrincipalArn: arn:aws-cn:iam::000000000000:role/admin
+      AccessPolicies:
+        - PolicyArn: arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy
+          AccessScope:
+            Type: cluster
+`), FormatCloudFormation)
+	g.Expect(err).To(gomega.MatchError(ErrUnsupportedAccessEntry))
+}
+
+func TestExport_Manifest(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	create_MockConfigMap(client)
+	mapper := New(client, true)
+
+	var buf bytes.Buffer
+	g.Expect(mapper.Export(&buf, ExportOptions{Format: FormatYAML, Filter: &MappingFilter{MapRoles: true}})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: aws-auth
+  namespace: kube-system
+data:
+  mapRoles: |
+    - rolearn: arn:aws:iam::000000000000:role/node-1
+      username: system:node:{{EC2PrivateDNSName}}
+      groups:
+        - system:bootstrappers
+        - system:nodes
+`))
+
+	// a desired state document is imported too
+	imported, err := ImportAuthData([]byte(desiredState), FormatYAML)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(imported).To(gomega.Equal(export_MockAuthData(t)))
+
+	g.Expect(ExportAuthData(&buf, &imported, ExportOptions{Format: "pulumi"})).To(gomega.MatchError(ErrUnsupportedFormat))
+	_, err = ImportAuthData(nil, "pulumi")
+	g.Expect(err).To(gomega.MatchError(ErrUnsupportedFormat))
+}
@@ -0,0 +1,381 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// DefaultFilePollInterval is how often a FileStore checks its file for changes while it is watched
+var DefaultFilePollInterval = time.Second
+
+// NewFromFile returns an AuthMapper which reads and writes the aws-auth configmap in a manifest file
+// instead of a cluster, see FileStore. Backups, restores and rollbacks need a cluster and fail with
+// ErrClusterRequired.
+func NewFromFile(path string, isCommandline bool, opts ...Option) *AuthMapper {
+	return New(nil, isCommandline, append(opts, WithStore(NewFileStore(path, opts...)))...)
+}
+
+// FileStore stores the aws-auth configmap in a manifest file, which may hold other documents and is
+// created when it does not exist. Only the mapRoles, mapUsers and mapAccounts data and the metadata
+// of the configmap are written, the other documents of the file are left as they are and the other data
+// of the configmap and the comments of unchanged fields are preserved. Its revision is a hash of the file content.
+type FileStore struct {
+	path         string
+	ref          configMapRef
+	pollInterval time.Duration
+}
+
+// NewFileStore returns a store for the aws-auth configmap in the manifest file at path, WithNamespace
+// and WithConfigMapName select the configmap among the documents of the file
+func NewFileStore(path string, opts ...Option) *FileStore {
+	return &FileStore{path: path, ref: newConfigMapRef(opts), pollInterval: DefaultFilePollInterval}
+}
+
+// manifestConfigMap are the fields of a configmap manifest read by the file store
+type manifestConfigMap struct {
+	Metadata struct {
+		Labels      map[string]string `yaml:"labels"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	Data map[string]string `yaml:"data"`
+}
+
+// newManifest is the document added to a file which has no aws-auth configmap yet
+const newManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %v
+  namespace: %v
+`
+
+// Load reads the configmap from the file
+func (s *FileStore) Load(_ context.Context) (*v1.ConfigMap, string, error) {
+	content, revision, err := s.read()
+	if err != nil {
+		return nil, "", err
+	}
+
+	cm, err := s.decode(content)
+	if err != nil {
+		return nil, "", err
+	}
+	return cm, revision, nil
+}
+
+// Save edits the configmap in the file
+func (s *FileStore) Save(_ context.Context, cm *v1.ConfigMap, revision string, dryRun bool) (string, error) {
+	content, current, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	if revision != current {
+		return "", errors.NewConflict(v1.Resource("configmaps"), s.ref.name, fmt.Errorf("%v was modified since it was read", s.path))
+	}
+
+	segments, err := splitManifest(s.path, content)
+	if err != nil {
+		return "", err
+	}
+	segment := findSegment(segments, s.ref)
+	if segment == nil {
+		doc := &yaml.Node{}
+		if err := yaml.Unmarshal([]byte(fmt.Sprintf(newManifest, s.ref.name, s.ref.namespace)), doc); err != nil {
+			return "", err
+		}
+		segment = &manifestSegment{docs: []*yaml.Node{doc}}
+		if len(content) > 0 {
+			segment.separator = []byte("---\n")
+			if !bytes.HasSuffix(content, []byte("\n")) {
+				segment.separator = []byte("\n---\n")
+			}
+		}
+		segments = append(segments, segment)
+	}
+
+	root := findConfigMap(segment.docs, s.ref).Content[0]
+	metadata := mappingValue(root, "metadata")
+	syncStrings(metadata, "labels", cm.Labels, nil)
+	syncStrings(metadata, "annotations", cm.Annotations, nil)
+	syncStrings(root, "data", cm.Data, authDataKeys)
+
+	// only the document of the configmap is encoded again, the others are written as they were read
+	var text bytes.Buffer
+	encoder := yaml.NewEncoder(&text)
+	encoder.SetIndent(2)
+	for _, doc := range segment.docs {
+		if err := encoder.Encode(doc); err != nil {
+			return "", err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	segment.text = text.Bytes()
+
+	var buf bytes.Buffer
+	for _, segment := range segments {
+		buf.Write(segment.separator)
+		buf.Write(segment.text)
+	}
+
+	if dryRun {
+		return contentRevision(buf.Bytes()), nil
+	}
+
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(s.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(s.path, buf.Bytes(), mode); err != nil {
+		return "", err
+	}
+	return contentRevision(buf.Bytes()), nil
+}
+
+// Watch polls the file for changes, the configmap is sent when the revision of the file changes and
+// the file still parses. A file which is removed is sent as an empty configmap.
+func (s *FileStore) Watch(ctx context.Context) (<-chan *v1.ConfigMap, error) {
+	_, revision, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *v1.ConfigMap)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			content, current, err := s.read()
+			if err != nil || current == revision {
+				continue
+			}
+			cm, err := s.decode(content)
+			if err != nil {
+				continue
+			}
+			revision = current
+
+			select {
+			case ch <- cm:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// decode returns the configmap of a manifest, empty when the manifest has none
+func (s *FileStore) decode(content []byte) (*v1.ConfigMap, error) {
+	cm := newAuthConfigMap(s.ref)
+
+	docs, err := parseManifest(s.path, content)
+	if err != nil {
+		return nil, err
+	}
+	doc := findConfigMap(docs, s.ref)
+	if doc == nil {
+		return cm, nil
+	}
+
+	var manifest manifestConfigMap
+	if err := doc.Content[0].Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", s.path, err)
+	}
+	cm.Labels = manifest.Metadata.Labels
+	cm.Annotations = manifest.Metadata.Annotations
+	cm.Data = manifest.Data
+	return cm, nil
+}
+
+// read returns the content of the file and its revision, a missing file is empty and has no revision
+func (s *FileStore) read() ([]byte, string, error) {
+	content, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return content, contentRevision(content), nil
+}
+
+func contentRevision(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseManifest parses every document of a manifest file, empty documents are skipped
+func parseManifest(path string, content []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		doc := &yaml.Node{}
+		err := decoder.Decode(doc)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %w", path, err)
+		}
+		if len(doc.Content) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+}
+
+// manifestSegment is the text of a manifest file between two document separators
+type manifestSegment struct {
+	// separator is the --- line the segment starts with, the first segment of a file has none
+	separator []byte
+	text      []byte
+	// docs are the documents parsed from text, usually one
+	docs []*yaml.Node
+}
+
+// splitManifest splits a manifest file at its document separators, so that a document can be written
+// again without encoding the others. Writing the separators and texts of the segments in order
+// reproduces content.
+func splitManifest(path string, content []byte) ([]*manifestSegment, error) {
+	segments := []*manifestSegment{{}}
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if isDocumentSeparator(line) {
+			segments = append(segments, &manifestSegment{separator: line})
+			continue
+		}
+		last := segments[len(segments)-1]
+		last.text = append(last.text, line...)
+	}
+
+	for _, segment := range segments {
+		docs, err := parseManifest(path, segment.text)
+		if err != nil {
+			return nil, err
+		}
+		segment.docs = docs
+	}
+	return segments, nil
+}
+
+// isDocumentSeparator returns true for a --- line which is followed by nothing but a comment. A
+// separator followed by content stays in its segment and is parsed as another document of it.
+func isDocumentSeparator(line []byte) bool {
+	rest, ok := bytes.CutPrefix(line, []byte("---"))
+	if !ok {
+		return false
+	}
+	comment := bytes.TrimLeft(rest, " \t")
+	if len(comment) == len(rest) {
+		// --- must be followed by a space, a comment or the end of the line
+		return len(bytes.TrimSpace(rest)) == 0
+	}
+	return len(bytes.TrimSpace(comment)) == 0 || comment[0] == '#'
+}
+
+// findSegment returns the segment of a manifest file which holds the configmap
+func findSegment(segments []*manifestSegment, ref configMapRef) *manifestSegment {
+	for _, segment := range segments {
+		if findConfigMap(segment.docs, ref) != nil {
+			return segment
+		}
+	}
+	return nil
+}
+
+// findConfigMap returns the document of the configmap, a manifest without a namespace matches too
+func findConfigMap(docs []*yaml.Node, ref configMapRef) *yaml.Node {
+	for _, doc := range docs {
+		root := doc.Content[0]
+		metadata := mappingValue(root, "metadata")
+		namespace := scalarValue(mappingValue(metadata, "namespace"))
+		if scalarValue(mappingValue(root, "kind")) == "ConfigMap" &&
+			scalarValue(mappingValue(metadata, "name")) == ref.name &&
+			(namespace == "" || namespace == ref.namespace) {
+			return doc
+		}
+	}
+	return nil
+}
+
+// syncStrings makes the string map under field of n hold values. When keys is set only those keys
+// are written and the others are left as they are. Values which did not change keep their text.
+func syncStrings(n *yaml.Node, field string, values map[string]string, keys []string) {
+	m := mappingValue(n, field)
+	if m == nil || m.Kind != yaml.MappingNode {
+		if len(values) == 0 {
+			return
+		}
+		m = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingValue(n, field, m)
+	}
+
+	if keys == nil {
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			keys = append(keys, m.Content[i].Value)
+		}
+		var added []string
+		for key := range values {
+			if !slices.Contains(keys, key) {
+				added = append(added, key)
+			}
+		}
+		sort.Strings(added)
+		keys = append(keys, added...)
+	}
+
+	for _, key := range keys {
+		value, ok := values[key]
+		if !ok {
+			deleteMappingKey(m, key)
+			continue
+		}
+		if existing := mappingValue(m, key); existing != nil && existing.Kind == yaml.ScalarNode && existing.Value == value {
+			continue
+		}
+		node := newScalar(value)
+		if strings.Contains(value, "\n") {
+			node.Style = yaml.LiteralStyle
+		}
+		setMappingValue(m, key, node)
+	}
+
+	if len(m.Content) == 0 {
+		deleteMappingKey(n, field)
+	}
+}
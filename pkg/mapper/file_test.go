@@ -0,0 +1,230 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const testManifest = `# cluster bootstrap
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: platform
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: aws-auth
+  namespace: kube-system
+  labels:
+    app.kubernetes.io/managed-by: git # reviewed by platform
+data:
+  mapRoles: |
+    # worker nodes
+    - rolearn: arn:aws:iam::000000000000:role/node-1
+      username: system:node:{{EC2PrivateDNSName}}
+      groups:
+      - system:bootstrappers
+      - system:nodes
+  extra: keep me
+`
+
+func file_MockManifest(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "aws-auth.yaml")
+	gomega.Expect(os.WriteFile(path, []byte(content), 0600)).To(gomega.Succeed())
+	return path
+}
+
+func TestFileMapper_UpsertRemove(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	path := file_MockManifest(t, testManifest)
+	mapper := NewFromFile(path, true)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapUsers: true,
+		UserARN:  "arn:aws:iam::000000000000:user/alice",
+		Username: "alice",
+		Groups:   []string{"viewers"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, err := mapper.Get(&MapperArguments{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(auth.MapUsers).To(gomega.HaveLen(1))
+
+	content, err := os.ReadFile(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(content)).To(gomega.ContainSubstring("# cluster bootstrap\napiVersion: v1\nkind: Namespace"))
+	g.Expect(string(content)).To(gomega.ContainSubstring("app.kubernetes.io/managed-by: git # reviewed by platform"))
+	g.Expect(string(content)).To(gomega.ContainSubstring("    # worker nodes\n    - rolearn: arn:aws:iam::000000000000:role/node-1\n"))
+	g.Expect(string(content)).To(gomega.ContainSubstring("  extra: keep me\n"))
+	g.Expect(string(content)).To(gomega.ContainSubstring("  mapUsers: |\n    - userarn: arn:aws:iam::000000000000:user/alice\n"))
+	g.Expect(string(content)).NotTo(gomega.ContainSubstring(HistoryAnnotation))
+
+	err = mapper.Remove(&MapperArguments{MapUsers: true, UserARN: "arn:aws:iam::000000000000:user/alice"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, err = mapper.Get(&MapperArguments{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapUsers).To(gomega.BeEmpty())
+
+	history, err := mapper.History()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(history.Entries).To(gomega.BeEmpty())
+}
+
+func TestFileMapper_PreservesOtherDocuments(t *testing.T) {
+	g := gomega.NewWithT(t)
+	namespace := `apiVersion: v1
+kind: Namespace
+metadata:
+    name: platform
+    labels: {team: "platform"}
+--- # the configmap
+`
+	service := `--- 
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+    name: 'deployer'
+    namespace: platform
+`
+	path := file_MockManifest(t, namespace+testManifest[len("# cluster bootstrap\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: platform\n---\n"):]+service)
+	mapper := NewFromFile(path, true)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapUsers: true,
+		UserARN:  "arn:aws:iam::000000000000:user/alice",
+		Username: "alice",
+		Groups:   []string{"viewers"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	content, err := os.ReadFile(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(content)).To(gomega.HavePrefix(namespace))
+	g.Expect(string(content)).To(gomega.HaveSuffix(service))
+	g.Expect(string(content)).To(gomega.ContainSubstring("  mapUsers: |\n    - userarn: arn:aws:iam::000000000000:user/alice\n"))
+}
+
+func TestFileMapper_DryRun(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	path := file_MockManifest(t, testManifest)
+	mapper := NewFromFile(path, true)
+
+	for _, mode := range []DryRunMode{DryRunClient, DryRunServer} {
+		plan, err := mapper.Plan(&MapperArguments{
+			OperationType: OperationUpsert,
+			MapRoles:      true,
+			RoleARN:       "arn:aws:iam::000000000000:role/admin",
+			Username:      "admin",
+			Groups:        []string{"admins"},
+			DryRun:        mode,
+		})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(plan.Changes).To(gomega.HaveLen(1))
+	}
+
+	content, err := os.ReadFile(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(content)).To(gomega.Equal(testManifest))
+}
+
+func TestFileMapper_MissingFile(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	path := filepath.Join(t.TempDir(), "aws-auth.yaml")
+	mapper := NewFromFile(path, true)
+
+	auth, err := mapper.Get(&MapperArguments{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.BeEmpty())
+	_, err = os.Stat(path)
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+
+	err = mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	content, err := os.ReadFile(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(content)).To(gomega.HavePrefix("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: aws-auth\n  namespace: kube-system\n"))
+	g.Expect(string(content)).To(gomega.ContainSubstring("  mapAccounts: |\n    - \"111111111111\"\n"))
+}
+
+func TestFileMapper_Lint(t *testing.T) {
+	g := gomega.NewWithT(t)
+	path := file_MockManifest(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: aws-auth\ndata:\n  mapAccounts: |\n    - \"1234\"\n")
+	mapper := NewFromFile(path, true)
+
+	report, err := mapper.Lint()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(lintFindings(report)).To(gomega.Equal([]string{"mapAccounts[0] invalid-account"}))
+
+	path = file_MockManifest(t, "data: [\n")
+	_, err = NewFromFile(path, true).Lint()
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("failed to parse " + path)))
+}
+
+func TestFileStore_Conflict(t *testing.T) {
+	g := gomega.NewWithT(t)
+	path := file_MockManifest(t, testManifest)
+	store := NewFileStore(path)
+
+	cm, revision, err := store.Load(context.Background())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data["extra"]).To(gomega.Equal("keep me"))
+
+	g.Expect(os.WriteFile(path, []byte(testManifest+"\n"), 0600)).To(gomega.Succeed())
+	_, err = store.Save(context.Background(), cm, revision, false)
+	g.Expect(errors.IsConflict(err)).To(gomega.BeTrue())
+}
+
+func TestFileMapper_ClusterRequired(t *testing.T) {
+	g := gomega.NewWithT(t)
+	mapper := NewFromFile(file_MockManifest(t, testManifest), true)
+
+	_, err := mapper.ListBackups()
+	g.Expect(err).To(gomega.MatchError(ErrClusterRequired))
+	_, err = mapper.Restore("20240101T000000", RestoreOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrClusterRequired))
+	_, err = mapper.Rollback(1, RestoreOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrClusterRequired))
+}
+
+func TestFileMapper_CustomConfigMap(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	path := file_MockManifest(t, testManifest)
+	mapper := NewFromFile(path, true, WithConfigMapName("aws-auth-staging"))
+
+	err := mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	content, err := os.ReadFile(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(content)).To(gomega.HavePrefix(testManifest[:len(testManifest)-len("  extra: keep me\n")]))
+	g.Expect(string(content)).To(gomega.ContainSubstring("---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: aws-auth-staging\n  namespace: kube-system\n"))
+}
@@ -15,31 +15,54 @@ limitations under the License.
 
 package mapper
 
-// Upsert update or inserts by rolearn
+import "context"
+
+// Get returns the mappings of the aws-auth configmap, limited to the mappings selected by the
+// map types, ARNs, username, account and groups of args when any of them are set
 func (b *AuthMapper) Get(args *MapperArguments) (AwsAuthData, error) {
+	return b.GetWithContext(context.Background(), args)
+}
+
+// GetWithContext is like Get but uses the given context for the API calls and retries
+func (b *AuthMapper) GetWithContext(ctx context.Context, args *MapperArguments) (AwsAuthData, error) {
 	args.IsGlobal = true
-	args.Validate()
+	if err := args.Validate(); err != nil {
+		return AwsAuthData{}, err
+	}
+
+	var (
+		authData AwsAuthData
+		err      error
+	)
 
 	if args.WithRetries {
-		out, err := WithRetry(func() (interface{}, error) {
-			return b.getAuth()
+		out, err := b.withRetry(ctx, func() (interface{}, error) {
+			return b.getAuth(ctx)
 		}, args)
 		if err != nil {
 			return AwsAuthData{}, err
 		}
-		return out.(AwsAuthData), nil
+		authData = out.(AwsAuthData)
+	} else {
+		authData, err = b.getAuth(ctx)
+		if err != nil {
+			return AwsAuthData{}, err
+		}
 	}
 
-	return b.getAuth()
+	if filter := args.Filter(); !filter.IsEmpty() {
+		return authData.Filter(filter), nil
+	}
+	return authData, nil
 }
 
-func (b *AuthMapper) getAuth() (AwsAuthData, error) {
+func (b *AuthMapper) getAuth(ctx context.Context) (AwsAuthData, error) {
 
 	// Read the config map and return an AuthMap
-	authData, _, err := ReadAuthMap(b.KubernetesClient)
+	cm, _, err := b.store().Load(ctx)
 	if err != nil {
 		return AwsAuthData{}, err
 	}
 
-	return authData, nil
+	return decodeAuthData(cm)
 }
@@ -32,7 +32,7 @@ func TestMapper_Get(t *testing.T) {
 
 	err := mapper.Upsert(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-2",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-2",
 		Username: "system:node:{{EC2PrivateDNSName}}",
 		Groups:   []string{"system:bootstrappers", "system:nodes"},
 	})
@@ -40,7 +40,7 @@ func TestMapper_Get(t *testing.T) {
 
 	err = mapper.Upsert(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-2",
+		UserARN:  "arn:aws:iam::000000000000:user/user-2",
 		Username: "admin",
 		Groups:   []string{"system:masters"},
 	})
@@ -98,3 +98,34 @@ func TestMapper_GetRetryFail(t *testing.T) {
 	g.Expect(err.Error()).To(gomega.ContainSubstring("cannot unmarshal"))
 
 }
+
+func TestMapper_GetFiltered(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMapWithAccounts(client)
+
+	data, err := mapper.Get(&MapperArguments{
+		OperationType: OperationGet,
+		Format:        FormatTable,
+		MapRoles:      true,
+		Groups:        []string{"system:nodes"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(data.MapRoles).NotTo(gomega.BeEmpty())
+	g.Expect(data.MapUsers).To(gomega.BeEmpty())
+	g.Expect(data.MapAccounts).To(gomega.BeEmpty())
+	for _, r := range data.MapRoles {
+		g.Expect(r.Groups).To(gomega.ContainElement("system:nodes"))
+	}
+
+	data, err = mapper.Get(&MapperArguments{
+		OperationType: OperationGet,
+		Format:        FormatTable,
+		MapAccounts:   true,
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(data.MapRoles).To(gomega.BeEmpty())
+	g.Expect(data.MapAccounts).NotTo(gomega.BeEmpty())
+}
@@ -0,0 +1,244 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// HistoryAnnotation holds the change history of the aws-auth configmap as a json list, newest first
+	HistoryAnnotation = "aws-auth.keikoproj.io/history"
+	// HistoryLength is the number of changes kept in the change history
+	HistoryLength = 20
+
+	unknownActor = "unknown"
+)
+
+// HistoryEntry records a change of the aws-auth configmap
+type HistoryEntry struct {
+	Revision  int           `json:"revision"`
+	Time      time.Time     `json:"time"`
+	User      string        `json:"user"`
+	Operation OperationType `json:"operation"`
+	// PreviousHash and Hash are the content hashes of the mapRoles, mapUsers and mapAccounts before
+	// and after the change
+	PreviousHash string `json:"previousHash"`
+	Hash         string `json:"hash"`
+	// Backup is the ID of the backup of the previous content, empty when none was taken
+	Backup string `json:"backup,omitempty"`
+	// ModifiedOutside is set when the previous content does not match the previous change, because
+	// the configmap was edited by something other than this library in between
+	ModifiedOutside bool `json:"modifiedOutside,omitempty"`
+}
+
+// History is the change history of the aws-auth configmap
+type History struct {
+	// Entries are the recorded changes, newest first
+	Entries []HistoryEntry `json:"entries"`
+	// Modified is set when the current content does not match the newest change
+	Modified bool `json:"modified"`
+}
+
+// History returns the change history recorded on the aws-auth configmap
+func (b *AuthMapper) History() (*History, error) {
+	return b.HistoryWithContext(context.Background())
+}
+
+// HistoryWithContext is like History but uses the given context for the API calls
+func (b *AuthMapper) HistoryWithContext(ctx context.Context) (*History, error) {
+	cm, _, err := b.store().Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := decodeHistory(cm)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &History{Entries: entries}
+	if len(entries) > 0 {
+		history.Modified = entries[0].Hash != contentHash(cm.Data)
+	}
+	return history, nil
+}
+
+// Rollback reverts the last steps changes of the change history by restoring the backup of the content
+// before them, and returns the changes made. Rolling back fails with ErrRevisionNotFound when the history
+// is shorter than steps or the backup was pruned, and with ErrHistoryDiverged when the configmap was
+// modified outside of the history since the newest change, unless opts.Force is set. Like a restore,
+// the rollback is recorded in the history and can itself be rolled back.
+func (b *AuthMapper) Rollback(steps int, opts RestoreOptions) (*Plan, error) {
+	return b.RollbackWithContext(context.Background(), steps, opts)
+}
+
+// RollbackWithContext is like Rollback but uses the given context for the API calls
+func (b *AuthMapper) RollbackWithContext(ctx context.Context, steps int, opts RestoreOptions) (*Plan, error) {
+	if err := b.clusterRequired("rollbacks"); err != nil {
+		return nil, err
+	}
+	if opts.DryRun != DryRunNone && opts.DryRun != DryRunClient && opts.DryRun != DryRunServer {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDryRun, opts.DryRun)
+	}
+
+	history, err := b.HistoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if steps < 1 || steps > len(history.Entries) {
+		return nil, fmt.Errorf("%w: cannot roll back %d steps, the history has %d changes", ErrRevisionNotFound, steps, len(history.Entries))
+	}
+
+	if history.Modified && !opts.Force {
+		return nil, fmt.Errorf("%w: the changes made since revision %d would be lost, use force to roll back anyway", ErrHistoryDiverged, history.Entries[0].Revision)
+	}
+
+	target := history.Entries[steps-1]
+	backup, err := b.revisionBackup(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	fn := b.restoreMutation(backup, opts.Force, plan)
+
+	if opts.DryRun != DryRunNone {
+		return b.planConfigMap(ctx, OperationRollback, fn, opts.DryRun)
+	}
+
+	if err := b.mutateConfigMap(ctx, OperationRollback, fn); err != nil {
+		return nil, err
+	}
+	b.logf("rolled back to the content before revision %d\n", target.Revision)
+	return plan, nil
+}
+
+// revisionBackup returns the backup of the content before the change of entry
+func (b *AuthMapper) revisionBackup(ctx context.Context, entry HistoryEntry) (*Backup, error) {
+	// no backup is taken of empty content
+	if entry.Backup == "" && entry.PreviousHash == contentHash(nil) {
+		return &Backup{data: map[string]string{}}, nil
+	}
+
+	if entry.Backup == "" {
+		return nil, fmt.Errorf("%w: no backup was taken before revision %d", ErrRevisionNotFound, entry.Revision)
+	}
+
+	backup, err := b.GetBackupWithContext(ctx, entry.Backup)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the backup of revision %d is gone: %v", ErrRevisionNotFound, entry.Revision, err)
+	}
+
+	if contentHash(backup.data) != entry.PreviousHash {
+		return nil, fmt.Errorf("%w: backup %v does not match the content before revision %d", ErrRevisionNotFound, backup.ID, entry.Revision)
+	}
+	return backup, nil
+}
+
+// recordHistory adds an entry for the change from original to cm to the history annotation of cm. Like
+// backups, the history is only recorded in a cluster, a manifest file is versioned by its own means and
+// has no identity to record.
+func (b *AuthMapper) recordHistory(ctx context.Context, op OperationType, original, cm *v1.ConfigMap, backup *v1.ConfigMap) error {
+	if b.KubernetesClient == nil {
+		return nil
+	}
+
+	entries, err := decodeHistory(original)
+	if err != nil {
+		return err
+	}
+
+	entry := HistoryEntry{
+		Revision:     1,
+		Time:         time.Now().UTC(),
+		User:         b.actor(ctx),
+		Operation:    op,
+		PreviousHash: contentHash(original.Data),
+		Hash:         contentHash(cm.Data),
+	}
+	if backup != nil {
+		entry.Backup = strings.TrimPrefix(backup.Name, b.backupNamePrefix())
+	}
+	if len(entries) > 0 {
+		entry.Revision = entries[0].Revision + 1
+		entry.ModifiedOutside = entries[0].Hash != entry.PreviousHash
+	}
+
+	entries = append([]HistoryEntry{entry}, entries[:min(len(entries), HistoryLength-1)]...)
+	value, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	if cm.Annotations == nil {
+		cm.Annotations = make(map[string]string)
+	}
+	cm.Annotations[HistoryAnnotation] = string(value)
+	return nil
+}
+
+// decodeHistory returns the entries of the history annotation of cm, newest first
+func decodeHistory(cm *v1.ConfigMap) ([]HistoryEntry, error) {
+	value, ok := cm.Annotations[HistoryAnnotation]
+	if !ok || value == "" {
+		return nil, nil
+	}
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse annotation %v: %w", HistoryAnnotation, err)
+	}
+	return entries, nil
+}
+
+// actor returns the user recorded in the history, which is Actor or the identity of the kubernetes client
+func (b *AuthMapper) actor(ctx context.Context) string {
+	if b.Actor != "" {
+		return b.Actor
+	}
+
+	review, err := b.KubernetesClient.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil || review.Status.UserInfo.Username == "" {
+		return unknownActor
+	}
+	return review.Status.UserInfo.Username
+}
+
+// contentHash returns the hash of the mapRoles, mapUsers and mapAccounts of a configmap, keys
+// which are missing or blank hash the same
+func contentHash(data map[string]string) string {
+	h := sha256.New()
+	for _, key := range authDataKeys {
+		value := data[key]
+		if strings.TrimSpace(value) == "" {
+			value = ""
+		}
+		fmt.Fprintf(h, "%v\x00%v\x00", key, value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,216 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMapper_History(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	_, original, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	history, err := mapper.History()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(history.Entries).To(gomega.BeEmpty())
+	g.Expect(history.Modified).To(gomega.BeFalse())
+
+	err = mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	mapper.Actor = "alice"
+	err = mapper.RemoveByUsername(&MapperArguments{Username: "admin"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	history, err = mapper.History()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(history.Modified).To(gomega.BeFalse())
+	g.Expect(history.Entries).To(gomega.HaveLen(2))
+
+	newest, oldest := history.Entries[0], history.Entries[1]
+	g.Expect(newest.Revision).To(gomega.Equal(2))
+	g.Expect(newest.Operation).To(gomega.Equal(OperationRemoveByUsername))
+	g.Expect(newest.User).To(gomega.Equal("alice"))
+	g.Expect(newest.Backup).NotTo(gomega.BeEmpty())
+	g.Expect(newest.PreviousHash).To(gomega.Equal(oldest.Hash))
+	g.Expect(newest.ModifiedOutside).To(gomega.BeFalse())
+
+	// the fake client does not know who it is
+	g.Expect(oldest.Revision).To(gomega.Equal(1))
+	g.Expect(oldest.Operation).To(gomega.Equal(OperationUpsert))
+	g.Expect(oldest.User).To(gomega.Equal(unknownActor))
+	g.Expect(oldest.PreviousHash).To(gomega.Equal(contentHash(original.Data)))
+
+	// writes which bypass the library are detected
+	auth, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	auth.MapAccounts = nil
+	g.Expect(UpdateAuthMap(client, auth, cm)).To(gomega.Succeed())
+
+	history, err = mapper.History()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(history.Modified).To(gomega.BeTrue())
+
+	err = mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "222222222222"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	history, err = mapper.History()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(history.Modified).To(gomega.BeFalse())
+	g.Expect(history.Entries[0].ModifiedOutside).To(gomega.BeTrue())
+}
+
+func TestMapper_HistoryLength(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	mapper.Backups.Disabled = true
+	create_MockConfigMap(client)
+
+	for i := 0; i < HistoryLength/2+1; i++ {
+		err := mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		err = mapper.Remove(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+
+	history, err := mapper.History()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(history.Entries).To(gomega.HaveLen(HistoryLength))
+	g.Expect(history.Entries[0].Revision).To(gomega.Equal(HistoryLength + 2))
+}
+
+func TestMapper_Rollback(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	_, original, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	err = mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	err = mapper.RemoveByUsername(&MapperArguments{Username: "admin"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	updates := count_Updates(client)
+	plan, err := mapper.Rollback(2, RestoreOptions{DryRun: DryRunClient})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(2))
+	g.Expect(count_Updates(client)).To(gomega.Equal(updates))
+
+	_, err = mapper.Rollback(3, RestoreOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrRevisionNotFound))
+	_, err = mapper.Rollback(0, RestoreOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrRevisionNotFound))
+
+	plan, err = mapper.Rollback(2, RestoreOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(2))
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data).To(gomega.Equal(original.Data))
+
+	// the rollback is recorded and can itself be rolled back
+	history, err := mapper.History()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(history.Entries).To(gomega.HaveLen(3))
+	g.Expect(history.Entries[0].Operation).To(gomega.Equal(OperationRollback))
+
+	_, err = mapper.Rollback(1, RestoreOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"111111111111"}))
+	g.Expect(auth.MapUsers).To(gomega.BeEmpty())
+}
+
+func TestMapper_RollbackBeyondRetention(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	mapper.Backups.Retain = 1
+	create_MockConfigMap(client)
+
+	_, original, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	for _, account := range []string{"111111111111", "222222222222", "333333333333"} {
+		err := mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: account})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+
+	// the backups of the history are kept beyond the retention
+	_, err = mapper.Rollback(3, RestoreOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data).To(gomega.Equal(original.Data))
+}
+
+func TestMapper_RollbackDiverged(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	err := mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	auth.MapAccounts = append(auth.MapAccounts, "222222222222")
+	g.Expect(UpdateAuthMap(client, auth, cm)).To(gomega.Succeed())
+
+	_, err = mapper.Rollback(1, RestoreOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrHistoryDiverged))
+
+	_, err = mapper.Rollback(1, RestoreOptions{Force: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	auth, _, err = ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapAccounts).To(gomega.BeEmpty())
+}
+
+func TestMapper_RollbackWithoutBackup(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	mapper.Backups.Disabled = true
+	create_MockConfigMap(client)
+
+	err := mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, err = mapper.Rollback(1, RestoreOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrRevisionNotFound))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("no backup was taken before revision 1"))
+}
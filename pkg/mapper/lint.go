@@ -0,0 +1,365 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Severity is the severity of a lint finding
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+	// SeverityNone is a threshold no finding reaches
+	SeverityNone Severity = "none"
+)
+
+// severities are the severities in increasing order
+var severities = []Severity{SeverityInfo, SeverityWarning, SeverityError, SeverityNone}
+
+// ParseSeverity returns the severity with the given name
+func ParseSeverity(s string) (Severity, error) {
+	if !slices.Contains(severities, Severity(s)) {
+		return "", fmt.Errorf("%w: %q, must be one of info, warning, error, none", ErrInvalidSeverity, s)
+	}
+	return Severity(s), nil
+}
+
+// AtLeast returns true if s is as severe as threshold or more
+func (s Severity) AtLeast(threshold Severity) bool {
+	return slices.Index(severities, s) >= slices.Index(severities, threshold)
+}
+
+// IDs of the lint rules
+const (
+	LintInvalidYAML      = "invalid-yaml"
+	LintDuplicateMapping = "duplicate-mapping"
+	LintEmptyUsername    = "empty-username"
+	LintEmptyGroups      = "empty-groups"
+	LintInvalidARN       = "invalid-arn"
+	LintInvalidAccount   = "invalid-account"
+	LintInvalidTemplate  = "invalid-template"
+	LintSharedUsername   = "shared-username"
+	LintSystemMasters    = "system-masters"
+)
+
+// LintRule describes a check made by lint
+type LintRule struct {
+	ID          string
+	Severity    Severity
+	Description string
+}
+
+// LintRules are the checks made by lint
+var LintRules = []LintRule{
+	{LintInvalidYAML, SeverityError, "mapRoles, mapUsers or mapAccounts is not valid YAML"},
+	{LintDuplicateMapping, SeverityError, "the same ARN or account is mapped more than once, only one of the entries is used"},
+	{LintEmptyUsername, SeverityError, "a role or user is mapped without a username"},
+	{LintEmptyGroups, SeverityWarning, "a role or user is mapped without groups"},
+	{LintInvalidARN, SeverityError, "a role or user ARN is malformed"},
+	{LintInvalidAccount, SeverityError, "an account ID is not 12 digits"},
+	{LintInvalidTemplate, SeverityError, "a username has a placeholder the authenticator does not support"},
+	{LintSharedUsername, SeverityWarning, "the same username is mapped to different ARNs"},
+	{LintSystemMasters, SeverityWarning, "a role or user is granted system:masters, which bypasses RBAC"},
+}
+
+const systemMastersGroup = "system:masters"
+
+// Finding is a problem found by lint
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Entry locates the problem in the configmap, like mapRoles[2], or mapRoles for the whole document
+	Entry string `json:"entry"`
+	// Name is the name of the mapping, as in Mapping.Name, empty when the document could not be parsed
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+// LintReport is the result of linting the aws-auth configmap
+type LintReport struct {
+	Findings []Finding `json:"findings"`
+
+	// configMap locates the findings in SARIF logs, the aws-auth configmap when it is not set
+	configMap configMapRef
+}
+
+// Check returns an error wrapping ErrLintFindings if any finding is at least as severe as threshold
+func (r *LintReport) Check(threshold Severity) error {
+	var n int
+	for _, f := range r.Findings {
+		if f.Severity.AtLeast(threshold) {
+			n++
+		}
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: %d findings of severity %v or above", ErrLintFindings, n, threshold)
+	}
+	return nil
+}
+
+// Lint reads the aws-auth configmap and reports problems with its mappings
+func (b *AuthMapper) Lint() (*LintReport, error) {
+	return b.LintWithContext(context.Background())
+}
+
+// LintWithContext is like Lint but uses the given context for the API calls
+func (b *AuthMapper) LintWithContext(ctx context.Context) (*LintReport, error) {
+	// documents which fail to parse are reported as findings
+	cm, _, err := b.store().Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := LintConfigMap(cm)
+	report.configMap = b.configMapRef()
+	return report, nil
+}
+
+// LintConfigMap reports problems with the mappings of an aws-auth configmap. Each document is parsed
+// on its own, so the others are still checked when one is not valid YAML.
+func LintConfigMap(cm *v1.ConfigMap) *LintReport {
+	l := &linter{
+		report:    &LintReport{Findings: []Finding{}},
+		arns:      map[string]string{},
+		usernames: map[string]*Mapping{},
+	}
+
+	var authData AwsAuthData
+	for _, doc := range []struct {
+		key string
+		out interface{}
+	}{
+		{"mapRoles", &authData.MapRoles},
+		{"mapUsers", &authData.MapUsers},
+		{"mapAccounts", &authData.MapAccounts},
+	} {
+		if err := yaml.Unmarshal([]byte(cm.Data[doc.key]), doc.out); err != nil {
+			l.add(LintInvalidYAML, doc.key, "", err.Error())
+		}
+	}
+
+	for _, entry := range policyEntries(&authData) {
+		l.lint(entry.name, &entry.mapping)
+	}
+	return l.report
+}
+
+type linter struct {
+	report *LintReport
+	// arns are the entries of the ARNs and accounts seen so far, keyed by kind and canonical ARN
+	arns map[string]string
+	// usernames are the mappings of the usernames seen so far
+	usernames map[string]*Mapping
+}
+
+func (l *linter) lint(entry string, m *Mapping) {
+	name := m.Name()
+
+	if m.Kind == AccountMappingKind {
+		if err := ValidateAccountID(m.Account); err != nil {
+			l.add(LintInvalidAccount, entry, name, err.Error())
+		}
+		l.duplicate(entry, name, name)
+		return
+	}
+
+	var err error
+	if m.Kind == RoleMappingKind {
+		err = ValidateRoleARN(m.ARN)
+	} else {
+		err = ValidateUserARN(m.ARN)
+	}
+	if err != nil {
+		l.add(LintInvalidARN, entry, name, err.Error())
+	}
+
+	// the authenticator ignores the path of roles, so path variants are duplicates too
+	key := name
+	if m.Kind == RoleMappingKind {
+		key = "role/" + CanonicalRoleARN(m.ARN)
+	}
+	l.duplicate(entry, name, key)
+
+	templateErr := ValidateUsernameTemplate(m.Username)
+	switch {
+	case m.Username == "":
+		l.add(LintEmptyUsername, entry, name, fmt.Sprintf("%v has no username", m.ARN))
+	case templateErr != nil:
+		l.add(LintInvalidTemplate, entry, name, templateErr.Error())
+	case !strings.Contains(m.Username, "{{"):
+		// templated usernames are expected to be shared, like the node username
+		if other, ok := l.usernames[m.Username]; ok && other.ARN != m.ARN {
+			l.add(LintSharedUsername, entry, name, fmt.Sprintf("username %v is also mapped to %v", m.Username, other.ARN))
+		} else if !ok {
+			l.usernames[m.Username] = m
+		}
+	}
+
+	if len(m.Groups) == 0 {
+		l.add(LintEmptyGroups, entry, name, fmt.Sprintf("%v has no groups", m.ARN))
+	}
+	if slices.Contains(m.Groups, systemMastersGroup) {
+		l.add(LintSystemMasters, entry, name, fmt.Sprintf("%v is granted %v", m.ARN, systemMastersGroup))
+	}
+}
+
+// duplicate reports the entry when key was seen before
+func (l *linter) duplicate(entry, name, key string) {
+	if first, ok := l.arns[key]; ok {
+		l.add(LintDuplicateMapping, entry, name, fmt.Sprintf("%v is already mapped by %v", name, first))
+		return
+	}
+	l.arns[key] = entry
+}
+
+func (l *linter) add(rule, entry, name, message string) {
+	severity := SeverityWarning
+	for _, r := range LintRules {
+		if r.ID == rule {
+			severity = r.Severity
+		}
+	}
+
+	l.report.Findings = append(l.report.Findings, Finding{
+		Rule:     rule,
+		Severity: severity,
+		Entry:    entry,
+		Name:     name,
+		Message:  message,
+	})
+}
+
+// SARIF is a SARIF 2.1.0 log, the static analysis format understood by code scanning tools
+type SARIF struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+// SARIFRun is a run of a tool in a SARIF log
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+// SARIFTool describes the tool and its rules in a SARIF log
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+// SARIFDriver is the tool component of a SARIF log
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+// SARIFRule is the metadata of a rule in a SARIF log
+type SARIFRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     SARIFMessage       `json:"shortDescription"`
+	DefaultConfiguration SARIFConfiguration `json:"defaultConfiguration"`
+}
+
+// SARIFConfiguration is the default level of a rule in a SARIF log
+type SARIFConfiguration struct {
+	Level string `json:"level"`
+}
+
+// SARIFMessage is a text in a SARIF log
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// SARIFResult is a finding in a SARIF log
+type SARIFResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   SARIFMessage    `json:"message"`
+	Locations []SARIFLocation `json:"locations"`
+}
+
+// SARIFLocation locates a finding in a SARIF log, the configmap has no file so the location is logical
+type SARIFLocation struct {
+	LogicalLocations []SARIFLogicalLocation `json:"logicalLocations"`
+}
+
+// SARIFLogicalLocation is an entry of the configmap in a SARIF log
+type SARIFLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// SARIF returns the report as a SARIF log
+func (r *LintReport) SARIF() *SARIF {
+	driver := SARIFDriver{
+		Name:           "aws-auth",
+		InformationURI: "https://github.com/keikoproj/aws-auth",
+	}
+	for _, rule := range LintRules {
+		driver.Rules = append(driver.Rules, SARIFRule{
+			ID:                   rule.ID,
+			ShortDescription:     SARIFMessage{Text: rule.Description},
+			DefaultConfiguration: SARIFConfiguration{Level: sarifLevel(rule.Severity)},
+		})
+	}
+
+	ref := r.configMap
+	if ref.name == "" {
+		ref = newConfigMapRef(nil)
+	}
+
+	results := []SARIFResult{}
+	for _, f := range r.Findings {
+		results = append(results, SARIFResult{
+			RuleID:  f.Rule,
+			Level:   sarifLevel(f.Severity),
+			Message: SARIFMessage{Text: f.Message},
+			Locations: []SARIFLocation{{
+				LogicalLocations: []SARIFLogicalLocation{{
+					Name:               f.Entry,
+					FullyQualifiedName: fmt.Sprintf("%v/%v", ref, f.Entry),
+					Kind:               "element",
+				}},
+			}},
+		})
+	}
+
+	return &SARIF{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []SARIFRun{{Tool: SARIFTool{Driver: driver}, Results: results}},
+	}
+}
+
+// sarifLevel returns the SARIF level of a severity
+func sarifLevel(s Severity) string {
+	if s == SeverityInfo {
+		return "note"
+	}
+	return string(s)
+}
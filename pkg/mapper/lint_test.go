@@ -0,0 +1,178 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const lintMapRoles = `- rolearn: arn:aws:iam::000000000000:role/node
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+  - system:bootstrappers
+  - system:nodes
+- rolearn: arn:aws:iam::000000000000:role/team/node
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+  - system:nodes
+- rolearn: arn:aws:iam::000000000000:role/admin
+  username: admin
+  groups:
+  - system:masters
+- rolearn: arn:aws:iam::000000000000:user/ci
+  username: ci
+- rolearn: arn:aws:iam::000000000000:role/dev
+  username: "{{RoleName}}"
+  groups:
+  - dev
+`
+
+const lintMapUsers = `- userarn: arn:aws:iam::000000000000:user/alice
+  username: admin
+  groups:
+  - viewers
+- userarn: arn:aws:iam::000000000000:user/bob
+  groups:
+  - viewers
+- userarn: arn:aws:iam::000000000000:root
+  username: root
+  groups:
+  - viewers
+`
+
+func lintFindings(report *LintReport) []string {
+	var out []string
+	for _, f := range report.Findings {
+		out = append(out, f.Entry+" "+f.Rule)
+	}
+	return out
+}
+
+func TestLintConfigMap(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	cm := &v1.ConfigMap{Data: map[string]string{
+		"mapRoles":    lintMapRoles,
+		"mapUsers":    lintMapUsers,
+		"mapAccounts": "- \"111111111111\"\n- \"1234\"\n- \"111111111111\"\n",
+	}}
+
+	report := LintConfigMap(cm)
+	g.Expect(lintFindings(report)).To(gomega.Equal([]string{
+		"mapRoles[1] duplicate-mapping",
+		"mapRoles[2] system-masters",
+		"mapRoles[3] invalid-arn",
+		"mapRoles[3] empty-groups",
+		"mapRoles[4] invalid-template",
+		"mapUsers[0] shared-username",
+		"mapUsers[1] empty-username",
+		"mapAccounts[1] invalid-account",
+		"mapAccounts[2] duplicate-mapping",
+	}))
+
+	g.Expect(report.Findings[0].Severity).To(gomega.Equal(SeverityError))
+	g.Expect(report.Findings[0].Name).To(gomega.Equal("role/arn:aws:iam::000000000000:role/team/node"))
+	g.Expect(report.Findings[0].Message).To(gomega.ContainSubstring("already mapped by mapRoles[0]"))
+	g.Expect(report.Findings[1].Severity).To(gomega.Equal(SeverityWarning))
+	g.Expect(report.Findings[5].Message).To(gomega.Equal("username admin is also mapped to arn:aws:iam::000000000000:role/admin"))
+}
+
+func TestLintConfigMap_InvalidYAML(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	cm := &v1.ConfigMap{Data: map[string]string{
+		"mapRoles": "- rolearn: [\n",
+		"mapUsers": lintMapUsers,
+	}}
+
+	report := LintConfigMap(cm)
+	g.Expect(report.Findings[0].Rule).To(gomega.Equal(LintInvalidYAML))
+	g.Expect(report.Findings[0].Entry).To(gomega.Equal("mapRoles"))
+	g.Expect(report.Findings[0].Name).To(gomega.BeEmpty())
+
+	// the documents which parse are still checked
+	g.Expect(lintFindings(report)[1:]).To(gomega.Equal([]string{"mapUsers[1] empty-username"}))
+
+	g.Expect(LintConfigMap(&v1.ConfigMap{}).Findings).To(gomega.BeEmpty())
+}
+
+func TestLintReport_Check(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	report := &LintReport{Findings: []Finding{{Severity: SeverityWarning}, {Severity: SeverityInfo}}}
+	g.Expect(report.Check(SeverityError)).To(gomega.Succeed())
+	g.Expect(report.Check(SeverityNone)).To(gomega.Succeed())
+
+	err := report.Check(SeverityInfo)
+	g.Expect(err).To(gomega.MatchError(ErrLintFindings))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("2 findings of severity info or above"))
+	g.Expect(report.Check(SeverityWarning)).To(gomega.MatchError(ErrLintFindings))
+
+	severity, err := ParseSeverity("warning")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(severity).To(gomega.Equal(SeverityWarning))
+	_, err = ParseSeverity("fatal")
+	g.Expect(err).To(gomega.MatchError(ErrInvalidSeverity))
+}
+
+func TestLintReport_SARIF(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	report := &LintReport{Findings: []Finding{
+		{Rule: LintSystemMasters, Severity: SeverityWarning, Entry: "mapRoles[2]", Message: "granted system:masters"},
+		{Rule: "custom", Severity: SeverityInfo, Entry: "mapUsers[0]", Message: "note"},
+	}}
+
+	sarif := report.SARIF()
+	g.Expect(sarif.Version).To(gomega.Equal("2.1.0"))
+	g.Expect(sarif.Runs).To(gomega.HaveLen(1))
+	g.Expect(sarif.Runs[0].Tool.Driver.Rules).To(gomega.HaveLen(len(LintRules)))
+
+	results := sarif.Runs[0].Results
+	g.Expect(results).To(gomega.HaveLen(2))
+	g.Expect(results[0].RuleID).To(gomega.Equal(LintSystemMasters))
+	g.Expect(results[0].Level).To(gomega.Equal("warning"))
+	g.Expect(results[0].Locations[0].LogicalLocations[0].FullyQualifiedName).To(gomega.Equal("kube-system/aws-auth/mapRoles[2]"))
+	g.Expect(results[1].Level).To(gomega.Equal("note"))
+
+	g.Expect((&LintReport{}).SARIF().Runs[0].Results).To(gomega.BeEmpty())
+}
+
+func TestMapper_Lint(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockMalformedConfigMap(client)
+
+	report, err := mapper.Lint()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(lintFindings(report)).To(gomega.Equal([]string{"mapRoles invalid-yaml", "mapUsers invalid-yaml"}))
+
+	client.PrependReactor("get", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	_, err = mapper.LintWithContext(context.Background())
+	g.Expect(err).To(gomega.MatchError("connection refused"))
+}
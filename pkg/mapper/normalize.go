@@ -0,0 +1,137 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Normalize rewrites the role ARNs of the aws-auth configmap into the form the authenticator matches,
+// without an IAM path. Path variants of the same role which are mapped identically are merged, path
+// variants which are mapped to different usernames or groups are reported with ErrConflictingRoleARNs.
+// User ARNs are left unchanged, the authenticator matches them including their path.
+func (b *AuthMapper) Normalize(args *MapperArguments) error {
+	return b.NormalizeWithContext(context.Background(), args)
+}
+
+// NormalizeWithContext is like Normalize but uses the given context for the API calls and retries
+func (b *AuthMapper) NormalizeWithContext(ctx context.Context, args *MapperArguments) error {
+	args.IsGlobal = true
+	args.OperationType = OperationNormalize
+	if err := args.Validate(); err != nil {
+		return err
+	}
+
+	if args.DryRun != DryRunNone {
+		_, err := b.planConfigMap(ctx, OperationNormalize, b.normalizeConfigMap(), args.DryRun)
+		return err
+	}
+
+	if args.WithRetries {
+		_, err := b.withRetry(ctx, func() (interface{}, error) {
+			return nil, b.mutateConfigMap(ctx, OperationNormalize, b.normalizeConfigMap())
+		}, args)
+		return err
+	}
+	return b.mutateConfigMap(ctx, OperationNormalize, b.normalizeConfigMap())
+}
+
+// normalizeConfigMap is like normalizeMutation but also rewrites the role ARNs in the mapRoles document,
+// so that the normalized entries are matched to their text and keep their comments and unknown fields
+func (b *AuthMapper) normalizeConfigMap() configMapMutation {
+	fn := b.normalizeMutation()
+	return func(authData *AwsAuthData, cm *v1.ConfigMap) (bool, error) {
+		renames := map[string]string{}
+		for _, r := range authData.MapRoles {
+			if canonical := CanonicalRoleARN(r.RoleARN); canonical != r.RoleARN {
+				renames[r.RoleARN] = canonical
+			}
+		}
+
+		updated, err := fn(authData)
+		if err != nil || !updated {
+			return updated, err
+		}
+
+		if src, ok := cm.Data["mapRoles"]; ok {
+			mapRoles, err := renameEntries(src, "rolearn", renames)
+			if err != nil {
+				return false, err
+			}
+			cm.Data["mapRoles"] = mapRoles
+		}
+		return true, nil
+	}
+}
+
+// normalizeMutation returns the mutation which strips the path from all role ARNs
+func (b *AuthMapper) normalizeMutation() authMapMutation {
+	return func(authData *AwsAuthData) (bool, error) {
+		newMap, ok, err := b.normalizeRoles(authData.MapRoles)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			b.logf("role ARNs are already normalized, configmap is not changed\n")
+			return false, nil
+		}
+		authData.SetMapRoles(newMap)
+		return true, nil
+	}
+}
+
+func (b *AuthMapper) normalizeRoles(authMaps []*RolesAuthMap) ([]*RolesAuthMap, bool, error) {
+	var newMap []*RolesAuthMap
+	var updated bool
+	var errs []error
+
+	seen := map[string]*RolesAuthMap{}
+	for _, existing := range authMaps {
+		canonical := CanonicalRoleARN(existing.RoleARN)
+
+		if first, ok := seen[canonical]; ok {
+			if first.Username != existing.Username || !slices.Equal(first.Groups, existing.Groups) {
+				errs = append(errs, fmt.Errorf("%w: %v and %v", ErrConflictingRoleARNs, first.RoleARN, existing.RoleARN))
+				continue
+			}
+			b.logf("merged %v into %v\n", existing.RoleARN, canonical)
+			updated = true
+			continue
+		}
+
+		seen[canonical] = existing
+		if canonical != existing.RoleARN {
+			b.logf("rewrote %v to %v\n", existing.RoleARN, canonical)
+			// the entry is copied so that only its ARN differs and the input is left as it is
+			renamed := *existing
+			renamed.RoleARN = canonical
+			newMap = append(newMap, &renamed)
+			updated = true
+			continue
+		}
+		newMap = append(newMap, existing)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, false, err
+	}
+	return newMap, updated, nil
+}
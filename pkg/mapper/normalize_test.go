@@ -0,0 +1,198 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMapper_UpsertIgnorePath(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles:   true,
+		RoleARN:    "arn:aws:iam::000000000000:role/team/x/node-1",
+		Username:   "system:node:{{EC2PrivateDNSName}}",
+		Groups:     []string{"system:nodes"},
+		IgnorePath: true,
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:nodes"}))
+
+	// without IgnorePath a path variant is a separate mapping
+	err = mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/team/x/node-1",
+		Username: "system:node:{{EC2PrivateDNSName}}",
+		Groups:   []string{"system:nodes"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err = ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(2))
+}
+
+func TestMapper_RemoveIgnorePath(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	err := mapper.UpsertMultiple([]*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/team/node-1", "system:node:{{EC2PrivateDNSName}}", nil),
+	}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	err = mapper.Remove(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/other/node-1",
+	})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	err = mapper.Remove(&MapperArguments{
+		MapRoles:   true,
+		RoleARN:    "arn:aws:iam::000000000000:role/other/node-1",
+		IgnorePath: true,
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.BeEmpty())
+}
+
+func TestNormalizeRoles(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	roles := []*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/team/a/node", "system:node:{{EC2PrivateDNSName}}", []string{"system:nodes"}),
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/admin", "admin", []string{"system:masters"}),
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/node", "system:node:{{EC2PrivateDNSName}}", []string{"system:nodes"}),
+		NewRolesAuthMap("not-an-arn", "legacy", nil),
+	}
+
+	mapper := &AuthMapper{}
+	newMap, ok, err := mapper.normalizeRoles(roles)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(newMap).To(gomega.Equal([]*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/node", "system:node:{{EC2PrivateDNSName}}", []string{"system:nodes"}),
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/admin", "admin", []string{"system:masters"}),
+		NewRolesAuthMap("not-an-arn", "legacy", nil),
+	}))
+
+	// the input is not modified
+	g.Expect(roles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/team/a/node"))
+
+	_, ok, err = mapper.normalizeRoles(newMap)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(ok).To(gomega.BeFalse())
+}
+
+func TestMapper_Normalize(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	err := mapper.UpsertMultiple([]*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/eks/admin", "admin", []string{"system:masters"}),
+	}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	plan, err := mapper.Plan(&MapperArguments{OperationType: OperationNormalize})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(2))
+	g.Expect(plan.Changes[0].Action).To(gomega.Equal(ChangeRemove))
+	g.Expect(plan.Changes[0].Name).To(gomega.Equal("role/arn:aws:iam::000000000000:role/eks/admin"))
+	g.Expect(plan.Changes[1].Action).To(gomega.Equal(ChangeAdd))
+	g.Expect(plan.Changes[1].Name).To(gomega.Equal("role/arn:aws:iam::000000000000:role/admin"))
+
+	g.Expect(mapper.Normalize(&MapperArguments{})).To(gomega.Succeed())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(2))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/admin"))
+	g.Expect(auth.MapUsers).To(gomega.HaveLen(1))
+}
+
+func TestMapper_NormalizeConflict(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	err := mapper.UpsertMultiple([]*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/team/node-1", "admin", []string{"system:masters"}),
+	}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	updates := count_Updates(client)
+
+	err = mapper.Normalize(&MapperArguments{})
+	g.Expect(err).To(gomega.MatchError(ErrConflictingRoleARNs))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("arn:aws:iam::000000000000:role/team/node-1 and arn:aws:iam::000000000000:role/node-1"))
+	g.Expect(count_Updates(client)).To(gomega.Equal(updates))
+}
+
+func TestMapper_NormalizeKeepsComments(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+
+	mapRoles := `# break-glass access, see runbook
+- rolearn: arn:aws:iam::000000000000:role/eks/admin # path added by the console
+  username: admin
+  groups:
+  - system:masters
+  team: sre
+# ci deployments
+- rolearn: "arn:aws:iam::000000000000:role/ci"
+  username: ci
+`
+	_, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Create(context.Background(), &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: AwsAuthName, Namespace: AwsAuthNamespace},
+		Data:       map[string]string{"mapRoles": mapRoles},
+	}, metav1.CreateOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(mapper.Normalize(&MapperArguments{})).To(gomega.Succeed())
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cm.Data["mapRoles"]).To(gomega.Equal(strings.Replace(mapRoles, "role/eks/admin", "role/admin", 1)))
+}
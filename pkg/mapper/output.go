@@ -0,0 +1,111 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import "strings"
+
+const (
+	// MappingListAPIVersion is the version of the structured output schema, fields are only
+	// added within a version and any incompatible change results in a new version
+	MappingListAPIVersion = "aws-auth.keikoproj.io/v1"
+	MappingListKind       = "MappingList"
+)
+
+// MappingKind identifies which section of the aws-auth configmap a Mapping belongs to
+type MappingKind string
+
+const (
+	RoleMappingKind    MappingKind = "RoleMapping"
+	UserMappingKind    MappingKind = "UserMapping"
+	AccountMappingKind MappingKind = "AccountMapping"
+)
+
+// MappingList is the structured representation of the aws-auth configmap used by the json and yaml formats
+type MappingList struct {
+	APIVersion string    `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string    `json:"kind" yaml:"kind"`
+	Items      []Mapping `json:"items" yaml:"items"`
+}
+
+// Mapping is a single role, user or account mapping. ARN and Username are empty for
+// account mappings, Account is the AWS account of the ARN or the mapped account.
+type Mapping struct {
+	Kind     MappingKind `json:"kind" yaml:"kind"`
+	ARN      string      `json:"arn" yaml:"arn"`
+	Username string      `json:"username" yaml:"username"`
+	Groups   []string    `json:"groups" yaml:"groups"`
+	Account  string      `json:"account" yaml:"account"`
+}
+
+// Name returns the kind qualified name of the mapping, e.g. role/arn:aws:iam::555555555555:role/node
+func (m Mapping) Name() string {
+	switch m.Kind {
+	case RoleMappingKind:
+		return "role/" + m.ARN
+	case UserMappingKind:
+		return "user/" + m.ARN
+	default:
+		return "account/" + m.Account
+	}
+}
+
+// Mappings returns the mappings of the aws-auth data as a MappingList, roles first, then users and accounts
+func (m *AwsAuthData) Mappings() *MappingList {
+	list := &MappingList{
+		APIVersion: MappingListAPIVersion,
+		Kind:       MappingListKind,
+		Items:      []Mapping{},
+	}
+
+	for _, r := range m.MapRoles {
+		list.Items = append(list.Items, newMapping(RoleMappingKind, r.RoleARN, r.Username, r.Groups))
+	}
+
+	for _, u := range m.MapUsers {
+		list.Items = append(list.Items, newMapping(UserMappingKind, u.UserARN, u.Username, u.Groups))
+	}
+
+	for _, a := range m.MapAccounts {
+		list.Items = append(list.Items, Mapping{
+			Kind:    AccountMappingKind,
+			Groups:  []string{},
+			Account: a,
+		})
+	}
+
+	return list
+}
+
+func newMapping(kind MappingKind, arn, username string, groups []string) Mapping {
+	g := make([]string, len(groups))
+	copy(g, groups)
+	return Mapping{
+		Kind:     kind,
+		ARN:      arn,
+		Username: username,
+		Groups:   g,
+		Account:  arnAccount(arn),
+	}
+}
+
+// arnAccount returns the account field of an ARN, or an empty string if it has none
+func arnAccount(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[4]
+}
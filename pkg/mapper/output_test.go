@@ -0,0 +1,62 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestMappings(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	data := &AwsAuthData{
+		MapRoles:    []*RolesAuthMap{NewRolesAuthMap("arn:aws:iam::555555555555:role/node", "system:node:{{EC2PrivateDNSName}}", []string{"system:nodes"})},
+		MapUsers:    []*UsersAuthMap{NewUsersAuthMap("arn:aws:iam::666666666666:user/admin", "admin", nil)},
+		MapAccounts: []string{"777777777777"},
+	}
+
+	list := data.Mappings()
+	g.Expect(list.APIVersion).To(gomega.Equal(MappingListAPIVersion))
+	g.Expect(list.Kind).To(gomega.Equal(MappingListKind))
+	g.Expect(list.Items).To(gomega.Equal([]Mapping{
+		{Kind: RoleMappingKind, ARN: "arn:aws:iam::555555555555:role/node", Username: "system:node:{{EC2PrivateDNSName}}", Groups: []string{"system:nodes"}, Account: "555555555555"},
+		{Kind: UserMappingKind, ARN: "arn:aws:iam::666666666666:user/admin", Username: "admin", Groups: []string{}, Account: "666666666666"},
+		{Kind: AccountMappingKind, Groups: []string{}, Account: "777777777777"},
+	}))
+
+	g.Expect(list.Items[0].Name()).To(gomega.Equal("role/arn:aws:iam::555555555555:role/node"))
+	g.Expect(list.Items[1].Name()).To(gomega.Equal("user/arn:aws:iam::666666666666:user/admin"))
+	g.Expect(list.Items[2].Name()).To(gomega.Equal("account/777777777777"))
+}
+
+func TestMappings_StableJSONSchema(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	data := &AwsAuthData{
+		MapAccounts: []string{"777777777777"},
+	}
+
+	out, err := json.Marshal(data.Mappings())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(out)).To(gomega.Equal(`{"apiVersion":"aws-auth.keikoproj.io/v1","kind":"MappingList","items":[{"kind":"AccountMapping","arn":"","username":"","groups":[],"account":"777777777777"}]}`))
+
+	out, err = json.Marshal((&AwsAuthData{}).Mappings())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(out)).To(gomega.Equal(`{"apiVersion":"aws-auth.keikoproj.io/v1","kind":"MappingList","items":[]}`))
+}
@@ -0,0 +1,203 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// ChangeAction is the kind of change made to a mapping
+type ChangeAction string
+
+const (
+	ChangeAdd    ChangeAction = "add"
+	ChangeModify ChangeAction = "modify"
+	ChangeRemove ChangeAction = "remove"
+)
+
+// Plan is the change set of a mutating operation
+type Plan struct {
+	Changes []Change `json:"changes"`
+}
+
+// Change is a single added, modified or removed mapping. Before is nil for added mappings
+// and After is nil for removed mappings.
+type Change struct {
+	Action ChangeAction  `json:"action"`
+	Kind   MappingKind   `json:"kind"`
+	Name   string        `json:"name"`
+	Before *Mapping      `json:"before,omitempty"`
+	After  *Mapping      `json:"after,omitempty"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is the before and after value of a modified field of a mapping
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// IsEmpty returns true if the plan has no changes
+func (p *Plan) IsEmpty() bool {
+	return len(p.Changes) == 0
+}
+
+// Plan returns the changes the operation described by args would make, without making them.
+// args.OperationType selects the operation, one of upsert, remove, remove-by-username or normalize.
+// With args.DryRun set to DryRunServer the update is also validated by the API server.
+func (b *AuthMapper) Plan(args *MapperArguments) (*Plan, error) {
+	return b.PlanWithContext(context.Background(), args)
+}
+
+// PlanWithContext is like Plan but uses the given context for the API calls
+func (b *AuthMapper) PlanWithContext(ctx context.Context, args *MapperArguments) (*Plan, error) {
+	if args.OperationType == OperationRemoveByUsername || args.OperationType == OperationNormalize {
+		args.IsGlobal = true
+	}
+	if err := args.Validate(); err != nil {
+		return nil, err
+	}
+
+	var fn authMapMutation
+	switch args.OperationType {
+	case OperationUpsert:
+		if err := args.validateMapping(); err != nil {
+			return nil, err
+		}
+		fn = b.upsertMutation(args)
+	case OperationRemove:
+		fn = b.removeMutation(args)
+	case OperationRemoveByUsername:
+		fn = b.removeByUserMutation(args)
+	case OperationNormalize:
+		fn = b.normalizeMutation()
+	default:
+		return nil, fmt.Errorf("cannot plan operation %q", args.OperationType)
+	}
+
+	mode := args.DryRun
+	if mode == DryRunNone {
+		mode = DryRunClient
+	}
+
+	return b.planMutation(ctx, args.OperationType, fn, mode)
+}
+
+// planMutation is like planConfigMap for a mutation of the AwsAuthData only
+func (b *AuthMapper) planMutation(ctx context.Context, op OperationType, fn authMapMutation, mode DryRunMode) (*Plan, error) {
+	return b.planConfigMap(ctx, op, func(authData *AwsAuthData, _ *v1.ConfigMap) (bool, error) {
+		return fn(authData)
+	}, mode)
+}
+
+// planConfigMap applies fn to the aws-auth configmap without persisting it and returns the changes.
+// A missing configmap is not created, with DryRunServer the create or update is sent with the
+// DryRun option instead.
+func (b *AuthMapper) planConfigMap(ctx context.Context, op OperationType, fn configMapMutation, mode DryRunMode) (*Plan, error) {
+	fn = b.enforcePolicies(b.guardProtection(op, fn))
+
+	cm, revision, err := b.store().Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := decodeAuthData(cm)
+	if err != nil {
+		return nil, err
+	}
+
+	authData, err := decodeAuthData(cm)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := fn(&authData, cm)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := DiffAuthData(&before, &authData)
+	if !updated || mode != DryRunServer {
+		return plan, nil
+	}
+
+	if err := encodeAuthData(authData, cm); err != nil {
+		return nil, err
+	}
+
+	if _, err := b.store().Save(ctx, cm, revision, true); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// DiffAuthData returns the changes which turn before into after. Mappings are matched by
+// their ARN or account, repeated ARNs are matched in order of appearance.
+func DiffAuthData(before, after *AwsAuthData) *Plan {
+	plan := &Plan{Changes: []Change{}}
+
+	diffMappings(plan, before.Mappings().Items, after.Mappings().Items)
+	return plan
+}
+
+func diffMappings(plan *Plan, before, after []Mapping) {
+	used := make([]bool, len(after))
+
+	for _, b := range before {
+		j := -1
+		for i, a := range after {
+			if !used[i] && a.Kind == b.Kind && a.Name() == b.Name() {
+				j = i
+				break
+			}
+		}
+
+		if j < 0 {
+			removed := b
+			plan.Changes = append(plan.Changes, Change{Action: ChangeRemove, Kind: b.Kind, Name: b.Name(), Before: &removed})
+			continue
+		}
+		used[j] = true
+
+		if fields := diffFields(b, after[j]); len(fields) > 0 {
+			previous, next := b, after[j]
+			plan.Changes = append(plan.Changes, Change{Action: ChangeModify, Kind: b.Kind, Name: b.Name(), Before: &previous, After: &next, Fields: fields})
+		}
+	}
+
+	for i, a := range after {
+		if !used[i] {
+			added := a
+			plan.Changes = append(plan.Changes, Change{Action: ChangeAdd, Kind: a.Kind, Name: a.Name(), After: &added})
+		}
+	}
+}
+
+func diffFields(before, after Mapping) []FieldChange {
+	var fields []FieldChange
+	if before.Username != after.Username {
+		fields = append(fields, FieldChange{Field: "username", Before: before.Username, After: after.Username})
+	}
+	if !groupsEqual(before.Groups, after.Groups) {
+		fields = append(fields, FieldChange{Field: "groups", Before: before.Groups, After: after.Groups})
+	}
+	return fields
+}
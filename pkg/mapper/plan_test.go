@@ -0,0 +1,199 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDiffAuthData(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	before := &AwsAuthData{
+		MapRoles: []*RolesAuthMap{
+			NewRolesAuthMap("arn:aws:iam::000000000000:role/node-1", "system:node:{{EC2PrivateDNSName}}", []string{"system:nodes"}),
+			NewRolesAuthMap("arn:aws:iam::000000000000:role/admin", "admin", []string{"system:masters"}),
+		},
+		MapAccounts: []string{"111111111111"},
+	}
+	after := &AwsAuthData{
+		MapRoles: []*RolesAuthMap{
+			NewRolesAuthMap("arn:aws:iam::000000000000:role/node-1", "system:node:{{EC2PrivateDNSName}}", []string{"system:nodes"}),
+			NewRolesAuthMap("arn:aws:iam::000000000000:role/admin", "break-glass", []string{"system:masters", "auditors"}),
+		},
+		MapUsers: []*UsersAuthMap{
+			NewUsersAuthMap("arn:aws:iam::000000000000:user/user-1", "admin", nil),
+		},
+	}
+
+	plan := DiffAuthData(before, after)
+	g.Expect(plan.Changes).To(gomega.HaveLen(3))
+
+	g.Expect(plan.Changes[0].Action).To(gomega.Equal(ChangeModify))
+	g.Expect(plan.Changes[0].Name).To(gomega.Equal("role/arn:aws:iam::000000000000:role/admin"))
+	g.Expect(plan.Changes[0].Fields).To(gomega.Equal([]FieldChange{
+		{Field: "username", Before: "admin", After: "break-glass"},
+		{Field: "groups", Before: []string{"system:masters"}, After: []string{"system:masters", "auditors"}},
+	}))
+
+	g.Expect(plan.Changes[1].Action).To(gomega.Equal(ChangeRemove))
+	g.Expect(plan.Changes[1].Name).To(gomega.Equal("account/111111111111"))
+	g.Expect(plan.Changes[1].After).To(gomega.BeNil())
+
+	g.Expect(plan.Changes[2].Action).To(gomega.Equal(ChangeAdd))
+	g.Expect(plan.Changes[2].Kind).To(gomega.Equal(UserMappingKind))
+	g.Expect(plan.Changes[2].Before).To(gomega.BeNil())
+
+	g.Expect(DiffAuthData(before, before).IsEmpty()).To(gomega.BeTrue())
+}
+
+func TestMapper_Plan(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	plan, err := mapper.Plan(&MapperArguments{
+		OperationType: OperationUpsert,
+		MapRoles:      true,
+		RoleARN:       "arn:aws:iam::000000000000:role/node-1",
+		Username:      "system:node:{{EC2PrivateDNSName}}",
+		Groups:        []string{"system:nodes"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(1))
+	g.Expect(plan.Changes[0].Action).To(gomega.Equal(ChangeModify))
+	g.Expect(plan.Changes[0].Fields).To(gomega.Equal([]FieldChange{
+		{Field: "groups", Before: []string{"system:bootstrappers", "system:nodes"}, After: []string{"system:nodes"}},
+	}))
+
+	plan, err = mapper.Plan(&MapperArguments{
+		OperationType: OperationRemoveByUsername,
+		Username:      "admin",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(1))
+	g.Expect(plan.Changes[0].Action).To(gomega.Equal(ChangeRemove))
+	g.Expect(plan.Changes[0].Name).To(gomega.Equal("user/arn:aws:iam::000000000000:user/user-1"))
+
+	g.Expect(count_Updates(client)).To(gomega.BeZero())
+
+	_, err = mapper.Plan(&MapperArguments{OperationType: OperationGet, IsGlobal: true, Format: FormatTable})
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestMapper_ClientDryRun(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
+		Username: "system:node:{{EC2PrivateDNSName}}",
+		DryRun:   DryRunClient,
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// a client dry run makes no writes, the missing configmap is not created either
+	for _, action := range client.Actions() {
+		g.Expect(action.GetVerb()).To(gomega.Equal("get"))
+	}
+
+	create_MockConfigMap(client)
+	err = mapper.Remove(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
+		DryRun:   DryRunClient,
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(count_Updates(client)).To(gomega.BeZero())
+
+	// errors of the operation are still reported
+	err = mapper.Remove(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/missing",
+		DryRun:   DryRunClient,
+	})
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestMapper_ServerDryRun(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	plan, err := mapper.Plan(&MapperArguments{
+		OperationType: OperationRemove,
+		MapUsers:      true,
+		UserARN:       "arn:aws:iam::000000000000:user/user-1",
+		DryRun:        DryRunServer,
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(1))
+
+	var updates []k8stesting.UpdateActionImpl
+	for _, action := range client.Actions() {
+		if update, ok := action.(k8stesting.UpdateActionImpl); ok {
+			updates = append(updates, update)
+		}
+	}
+	g.Expect(updates).To(gomega.HaveLen(1))
+	g.Expect(updates[0].UpdateOptions.DryRun).To(gomega.Equal([]string{metav1.DryRunAll}))
+}
+
+func TestValidate_DryRun(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	err := (&MapperArguments{IsGlobal: true, DryRun: "everything"}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrInvalidDryRun))
+
+	err = (&MapperArguments{IsGlobal: true, DryRun: DryRunClient, Format: FormatTable}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrUnsupportedFormat))
+
+	err = (&MapperArguments{IsGlobal: true, DryRun: DryRunServer, Format: FormatJSON}).Validate()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func TestMapper_ApplyDryRun(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	desired := &AwsAuthData{MapAccounts: []string{"111111111111"}}
+	result, err := mapper.Apply(desired, ApplyOptions{DryRun: DryRunClient})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(result.Added).To(gomega.HaveLen(1))
+	g.Expect(count_Updates(client)).To(gomega.BeZero())
+
+	_, err = mapper.Apply(desired, ApplyOptions{DryRun: "all"})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidDryRun))
+}
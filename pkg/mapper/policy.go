@@ -0,0 +1,358 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+
+	yaml "gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Mapping kinds a policy rule selects, as written in a rules file
+const (
+	PolicyKindRole    = "role"
+	PolicyKindUser    = "user"
+	PolicyKindAccount = "account"
+)
+
+// Policy validates the mappings which are about to be written to the aws-auth configmap
+type Policy interface {
+	// Evaluate returns the violations of the proposed data, none if it is allowed
+	Evaluate(authData *AwsAuthData) []Violation
+}
+
+// PolicyFunc adapts a function to a Policy
+type PolicyFunc func(authData *AwsAuthData) []Violation
+
+// Evaluate calls f
+func (f PolicyFunc) Evaluate(authData *AwsAuthData) []Violation {
+	return f(authData)
+}
+
+// Violation is a mapping which is not allowed by a policy
+type Violation struct {
+	// Rule is the name of the rule which was violated
+	Rule string `json:"rule"`
+	// Entry locates the mapping in the configmap, like mapRoles[2]
+	Entry   string  `json:"entry"`
+	Mapping Mapping `json:"mapping"`
+	Message string  `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%v %v: rule %v: %v", v.Entry, v.Mapping.Name(), v.Rule, v.Message)
+}
+
+// RulesPolicy is a Policy made of rules, usually loaded from a rules file with LoadPolicyFile
+type RulesPolicy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule rejects the mappings selected by Match, when Deny is set, or which do not also match Require
+type Rule struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// Match selects the mappings the rule applies to, an empty selector selects all of them
+	Match Selector `yaml:"match,omitempty"`
+	// Deny rejects every selected mapping
+	Deny bool `yaml:"deny,omitempty"`
+	// Require must be matched by every selected mapping
+	Require *Selector `yaml:"require,omitempty"`
+}
+
+// Selector matches mappings, all of its fields which are set must match. ARN, Username and Group
+// are regular expressions, Group matches when any of the groups of a mapping matches.
+type Selector struct {
+	// Kinds are role, user or account
+	Kinds    []string `yaml:"kinds,omitempty"`
+	Accounts []string `yaml:"accounts,omitempty"`
+	ARN      string   `yaml:"arn,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Group    string   `yaml:"group,omitempty"`
+
+	arn      *regexp.Regexp
+	username *regexp.Regexp
+	group    *regexp.Regexp
+}
+
+// LoadPolicyFile reads a rules file
+func LoadPolicyFile(filename string) (*RulesPolicy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", filename, err)
+	}
+	return policy, nil
+}
+
+// ParsePolicy parses and validates a rules document. Unknown fields are rejected so that typos
+// do not silently disable a rule.
+func ParsePolicy(data []byte) (*RulesPolicy, error) {
+	var policy RulesPolicy
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+	}
+
+	names := map[string]bool{}
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("%w: rules[%d] has no name", ErrInvalidPolicy, i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("%w: rule %v is defined twice", ErrInvalidPolicy, rule.Name)
+		}
+		names[rule.Name] = true
+
+		if rule.Deny == (rule.Require != nil) {
+			return nil, fmt.Errorf("%w: rule %v must set exactly one of deny or require", ErrInvalidPolicy, rule.Name)
+		}
+		if err := rule.Match.compile(); err != nil {
+			return nil, fmt.Errorf("%w: rule %v: match: %v", ErrInvalidPolicy, rule.Name, err)
+		}
+		if rule.Require != nil {
+			if err := rule.Require.compile(); err != nil {
+				return nil, fmt.Errorf("%w: rule %v: require: %v", ErrInvalidPolicy, rule.Name, err)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// Evaluate returns a violation for every mapping and rule it breaks
+func (p *RulesPolicy) Evaluate(authData *AwsAuthData) []Violation {
+	var violations []Violation
+	for _, entry := range policyEntries(authData) {
+		for _, rule := range p.Rules {
+			if !rule.Match.Matches(&entry.mapping) {
+				continue
+			}
+
+			var message string
+			switch {
+			case rule.Deny:
+				message = "mapping is denied"
+			case !rule.Require.Matches(&entry.mapping):
+				message = "mapping does not match " + rule.Require.String()
+			default:
+				continue
+			}
+			if rule.Description != "" {
+				message += ", " + rule.Description
+			}
+
+			violations = append(violations, Violation{
+				Rule:    rule.Name,
+				Entry:   entry.name,
+				Mapping: entry.mapping,
+				Message: message,
+			})
+		}
+	}
+	return violations
+}
+
+// Matches returns true if the mapping matches every field of the selector which is set
+func (s *Selector) Matches(m *Mapping) bool {
+	if len(s.Kinds) > 0 && !slices.Contains(s.Kinds, policyKind(m.Kind)) {
+		return false
+	}
+	if len(s.Accounts) > 0 && !slices.Contains(s.Accounts, m.Account) {
+		return false
+	}
+	if s.arn != nil && !s.arn.MatchString(m.ARN) {
+		return false
+	}
+	if s.username != nil && !s.username.MatchString(m.Username) {
+		return false
+	}
+	if s.group != nil && !slices.ContainsFunc(m.Groups, s.group.MatchString) {
+		return false
+	}
+	return true
+}
+
+// String describes the fields of the selector which are set
+func (s *Selector) String() string {
+	var buf bytes.Buffer
+	add := func(field string, value interface{}) {
+		if buf.Len() > 0 {
+			buf.WriteString(" ")
+		}
+		fmt.Fprintf(&buf, "%v=%v", field, value)
+	}
+
+	if len(s.Kinds) > 0 {
+		add("kinds", s.Kinds)
+	}
+	if len(s.Accounts) > 0 {
+		add("accounts", s.Accounts)
+	}
+	if s.ARN != "" {
+		add("arn", s.ARN)
+	}
+	if s.Username != "" {
+		add("username", s.Username)
+	}
+	if s.Group != "" {
+		add("group", s.Group)
+	}
+	return buf.String()
+}
+
+func (s *Selector) compile() error {
+	for _, kind := range s.Kinds {
+		if kind != PolicyKindRole && kind != PolicyKindUser && kind != PolicyKindAccount {
+			return fmt.Errorf("unknown kind %q, must be one of %v, %v, %v", kind, PolicyKindRole, PolicyKindUser, PolicyKindAccount)
+		}
+	}
+
+	for _, p := range []struct {
+		field string
+		expr  string
+		re    **regexp.Regexp
+	}{
+		{"arn", s.ARN, &s.arn},
+		{"username", s.Username, &s.username},
+		{"group", s.Group, &s.group},
+	} {
+		if p.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(p.expr)
+		if err != nil {
+			return fmt.Errorf("%v: %v", p.field, err)
+		}
+		*p.re = re
+	}
+	return nil
+}
+
+// EvaluatePolicies evaluates the data against the policies and returns a PolicyError listing all
+// violations, or nil when there are none
+func EvaluatePolicies(authData *AwsAuthData, policies ...Policy) error {
+	var violations []Violation
+	for _, policy := range policies {
+		violations = append(violations, policy.Evaluate(authData)...)
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// enforcePolicies wraps fn so it fails with a PolicyError when a mapping it adds or modifies violates
+// the policies. Mappings which are left as they were are not checked, so a write which removes or fixes
+// a violating mapping is allowed even when other mappings still violate the policies.
+func (b *AuthMapper) enforcePolicies(fn configMapMutation) configMapMutation {
+	if len(b.Policies) == 0 {
+		return fn
+	}
+
+	return func(authData *AwsAuthData, cm *v1.ConfigMap) (bool, error) {
+		before, err := decodeAuthData(cm)
+		if err != nil {
+			return false, err
+		}
+
+		updated, err := fn(authData, cm)
+		if err != nil || !updated {
+			return updated, err
+		}
+
+		changed := changedEntries(&before, authData)
+		if len(changed) == 0 {
+			return true, nil
+		}
+
+		var violations []Violation
+		for _, policy := range b.Policies {
+			for _, v := range policy.Evaluate(authData) {
+				// violations which are not about a single entry are about the data as a whole
+				if v.Entry == "" || changed[v.Entry] {
+					violations = append(violations, v)
+				}
+			}
+		}
+		if len(violations) > 0 {
+			return false, &PolicyError{Violations: violations}
+		}
+		return true, nil
+	}
+}
+
+// changedEntries returns the locations of the entries of after which were added or modified since before
+func changedEntries(before, after *AwsAuthData) map[string]bool {
+	names := map[string]bool{}
+	for _, change := range DiffAuthData(before, after).Changes {
+		if change.Action != ChangeRemove {
+			names[change.Name] = true
+		}
+	}
+
+	changed := map[string]bool{}
+	for _, entry := range policyEntries(after) {
+		if names[entry.mapping.Name()] {
+			changed[entry.name] = true
+		}
+	}
+	return changed
+}
+
+type policyEntry struct {
+	name    string
+	mapping Mapping
+}
+
+// policyEntries returns the mappings of the data with their location in the configmap
+func policyEntries(authData *AwsAuthData) []policyEntry {
+	var entries []policyEntry
+	for i, r := range authData.MapRoles {
+		entries = append(entries, policyEntry{fmt.Sprintf("mapRoles[%d]", i), newMapping(RoleMappingKind, r.RoleARN, r.Username, r.Groups)})
+	}
+	for i, u := range authData.MapUsers {
+		entries = append(entries, policyEntry{fmt.Sprintf("mapUsers[%d]", i), newMapping(UserMappingKind, u.UserARN, u.Username, u.Groups)})
+	}
+	for i, a := range authData.MapAccounts {
+		entries = append(entries, policyEntry{fmt.Sprintf("mapAccounts[%d]", i), Mapping{Kind: AccountMappingKind, Groups: []string{}, Account: a}})
+	}
+	return entries
+}
+
+// policyKind returns the kind of a mapping as written in a rules file
+func policyKind(kind MappingKind) string {
+	switch kind {
+	case RoleMappingKind:
+		return PolicyKindRole
+	case UserMappingKind:
+		return PolicyKindUser
+	default:
+		return PolicyKindAccount
+	}
+}
@@ -0,0 +1,219 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testPolicy = `
+rules:
+  - name: no-users
+    description: map roles instead
+    match:
+      kinds: [user]
+    deny: true
+  - name: masters-in-admin-account
+    match:
+      group: ^system:masters$
+    require:
+      kinds: [role]
+      accounts: ["111111111111"]
+  - name: team-usernames
+    match:
+      kinds: [role]
+      group: ^team-
+    require:
+      username: ^team-
+  - name: no-wildcard-groups
+    match:
+      group: '[*?]'
+    deny: true
+`
+
+func TestParsePolicy(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	policy, err := ParsePolicy([]byte(testPolicy))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(policy.Rules).To(gomega.HaveLen(4))
+	g.Expect(policy.Rules[1].Require.Accounts).To(gomega.Equal([]string{"111111111111"}))
+
+	invalid := map[string]string{
+		"rules:\n  - deny: true\n": "rules[0] has no name",
+		"rules:\n  - name: a\n    deny: true\n  - name: a\n    deny: true\n": "rule a is defined twice",
+		"rules:\n  - name: a\n":                                              "exactly one of deny or require",
+		"rules:\n  - name: a\n    deny: true\n    require: {}\n":             "exactly one of deny or require",
+		"rules:\n  - name: a\n    deny: true\n    match: {arn: '['}\n":       "rule a: match: arn",
+		"rules:\n  - name: a\n    deny: true\n    match: {kinds: [group]}\n": "unknown kind \"group\"",
+		"rules:\n  - name: a\n    deny: true\n    mtach: {}\n":               "field mtach not found",
+	}
+	for doc, reason := range invalid {
+		_, err := ParsePolicy([]byte(doc))
+		g.Expect(err).To(gomega.MatchError(ErrInvalidPolicy), doc)
+		g.Expect(err.Error()).To(gomega.ContainSubstring(reason), doc)
+	}
+}
+
+func TestRulesPolicy_Evaluate(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	policy, err := ParsePolicy([]byte(testPolicy))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	authData := &AwsAuthData{
+		MapRoles: []*RolesAuthMap{
+			NewRolesAuthMap("arn:aws:iam::111111111111:role/admin", "admin", []string{"system:masters"}),
+			NewRolesAuthMap("arn:aws:iam::222222222222:role/admin", "admin", []string{"system:masters"}),
+			NewRolesAuthMap("arn:aws:iam::222222222222:role/dev", "dev", []string{"team-dev"}),
+			NewRolesAuthMap("arn:aws:iam::222222222222:role/ops", "team-ops", []string{"team-*"}),
+		},
+		MapUsers: []*UsersAuthMap{
+			NewUsersAuthMap("arn:aws:iam::111111111111:user/alice", "alice", nil),
+		},
+		MapAccounts: []string{"111111111111"},
+	}
+
+	violations := policy.Evaluate(authData)
+	g.Expect(violations).To(gomega.HaveLen(4))
+
+	g.Expect(violations[0].Rule).To(gomega.Equal("masters-in-admin-account"))
+	g.Expect(violations[0].Entry).To(gomega.Equal("mapRoles[1]"))
+	g.Expect(violations[0].Mapping.ARN).To(gomega.Equal("arn:aws:iam::222222222222:role/admin"))
+	g.Expect(violations[0].Message).To(gomega.Equal("mapping does not match kinds=[role] accounts=[111111111111]"))
+
+	g.Expect(violations[1].Rule).To(gomega.Equal("team-usernames"))
+	g.Expect(violations[1].Entry).To(gomega.Equal("mapRoles[2]"))
+
+	g.Expect(violations[2].Rule).To(gomega.Equal("no-wildcard-groups"))
+	g.Expect(violations[2].Entry).To(gomega.Equal("mapRoles[3]"))
+
+	g.Expect(violations[3].Rule).To(gomega.Equal("no-users"))
+	g.Expect(violations[3].String()).To(gomega.Equal("mapUsers[0] user/arn:aws:iam::111111111111:user/alice: rule no-users: mapping is denied, map roles instead"))
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	filename := filepath.Join(t.TempDir(), "policy.yaml")
+	g.Expect(os.WriteFile(filename, []byte(testPolicy), 0600)).To(gomega.Succeed())
+
+	policy, err := LoadPolicyFile(filename)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(policy.Rules).To(gomega.HaveLen(4))
+
+	_, err = LoadPolicyFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestMapper_PolicyEnforced(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	policy, err := ParsePolicy([]byte("rules:\n  - name: no-wildcard-groups\n    match: {group: '[*?]'}\n    deny: true\n"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	mapper.Policies = []Policy{policy}
+
+	updates := count_Updates(client)
+	err = mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/dev",
+		Username: "dev",
+		Groups:   []string{"team-*"},
+	})
+	g.Expect(err).To(gomega.MatchError(ErrPolicyViolation))
+
+	var policyErr *PolicyError
+	g.Expect(errors.As(err, &policyErr)).To(gomega.BeTrue())
+	g.Expect(policyErr.Violations).To(gomega.HaveLen(1))
+	g.Expect(policyErr.Violations[0].Entry).To(gomega.Equal("mapRoles[1]"))
+
+	_, err = mapper.Apply(&AwsAuthData{
+		MapRoles: []*RolesAuthMap{NewRolesAuthMap("arn:aws:iam::000000000000:role/dev", "dev", []string{"team-?"})},
+	}, ApplyOptions{DryRun: DryRunClient})
+	g.Expect(err).To(gomega.MatchError(ErrPolicyViolation))
+	g.Expect(count_Updates(client)).To(gomega.Equal(updates))
+
+	// custom policies plug in next to rules files
+	mapper.Policies = append(mapper.Policies, PolicyFunc(func(authData *AwsAuthData) []Violation {
+		if len(authData.MapAccounts) > 0 {
+			return []Violation{{Rule: "no-accounts", Entry: "mapAccounts[0]", Message: "accounts are not allowed"}}
+		}
+		return nil
+	}))
+	err = mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+	g.Expect(err).To(gomega.MatchError(ErrPolicyViolation))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("rule no-accounts: accounts are not allowed"))
+
+	err = mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/dev",
+		Username: "dev",
+		Groups:   []string{"team-dev"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func TestMapper_PolicyOnlyChecksChangedMappings(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/dev",
+		Username: "dev",
+		Groups:   []string{"team-*"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	policy, err := ParsePolicy([]byte("rules:\n  - name: no-wildcard-groups\n    match: {group: '[*?]'}\n    deny: true\n"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	mapper.Policies = []Policy{policy}
+
+	// the existing violation does not block unrelated writes
+	err = mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// modifying the violating mapping without fixing it is refused
+	err = mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/dev",
+		Username: "developer",
+		Groups:   []string{"team-*"},
+	})
+	g.Expect(err).To(gomega.MatchError(ErrPolicyViolation))
+
+	// removing it is always allowed
+	err = mapper.Remove(&MapperArguments{MapRoles: true, RoleARN: "arn:aws:iam::000000000000:role/dev"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"111111111111"}))
+}
@@ -0,0 +1,116 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// ProtectedAnnotation lists the mappings of the aws-auth configmap which cannot be removed or modified
+// unless AuthMapper.OverrideProtection is set. Entries are separated by commas or newlines, each is a
+// role or user ARN, which may be a glob, an account ID, or group:<name> to protect every role and user
+// mapped to that group.
+const ProtectedAnnotation = "aws-auth.keikoproj.io/protected"
+
+const protectedGroupPrefix = "group:"
+
+// protectedOperations are the operations which refuse to change protected mappings. Restore, rollback
+// and normalize are left out, they return the configmap to an earlier state or only rewrite role paths.
+var protectedOperations = []OperationType{
+	OperationUpsert,
+	OperationUpsertMultiple,
+	OperationRemove,
+	OperationRemoveByUsername,
+	OperationApply,
+	OperationSync,
+}
+
+// ProtectedEntries returns the entries of the ProtectedAnnotation of a configmap
+func ProtectedEntries(cm *v1.ConfigMap) []string {
+	var entries []string
+	for _, line := range strings.Split(cm.Annotations[ProtectedAnnotation], "\n") {
+		for _, entry := range strings.Split(line, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+// IsProtected returns true if the mapping matches one of the protected entries
+func IsProtected(entries []string, m *Mapping) bool {
+	for _, entry := range entries {
+		switch {
+		case strings.HasPrefix(entry, protectedGroupPrefix):
+			if m.Kind != AccountMappingKind && slices.Contains(m.Groups, strings.TrimPrefix(entry, protectedGroupPrefix)) {
+				return true
+			}
+		case m.Kind == AccountMappingKind:
+			if entry == m.Account {
+				return true
+			}
+		case m.Kind == RoleMappingKind:
+			// the authenticator ignores the path of roles, so neither does the protection
+			if matchPattern(entry, m.ARN) || matchPattern(CanonicalRoleARN(entry), CanonicalRoleARN(m.ARN)) {
+				return true
+			}
+		default:
+			if matchPattern(entry, m.ARN) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// guardProtection wraps fn so it fails with a ProtectedError when it removes or modifies protected mappings
+func (b *AuthMapper) guardProtection(op OperationType, fn configMapMutation) configMapMutation {
+	if b.OverrideProtection || !slices.Contains(protectedOperations, op) {
+		return fn
+	}
+
+	return func(authData *AwsAuthData, cm *v1.ConfigMap) (bool, error) {
+		entries := ProtectedEntries(cm)
+		if len(entries) == 0 {
+			return fn(authData, cm)
+		}
+
+		before, err := decodeAuthData(cm)
+		if err != nil {
+			return false, err
+		}
+
+		updated, err := fn(authData, cm)
+		if err != nil || !updated {
+			return updated, err
+		}
+
+		var protected []string
+		for _, change := range DiffAuthData(&before, authData).Changes {
+			if change.Action != ChangeAdd && IsProtected(entries, change.Before) {
+				protected = append(protected, change.Name)
+			}
+		}
+		if len(protected) > 0 {
+			return false, &ProtectedError{Operation: op, Mappings: protected}
+		}
+		return true, nil
+	}
+}
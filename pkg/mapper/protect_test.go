@@ -0,0 +1,153 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func protect_MockConfigMap(client kubernetes.Interface, entries string) {
+	cm, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Get(context.Background(), AwsAuthName, metav1.GetOptions{})
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	cm.Annotations = map[string]string{ProtectedAnnotation: entries}
+	_, err = client.CoreV1().ConfigMaps(AwsAuthNamespace).Update(context.Background(), cm, metav1.UpdateOptions{})
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func TestIsProtected(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	entries := []string{"group:system:nodes", "arn:aws:iam::000000000000:role/BreakGlass", "arn:aws:iam::000000000000:user/ops-*", "111111111111"}
+
+	g.Expect(IsProtected(entries, &Mapping{Kind: RoleMappingKind, ARN: "arn:aws:iam::000000000000:role/node", Groups: []string{"system:bootstrappers", "system:nodes"}})).To(gomega.BeTrue())
+	g.Expect(IsProtected(entries, &Mapping{Kind: RoleMappingKind, ARN: "arn:aws:iam::000000000000:role/admins/BreakGlass"})).To(gomega.BeTrue())
+	g.Expect(IsProtected(entries, &Mapping{Kind: UserMappingKind, ARN: "arn:aws:iam::000000000000:user/ops-alice"})).To(gomega.BeTrue())
+	g.Expect(IsProtected(entries, &Mapping{Kind: AccountMappingKind, Account: "111111111111"})).To(gomega.BeTrue())
+
+	g.Expect(IsProtected(entries, &Mapping{Kind: RoleMappingKind, ARN: "arn:aws:iam::000000000000:role/ci", Groups: []string{"deployers"}})).To(gomega.BeFalse())
+	g.Expect(IsProtected(entries, &Mapping{Kind: UserMappingKind, ARN: "arn:aws:iam::000000000000:user/team/ops-alice"})).To(gomega.BeFalse())
+	g.Expect(IsProtected(entries, &Mapping{Kind: AccountMappingKind, Account: "222222222222"})).To(gomega.BeFalse())
+}
+
+func TestProtectedEntries(t *testing.T) {
+	g := gomega.NewWithT(t)
+	client := fake.NewSimpleClientset()
+	gomega.RegisterTestingT(t)
+	create_MockConfigMap(client)
+	protect_MockConfigMap(client, "group:system:nodes, arn:aws:iam::000000000000:role/BreakGlass\n\n111111111111\n")
+
+	_, cm, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(ProtectedEntries(cm)).To(gomega.Equal([]string{"group:system:nodes", "arn:aws:iam::000000000000:role/BreakGlass", "111111111111"}))
+}
+
+func TestMapper_RemoveProtected(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+	protect_MockConfigMap(client, "group:system:nodes,arn:aws:iam::000000000000:user/user-1")
+
+	updates := count_Updates(client)
+	err := mapper.Remove(&MapperArguments{MapRoles: true, RoleARN: "arn:aws:iam::000000000000:role/node-1"})
+	g.Expect(err).To(gomega.MatchError(ErrProtected))
+
+	var protectedErr *ProtectedError
+	g.Expect(errors.As(err, &protectedErr)).To(gomega.BeTrue())
+	g.Expect(protectedErr.Operation).To(gomega.Equal(OperationRemove))
+	g.Expect(protectedErr.Mappings).To(gomega.Equal([]string{"role/arn:aws:iam::000000000000:role/node-1"}))
+
+	err = mapper.RemoveByUsername(&MapperArguments{Username: "admin"})
+	g.Expect(err).To(gomega.MatchError(ErrProtected))
+
+	// dry runs report the refusal too
+	err = mapper.Remove(&MapperArguments{MapUsers: true, UserARN: "arn:aws:iam::000000000000:user/user-1", DryRun: DryRunClient})
+	g.Expect(err).To(gomega.MatchError(ErrProtected))
+	g.Expect(count_Updates(client)).To(gomega.Equal(updates))
+
+	mapper.OverrideProtection = true
+	err = mapper.RemoveByUsername(&MapperArguments{Username: "admin"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapUsers).To(gomega.BeEmpty())
+}
+
+func TestMapper_UpsertProtected(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+	protect_MockConfigMap(client, "group:system:nodes")
+
+	err := mapper.UpsertMultiple([]*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/node-1", "node", []string{"system:nodes"}),
+	}, nil)
+	g.Expect(err).To(gomega.MatchError(ErrProtected))
+
+	err = mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
+		Username: "node",
+		Groups:   []string{"system:nodes"},
+	})
+	g.Expect(err).To(gomega.MatchError(ErrProtected))
+
+	// new mappings and mappings which do not change are fine
+	err = mapper.UpsertMultiple([]*RolesAuthMap{
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/node-1", "system:node:{{EC2PrivateDNSName}}", []string{"system:bootstrappers", "system:nodes"}),
+		NewRolesAuthMap("arn:aws:iam::000000000000:role/node-2", "system:node:{{EC2PrivateDNSName}}", []string{"system:bootstrappers", "system:nodes"}),
+	}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(2))
+}
+
+func TestMapper_ApplyProtected(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+	protect_MockConfigMap(client, "arn:aws:iam::000000000000:role/node-1")
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, err = mapper.Apply(&auth, ApplyOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// pruning the applied node role is refused
+	desired := &AwsAuthData{MapUsers: auth.MapUsers}
+	_, err = mapper.Apply(desired, ApplyOptions{Prune: true})
+	g.Expect(err).To(gomega.MatchError(ErrProtected))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("apply would remove or modify role/arn:aws:iam::000000000000:role/node-1"))
+
+	mapper.OverrideProtection = true
+	_, err = mapper.Apply(desired, ApplyOptions{Prune: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+}
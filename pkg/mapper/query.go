@@ -0,0 +1,164 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MappingFilter selects mappings of the aws-auth configmap, empty fields match everything.
+// RoleARN, UserARN, Username and AccountID are matched exactly, or as a glob when they
+// contain '*' (any characters, including '/') or '?' (a single character).
+type MappingFilter struct {
+	// MapRoles, MapUsers and MapAccounts limit the result to the selected sections,
+	// when none are set all sections are included
+	MapRoles    bool
+	MapUsers    bool
+	MapAccounts bool
+
+	RoleARN   string
+	UserARN   string
+	Username  string
+	AccountID string
+	// Groups must all be present in a mapping's groups
+	Groups []string
+}
+
+// Filter returns the filter for the query arguments of a get operation
+func (args *MapperArguments) Filter() *MappingFilter {
+	return &MappingFilter{
+		MapRoles:    args.MapRoles,
+		MapUsers:    args.MapUsers,
+		MapAccounts: args.MapAccounts,
+		RoleARN:     args.RoleARN,
+		UserARN:     args.UserARN,
+		Username:    args.Username,
+		AccountID:   args.AccountID,
+		Groups:      args.Groups,
+	}
+}
+
+// IsEmpty returns true if the filter matches every mapping
+func (f *MappingFilter) IsEmpty() bool {
+	return !f.MapRoles && !f.MapUsers && !f.MapAccounts &&
+		f.RoleARN == "" && f.UserARN == "" && f.Username == "" && f.AccountID == "" && len(f.Groups) == 0
+}
+
+func (f *MappingFilter) scoped() bool {
+	return f.MapRoles || f.MapUsers || f.MapAccounts
+}
+
+// MatchRole returns true if the role mapping is selected by the filter
+func (f *MappingFilter) MatchRole(r *RolesAuthMap) bool {
+	if f.scoped() && !f.MapRoles {
+		return false
+	}
+	if f.UserARN != "" {
+		return false
+	}
+	return matchPattern(f.RoleARN, r.RoleARN) && f.matchMapping(r.RoleARN, r.Username, r.Groups)
+}
+
+// MatchUser returns true if the user mapping is selected by the filter
+func (f *MappingFilter) MatchUser(u *UsersAuthMap) bool {
+	if f.scoped() && !f.MapUsers {
+		return false
+	}
+	if f.RoleARN != "" {
+		return false
+	}
+	return matchPattern(f.UserARN, u.UserARN) && f.matchMapping(u.UserARN, u.Username, u.Groups)
+}
+
+// MatchAccount returns true if the account mapping is selected by the filter, account
+// mappings have no ARN, username or groups so any of those filters excludes them
+func (f *MappingFilter) MatchAccount(account string) bool {
+	if f.scoped() && !f.MapAccounts {
+		return false
+	}
+	if f.RoleARN != "" || f.UserARN != "" || f.Username != "" || len(f.Groups) != 0 {
+		return false
+	}
+	return matchPattern(f.AccountID, account)
+}
+
+func (f *MappingFilter) matchMapping(arn, username string, groups []string) bool {
+	if !matchPattern(f.Username, username) {
+		return false
+	}
+	if f.AccountID != "" && !matchPattern(f.AccountID, arnAccount(arn)) {
+		return false
+	}
+	for _, g := range f.Groups {
+		if !slices.Contains(groups, g) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the subset of the mappings which are selected by the filter
+func (m *AwsAuthData) Filter(f *MappingFilter) AwsAuthData {
+	var out AwsAuthData
+
+	for _, r := range m.MapRoles {
+		if f.MatchRole(r) {
+			out.MapRoles = append(out.MapRoles, r)
+		}
+	}
+
+	for _, u := range m.MapUsers {
+		if f.MatchUser(u) {
+			out.MapUsers = append(out.MapUsers, u)
+		}
+	}
+
+	for _, a := range m.MapAccounts {
+		if f.MatchAccount(a) {
+			out.MapAccounts = append(out.MapAccounts, a)
+		}
+	}
+
+	return out
+}
+
+// matchPattern matches a value against an exact string or a glob, an empty pattern matches everything
+func matchPattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern == value
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	return regexp.MustCompile(expr.String()).MatchString(value)
+}
@@ -0,0 +1,105 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func queryAuthData() *AwsAuthData {
+	return &AwsAuthData{
+		MapRoles: []*RolesAuthMap{
+			NewRolesAuthMap("arn:aws:iam::111111111111:role/team/a/node", "system:node:{{EC2PrivateDNSName}}", []string{"system:bootstrappers", "system:nodes"}),
+			NewRolesAuthMap("arn:aws:iam::111111111111:role/admin", "admin", []string{"system:masters"}),
+			NewRolesAuthMap("arn:aws:iam::222222222222:role/ci", "ci", []string{"deployers"}),
+		},
+		MapUsers: []*UsersAuthMap{
+			NewUsersAuthMap("arn:aws:iam::111111111111:user/alice", "admin", []string{"system:masters"}),
+			NewUsersAuthMap("arn:aws:iam::222222222222:user/bob", "bob", []string{"viewers"}),
+		},
+		MapAccounts: []string{"111111111111", "333333333333"},
+	}
+}
+
+func TestFilter_Empty(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	f := &MappingFilter{}
+	g.Expect(f.IsEmpty()).To(gomega.BeTrue())
+	g.Expect(queryAuthData().Filter(f)).To(gomega.Equal(*queryAuthData()))
+}
+
+func TestFilter_Scope(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	out := queryAuthData().Filter(&MappingFilter{MapRoles: true})
+	g.Expect(out.MapRoles).To(gomega.HaveLen(3))
+	g.Expect(out.MapUsers).To(gomega.BeEmpty())
+	g.Expect(out.MapAccounts).To(gomega.BeEmpty())
+
+	out = queryAuthData().Filter(&MappingFilter{MapUsers: true, MapAccounts: true})
+	g.Expect(out.MapRoles).To(gomega.BeEmpty())
+	g.Expect(out.MapUsers).To(gomega.HaveLen(2))
+	g.Expect(out.MapAccounts).To(gomega.HaveLen(2))
+}
+
+func TestFilter_ARNGlob(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	out := queryAuthData().Filter(&MappingFilter{RoleARN: "arn:aws:iam::111111111111:role/*"})
+	g.Expect(out.MapRoles).To(gomega.HaveLen(2))
+	g.Expect(out.MapUsers).To(gomega.BeEmpty())
+	g.Expect(out.MapAccounts).To(gomega.BeEmpty())
+
+	out = queryAuthData().Filter(&MappingFilter{RoleARN: "arn:aws:iam::222222222222:role/ci"})
+	g.Expect(out.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(out.MapRoles[0].Username).To(gomega.Equal("ci"))
+
+	out = queryAuthData().Filter(&MappingFilter{UserARN: "*:user/b?b"})
+	g.Expect(out.MapRoles).To(gomega.BeEmpty())
+	g.Expect(out.MapUsers).To(gomega.HaveLen(1))
+	g.Expect(out.MapUsers[0].Username).To(gomega.Equal("bob"))
+
+	// glob characters are the only special characters
+	out = queryAuthData().Filter(&MappingFilter{RoleARN: "arn:aws:iam::111111111111:role/(admin|ci)"})
+	g.Expect(out.MapRoles).To(gomega.BeEmpty())
+}
+
+func TestFilter_UsernameGroupAccount(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	out := queryAuthData().Filter(&MappingFilter{Username: "admin"})
+	g.Expect(out.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(out.MapUsers).To(gomega.HaveLen(1))
+	g.Expect(out.MapAccounts).To(gomega.BeEmpty())
+
+	out = queryAuthData().Filter(&MappingFilter{Groups: []string{"system:nodes", "system:bootstrappers"}})
+	g.Expect(out.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(out.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::111111111111:role/team/a/node"))
+	g.Expect(out.MapUsers).To(gomega.BeEmpty())
+
+	out = queryAuthData().Filter(&MappingFilter{AccountID: "111111111111"})
+	g.Expect(out.MapRoles).To(gomega.HaveLen(2))
+	g.Expect(out.MapUsers).To(gomega.HaveLen(1))
+	g.Expect(out.MapAccounts).To(gomega.Equal([]string{"111111111111"}))
+
+	out = queryAuthData().Filter(&MappingFilter{AccountID: "111111111111", Groups: []string{"system:masters"}})
+	g.Expect(out.MapRoles).To(gomega.HaveLen(1))
+	g.Expect(out.MapUsers).To(gomega.HaveLen(1))
+	g.Expect(out.MapAccounts).To(gomega.BeEmpty())
+}
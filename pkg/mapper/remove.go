@@ -16,131 +16,173 @@ limitations under the License.
 package mapper
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"reflect"
 )
 
 // Remove removes by match of provided arguments
 func (b *AuthMapper) Remove(args *MapperArguments) error {
-	args.Validate()
+	return b.RemoveWithContext(context.Background(), args)
+}
+
+// RemoveWithContext is like Remove but uses the given context for the API calls and retries
+func (b *AuthMapper) RemoveWithContext(ctx context.Context, args *MapperArguments) error {
+	if err := args.Validate(); err != nil {
+		return err
+	}
+
+	if args.DryRun != DryRunNone {
+		_, err := b.planMutation(ctx, OperationRemove, b.removeMutation(args), args.DryRun)
+		return err
+	}
 
 	if args.WithRetries {
-		_, err := WithRetry(func() (interface{}, error) {
-			return nil, b.removeAuth(args)
+		_, err := b.withRetry(ctx, func() (interface{}, error) {
+			return nil, b.removeAuth(ctx, args)
 		}, args)
 		return err
 	}
-	return b.removeAuth(args)
+	return b.removeAuth(ctx, args)
 }
 
 // RemoveByUsername removes all map roles and map users that match provided username
 func (b *AuthMapper) RemoveByUsername(args *MapperArguments) error {
+	return b.RemoveByUsernameWithContext(context.Background(), args)
+}
+
+// RemoveByUsernameWithContext is like RemoveByUsername but uses the given context for the API calls and retries
+func (b *AuthMapper) RemoveByUsernameWithContext(ctx context.Context, args *MapperArguments) error {
 	args.IsGlobal = true
-	args.Validate()
+	args.OperationType = OperationRemoveByUsername
+	if err := args.Validate(); err != nil {
+		return err
+	}
+
+	if args.DryRun != DryRunNone {
+		_, err := b.planMutation(ctx, OperationRemoveByUsername, b.removeByUserMutation(args), args.DryRun)
+		return err
+	}
+
 	if args.WithRetries {
-		_, err := WithRetry(func() (interface{}, error) {
-			return nil, b.removeAuthByUser(args)
+		_, err := b.withRetry(ctx, func() (interface{}, error) {
+			return nil, b.removeAuthByUser(ctx, args)
 		}, args)
 		return err
 	}
-	return b.removeAuthByUser(args)
+	return b.removeAuthByUser(ctx, args)
 }
 
-func (b *AuthMapper) removeAuthByUser(args *MapperArguments) error {
-	// Read the config map and return an AuthMap
-	authData, configMap, err := ReadAuthMap(b.KubernetesClient)
-	if err != nil {
-		return err
-	}
-	removed := false
+func (b *AuthMapper) removeAuthByUser(ctx context.Context, args *MapperArguments) error {
+	return b.mutateAuthMap(ctx, OperationRemoveByUsername, b.removeByUserMutation(args))
+}
 
-	var newRolesAuthMap []*RolesAuthMap
+// removeByUserMutation returns the mutation which removes all roles and users mapped to the username of args
+func (b *AuthMapper) removeByUserMutation(args *MapperArguments) authMapMutation {
+	return func(authData *AwsAuthData) (bool, error) {
+		removed := false
 
-	for _, mapRole := range authData.MapRoles {
-		// Add all other members except the matched
-		if args.Username != mapRole.Username {
-			newRolesAuthMap = append(newRolesAuthMap, mapRole)
-		} else {
-			removed = true
+		var newRolesAuthMap []*RolesAuthMap
+
+		for _, mapRole := range authData.MapRoles {
+			// Add all other members except the matched
+			if args.Username != mapRole.Username {
+				newRolesAuthMap = append(newRolesAuthMap, mapRole)
+			} else {
+				removed = true
+			}
 		}
-	}
 
-	var newUsersAuthMap []*UsersAuthMap
+		var newUsersAuthMap []*UsersAuthMap
 
-	for _, mapUser := range authData.MapUsers {
-		// Add all other members except the matched
-		if args.Username != mapUser.Username {
-			newUsersAuthMap = append(newUsersAuthMap, mapUser)
-		} else {
-			removed = true
+		for _, mapUser := range authData.MapUsers {
+			// Add all other members except the matched
+			if args.Username != mapUser.Username {
+				newUsersAuthMap = append(newUsersAuthMap, mapUser)
+			} else {
+				removed = true
+			}
 		}
-	}
 
-	if !removed {
-		msg := fmt.Sprintf("failed to remove based on username %v, found zero matches\n", args.Username)
-		log.Print(msg)
-		if args.Force {
-			return nil
+		if !removed {
+			msg := fmt.Sprintf("failed to remove based on username %v, found zero matches\n", args.Username)
+			b.logf("%v", msg)
+			if args.Force {
+				return false, nil
+			}
+			return false, errors.New(msg)
 		}
-		return errors.New(msg)
-	}
 
-	authData.SetMapRoles(newRolesAuthMap)
-	authData.SetMapUsers(newUsersAuthMap)
+		authData.SetMapRoles(newRolesAuthMap)
+		authData.SetMapUsers(newUsersAuthMap)
+		return true, nil
+	}
+}
 
-	return UpdateAuthMap(b.KubernetesClient, authData, configMap)
+func (b *AuthMapper) removeAuth(ctx context.Context, args *MapperArguments) error {
+	return b.mutateAuthMap(ctx, OperationRemove, b.removeMutation(args))
 }
 
-func (b *AuthMapper) removeAuth(args *MapperArguments) error {
-	// Read the config map and return an AuthMap
-	authData, configMap, err := ReadAuthMap(b.KubernetesClient)
-	if err != nil {
-		return err
-	}
+// removeMutation returns the mutation which removes the role, user or account matching args
+func (b *AuthMapper) removeMutation(args *MapperArguments) authMapMutation {
+	return func(authData *AwsAuthData) (bool, error) {
+		if args.MapRoles {
+			var rolesResource = NewRolesAuthMap(args.RoleARN, args.Username, args.Groups)
+			newMap, ok := removeRole(authData.MapRoles, rolesResource, args.IgnorePath)
+
+			if !ok {
+				b.logf("failed to remove %v, could not find exact match\n", rolesResource.RoleARN)
+				if args.Force {
+					return false, nil
+				}
+				return false, errors.New("could not find rolemap")
+			}
+			b.logf("removed %v from aws-auth\n", rolesResource.RoleARN)
+			authData.SetMapRoles(newMap)
+		}
 
-	if args.MapRoles {
-		var rolesResource = NewRolesAuthMap(args.RoleARN, args.Username, args.Groups)
-		newMap, ok := removeRole(authData.MapRoles, rolesResource)
+		if args.MapUsers {
+			var usersResource = NewUsersAuthMap(args.UserARN, args.Username, args.Groups)
+			newMap, ok := removeUser(authData.MapUsers, usersResource)
 
-		if !ok {
-			log.Printf("failed to remove %v, could not find exact match\n", rolesResource.RoleARN)
-			if args.Force {
-				return nil
+			if !ok {
+				b.logf("failed to remove %v, could not find exact match\n", usersResource.UserARN)
+				if args.Force {
+					return false, nil
+				}
+				return false, errors.New("could not find usermap")
 			}
-			return errors.New("could not find rolemap")
+			b.logf("removed %v from aws-auth\n", usersResource.UserARN)
+			authData.SetMapUsers(newMap)
 		}
-		log.Printf("removed %v from aws-auth\n", rolesResource.RoleARN)
-		authData.SetMapRoles(newMap)
-	}
 
-	if args.MapUsers {
-		var usersResource = NewUsersAuthMap(args.UserARN, args.Username, args.Groups)
-		newMap, ok := removeUser(authData.MapUsers, usersResource)
+		if args.MapAccounts {
+			newMap, ok := removeAccount(authData.MapAccounts, args.AccountID)
 
-		if !ok {
-			log.Printf("failed to remove %v, could not find exact match\n", usersResource.UserARN)
-			if args.Force {
-				return nil
+			if !ok {
+				b.logf("failed to remove %v, could not find exact match\n", args.AccountID)
+				if args.Force {
+					return false, nil
+				}
+				return false, errors.New("could not find account")
 			}
-			return errors.New("could not find usermap")
+			b.logf("removed %v from aws-auth\n", args.AccountID)
+			authData.SetMapAccounts(newMap)
 		}
-		log.Printf("removed %v from aws-auth\n", usersResource.UserARN)
-		authData.SetMapUsers(newMap)
-	}
 
-	return UpdateAuthMap(b.KubernetesClient, authData, configMap)
+		return true, nil
+	}
 }
 
-func removeRole(authMaps []*RolesAuthMap, targetMap *RolesAuthMap) ([]*RolesAuthMap, bool) {
+func removeRole(authMaps []*RolesAuthMap, targetMap *RolesAuthMap, ignorePath bool) ([]*RolesAuthMap, bool) {
 	var newMap []*RolesAuthMap
 	var match bool
 	var removed bool
 
 	for _, existingMap := range authMaps {
 		match = false
-		if existingMap.RoleARN == targetMap.RoleARN {
+		if roleARNEqual(existingMap.RoleARN, targetMap.RoleARN, ignorePath) {
 			match = true
 			if len(targetMap.Groups) != 0 && !reflect.DeepEqual(existingMap.Groups, targetMap.Groups) {
 				match = false
@@ -182,3 +224,17 @@ func removeUser(authMaps []*UsersAuthMap, targetMap *UsersAuthMap) ([]*UsersAuth
 	}
 	return newMap, removed
 }
+
+func removeAccount(accounts []string, account string) ([]string, bool) {
+	var newMap []string
+	var removed bool
+
+	for _, existing := range accounts {
+		if existing == account {
+			removed = true
+		} else {
+			newMap = append(newMap, existing)
+		}
+	}
+	return newMap, removed
+}
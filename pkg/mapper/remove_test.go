@@ -32,7 +32,7 @@ func TestMapper_Remove(t *testing.T) {
 
 	err := mapper.Remove(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "system:node:{{EC2PrivateDNSName}}",
 		Groups:   []string{"system:bootstrappers", "system:nodes"},
 	})
@@ -40,7 +40,7 @@ func TestMapper_Remove(t *testing.T) {
 
 	err = mapper.Remove(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "admin",
 		Groups:   []string{"system:masters"},
 	})
@@ -61,13 +61,13 @@ func TestMapper_RemoveByARN(t *testing.T) {
 
 	err := mapper.Remove(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 	})
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 
 	err = mapper.Remove(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 	})
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 
@@ -86,13 +86,13 @@ func TestMapper_RemoveNotFound(t *testing.T) {
 
 	err := mapper.Remove(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-2",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-2",
 	})
 	g.Expect(err).To(gomega.HaveOccurred())
 
 	err = mapper.Remove(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-2",
+		UserARN:  "arn:aws:iam::000000000000:user/user-2",
 	})
 	g.Expect(err).To(gomega.HaveOccurred())
 
@@ -112,14 +112,14 @@ func TestMapper_RemoveNotFoundForce(t *testing.T) {
 	err := mapper.Remove(&MapperArguments{
 		Force:    true,
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-2",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-2",
 	})
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 
 	err = mapper.Remove(&MapperArguments{
 		Force:    true,
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-2",
+		UserARN:  "arn:aws:iam::000000000000:user/user-2",
 	})
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 
@@ -240,7 +240,7 @@ func TestMapper_RemoveRole_PartialMatchARNOnly(t *testing.T) {
 	// The ARN exists but with a different username — should fail to remove (no exact match)
 	err := mapper.Remove(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "wrong-username",
 		Groups:   []string{"system:bootstrappers", "system:nodes"},
 	})
@@ -262,7 +262,7 @@ func TestMapper_RemoveUser_PartialMatchARNOnly(t *testing.T) {
 	// The ARN exists but with a different username — should fail to remove (no exact match)
 	err := mapper.Remove(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "wrong-username",
 		Groups:   []string{"system:masters"},
 	})
@@ -284,7 +284,7 @@ func TestMapper_RemoveRole_GroupsMismatchNotOverriddenByUsername(t *testing.T) {
 	// ARN and username match, but groups do NOT match — should fail to remove
 	err := mapper.Remove(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "system:node:{{EC2PrivateDNSName}}",
 		Groups:   []string{"wrong-group"},
 	})
@@ -306,7 +306,7 @@ func TestMapper_RemoveUser_GroupsMismatchNotOverriddenByUsername(t *testing.T) {
 	// ARN and username match, but groups do NOT match — should fail to remove
 	err := mapper.Remove(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "admin",
 		Groups:   []string{"wrong-group"},
 	})
@@ -327,7 +327,7 @@ func TestMapper_RemoveRole_NotFound_ErrorMessage(t *testing.T) {
 
 	err := mapper.Remove(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/nonexistent",
+		RoleARN:  "arn:aws:iam::000000000000:role/nonexistent",
 	})
 	g.Expect(err).To(gomega.HaveOccurred())
 	g.Expect(err.Error()).To(gomega.ContainSubstring("rolemap"))
@@ -342,7 +342,7 @@ func TestMapper_RemoveUser_NotFound_ErrorMessage(t *testing.T) {
 
 	err := mapper.Remove(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/nonexistent",
+		UserARN:  "arn:aws:iam::000000000000:user/nonexistent",
 	})
 	g.Expect(err).To(gomega.HaveOccurred())
 	g.Expect(err.Error()).To(gomega.ContainSubstring("usermap"))
@@ -357,7 +357,7 @@ func TestMapper_RemoveWithRetries(t *testing.T) {
 
 	err := mapper.Remove(&MapperArguments{
 		MapRoles:      true,
-		RoleARN:       "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:       "arn:aws:iam::000000000000:role/node-1",
 		Username:      "system:node:{{EC2PrivateDNSName}}",
 		Groups:        []string{"system:bootstrappers", "system:nodes"},
 		WithRetries:   true,
@@ -369,7 +369,7 @@ func TestMapper_RemoveWithRetries(t *testing.T) {
 
 	err = mapper.Remove(&MapperArguments{
 		MapUsers:      true,
-		UserARN:       "arn:aws:iam::00000000000:user/user-1",
+		UserARN:       "arn:aws:iam::000000000000:user/user-1",
 		Username:      "admin",
 		Groups:        []string{"system:masters"},
 		WithRetries:   true,
@@ -384,3 +384,54 @@ func TestMapper_RemoveWithRetries(t *testing.T) {
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(0))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(0))
 }
+
+func TestMapper_RemoveAccount(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMapWithAccounts(client)
+
+	err := mapper.Remove(&MapperArguments{
+		MapAccounts: true,
+		AccountID:   "111111111111",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	err = mapper.Remove(&MapperArguments{
+		MapAccounts: true,
+		AccountID:   "333333333333",
+	})
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(err.Error()).To(gomega.ContainSubstring("could not find account"))
+
+	err = mapper.Remove(&MapperArguments{
+		Force:       true,
+		MapAccounts: true,
+		AccountID:   "333333333333",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"222222222222"}))
+}
+
+func TestMapper_RemoveByUsernamePreservesAccounts(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMapWithAccounts(client)
+
+	err := mapper.RemoveByUsername(&MapperArguments{
+		Username: "system:node:{{EC2PrivateDNSName}}",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(0))
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"111111111111", "222222222222"}))
+}
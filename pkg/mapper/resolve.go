@@ -0,0 +1,124 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Resolve returns the Kubernetes username and groups the caller of args is mapped to by the aws-auth
+// configmap. The caller is matched the way the authenticator matches it, first against mapUsers, then
+// against mapRoles with assumed-role sessions converted to their role and role paths ignored, and last
+// against mapAccounts. ARNs are compared regardless of case. When nothing matches a NoMatchError explains why.
+func (b *AuthMapper) Resolve(args *RenderArguments) (*Identity, error) {
+	return b.ResolveWithContext(context.Background(), args)
+}
+
+// ResolveWithContext is like Resolve but uses the given context for the API calls
+func (b *AuthMapper) ResolveWithContext(ctx context.Context, args *RenderArguments) (*Identity, error) {
+	authData, err := b.getAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return authData.Resolve(args)
+}
+
+// Resolve is like AuthMapper.Resolve for the given mappings
+func (m *AwsAuthData) Resolve(args *RenderArguments) (*Identity, error) {
+	caller, err := ParseARN(args.CallerARN)
+	if err != nil {
+		return nil, err
+	}
+
+	// the authenticator matches the role of an assumed-role session, without its path
+	canonical := caller.String()
+	if caller.isRole() {
+		canonical = caller.RoleARN().String()
+	}
+
+	values := map[string]string{
+		PlaceholderAccountID:         caller.AccountID,
+		PlaceholderSessionName:       args.SessionName,
+		PlaceholderAccessKeyID:       args.AccessKeyID,
+		PlaceholderEC2PrivateDNSName: args.EC2PrivateDNSName,
+	}
+	if values[PlaceholderSessionName] == "" {
+		values[PlaceholderSessionName] = caller.SessionName
+	}
+
+	// like the authenticator, ARNs are matched regardless of case
+	for _, u := range m.MapUsers {
+		if strings.EqualFold(u.UserARN, canonical) {
+			return renderIdentity(newMapping(UserMappingKind, u.UserARN, u.Username, u.Groups), values), nil
+		}
+	}
+
+	for _, r := range m.MapRoles {
+		if strings.EqualFold(CanonicalRoleARN(r.RoleARN), canonical) {
+			return renderIdentity(newMapping(RoleMappingKind, r.RoleARN, r.Username, r.Groups), values), nil
+		}
+	}
+
+	if slices.Contains(m.MapAccounts, caller.AccountID) {
+		// callers of a mapped account are known by their ARN and have no groups
+		return &Identity{
+			Mapping:  Mapping{Kind: AccountMappingKind, Groups: []string{}, Account: caller.AccountID},
+			Username: canonical,
+			Groups:   []string{},
+		}, nil
+	}
+
+	return nil, &NoMatchError{CallerARN: args.CallerARN, Reasons: m.explainNoMatch(caller, canonical)}
+}
+
+// explainNoMatch returns why no mapping matches the caller, including mappings which nearly match
+func (m *AwsAuthData) explainNoMatch(caller *ARN, canonical string) []string {
+	var reasons []string
+
+	if caller.ResourceType == ResourceTypeAssumedRole {
+		reasons = append(reasons, fmt.Sprintf("the assumed-role session is matched as its role %v", canonical))
+	}
+
+	reasons = append(reasons, fmt.Sprintf("no mapUsers entry for %v", canonical))
+	for _, u := range m.MapUsers {
+		if a, err := ParseARN(u.UserARN); err == nil && strings.EqualFold(a.Name, caller.Name) && a.ResourceType == caller.ResourceType {
+			reasons = append(reasons, fmt.Sprintf("mapUsers has %v, which differs in account, partition or path", u.UserARN))
+		}
+	}
+
+	reasons = append(reasons, fmt.Sprintf("no mapRoles entry for %v, role paths are ignored", canonical))
+	for _, r := range m.MapRoles {
+		if a, err := ParseARN(r.RoleARN); err == nil && strings.EqualFold(a.Name, caller.Name) && caller.isRole() {
+			reasons = append(reasons, fmt.Sprintf("mapRoles has %v, which differs in account or partition", r.RoleARN))
+		}
+	}
+
+	reasons = append(reasons, fmt.Sprintf("account %v is not in mapAccounts", caller.AccountID))
+	return reasons
+}
+
+// renderIdentity returns the identity of a matched mapping with its placeholders expanded
+func renderIdentity(mapping Mapping, values map[string]string) *Identity {
+	identity := &Identity{Mapping: mapping, Groups: []string{}}
+	identity.Username = renderTemplate(mapping.Username, values, &identity.Unresolved)
+	for _, g := range mapping.Groups {
+		identity.Groups = append(identity.Groups, renderTemplate(g, values, &identity.Unresolved))
+	}
+	return identity
+}
@@ -0,0 +1,113 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func resolveAuthData() *AwsAuthData {
+	return &AwsAuthData{
+		MapRoles: []*RolesAuthMap{
+			NewRolesAuthMap("arn:aws:iam::111111111111:role/ci/Deploy", "deploy:{{SessionName}}", []string{"deployers"}),
+			NewRolesAuthMap("arn:aws:iam::111111111111:role/Admin", "admin-role", []string{"viewers"}),
+		},
+		MapUsers: []*UsersAuthMap{
+			NewUsersAuthMap("arn:aws:iam::111111111111:user/alice", "alice", []string{"system:masters"}),
+			// a role ARN in mapUsers takes precedence over mapRoles
+			NewUsersAuthMap("arn:aws:iam::111111111111:role/Admin", "admin-user", []string{"system:masters"}),
+		},
+		MapAccounts: []string{"222222222222"},
+	}
+}
+
+func TestResolve_Precedence(t *testing.T) {
+	g := gomega.NewWithT(t)
+	data := resolveAuthData()
+
+	identity, err := data.Resolve(&RenderArguments{CallerARN: "arn:aws:sts::111111111111:assumed-role/Admin/jane"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Mapping.Kind).To(gomega.Equal(UserMappingKind))
+	g.Expect(identity.Username).To(gomega.Equal("admin-user"))
+
+	identity, err = data.Resolve(&RenderArguments{CallerARN: "arn:aws:sts::111111111111:assumed-role/Deploy/build-42"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Mapping.Name()).To(gomega.Equal("role/arn:aws:iam::111111111111:role/ci/Deploy"))
+	g.Expect(identity.Username).To(gomega.Equal("deploy:build-42"))
+	g.Expect(identity.Groups).To(gomega.Equal([]string{"deployers"}))
+
+	// ARNs are matched regardless of case
+	identity, err = data.Resolve(&RenderArguments{CallerARN: "arn:aws:sts::111111111111:assumed-role/deploy/build-42"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Mapping.Name()).To(gomega.Equal("role/arn:aws:iam::111111111111:role/ci/Deploy"))
+	identity, err = data.Resolve(&RenderArguments{CallerARN: "arn:aws:iam::111111111111:user/Alice"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Username).To(gomega.Equal("alice"))
+
+	identity, err = data.Resolve(&RenderArguments{CallerARN: "arn:aws:sts::222222222222:assumed-role/Anything/x"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Mapping.Name()).To(gomega.Equal("account/222222222222"))
+	g.Expect(identity.Username).To(gomega.Equal("arn:aws:iam::222222222222:role/Anything"))
+	g.Expect(identity.Groups).To(gomega.BeEmpty())
+
+	data.MapUsers = append(data.MapUsers, NewUsersAuthMap("arn:aws:iam::111111111111:root", "root", []string{"system:masters"}))
+	identity, err = data.Resolve(&RenderArguments{CallerARN: "arn:aws:iam::111111111111:root"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Mapping.Kind).To(gomega.Equal(UserMappingKind))
+	g.Expect(identity.Username).To(gomega.Equal("root"))
+}
+
+func TestResolve_NoMatch(t *testing.T) {
+	g := gomega.NewWithT(t)
+	data := resolveAuthData()
+
+	_, err := data.Resolve(&RenderArguments{CallerARN: "arn:aws:sts::333333333333:assumed-role/Deploy/build-42"})
+	g.Expect(err).To(gomega.MatchError(ErrNoMatchingMapping))
+
+	var noMatch *NoMatchError
+	g.Expect(errors.As(err, &noMatch)).To(gomega.BeTrue())
+	g.Expect(noMatch.Reasons).To(gomega.Equal([]string{
+		"the assumed-role session is matched as its role arn:aws:iam::333333333333:role/Deploy",
+		"no mapUsers entry for arn:aws:iam::333333333333:role/Deploy",
+		"no mapRoles entry for arn:aws:iam::333333333333:role/Deploy, role paths are ignored",
+		"mapRoles has arn:aws:iam::111111111111:role/ci/Deploy, which differs in account or partition",
+		"account 333333333333 is not in mapAccounts",
+	}))
+
+	_, err = data.Resolve(&RenderArguments{CallerARN: "arn:aws:iam::111111111111:user/team/alice"})
+	g.Expect(errors.As(err, &noMatch)).To(gomega.BeTrue())
+	g.Expect(noMatch.Reasons).To(gomega.ContainElement("mapUsers has arn:aws:iam::111111111111:user/alice, which differs in account, partition or path"))
+}
+
+func TestMapper_Resolve(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	identity, err := mapper.Resolve(&RenderArguments{CallerARN: "arn:aws:iam::000000000000:user/user-1"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Username).To(gomega.Equal("admin"))
+	g.Expect(identity.Groups).To(gomega.Equal([]string{"system:masters"}))
+
+	_, err = mapper.Resolve(&RenderArguments{CallerARN: "arn:aws:iam::000000000000:user/user-2"})
+	g.Expect(err).To(gomega.MatchError(ErrNoMatchingMapping))
+}
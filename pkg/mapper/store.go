@@ -0,0 +1,290 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AuthStore is where the aws-auth configmap is read from and written to. The ConfigMapStore of
+// the KubernetesClient is used by default, other stores are passed to New with WithStore.
+type AuthStore interface {
+	// Load returns the aws-auth configmap and its revision, a missing configmap is returned empty
+	Load(ctx context.Context) (*v1.ConfigMap, string, error)
+	// Save writes cm when the stored configmap is still at the revision it was loaded at and returns
+	// the new revision. When it changed since, Save fails with a conflict, see errors.IsConflict in
+	// k8s.io/apimachinery/pkg/api/errors, and the change is applied again on freshly loaded data.
+	// With dryRun the write is validated but not made.
+	Save(ctx context.Context, cm *v1.ConfigMap, revision string, dryRun bool) (string, error)
+	// Watch returns a channel which receives the configmap whenever it changes, a deleted configmap
+	// is received empty. The channel is closed when ctx is done or the watch ends.
+	Watch(ctx context.Context) (<-chan *v1.ConfigMap, error)
+}
+
+// ConfigMapStore stores the aws-auth configmap in a cluster, its revision is the resourceVersion
+type ConfigMapStore struct {
+	client kubernetes.Interface
+	ref    configMapRef
+}
+
+// unversionedRevision is the revision of a configmap that exists without a resourceVersion, which the
+// API server always sets but clients which do not version objects, e.g. the fake clientset, leave empty
+const unversionedRevision = "unversioned"
+
+// NewConfigMapStore returns a store for the aws-auth configmap of a cluster, WithNamespace and
+// WithConfigMapName locate the configmap
+func NewConfigMapStore(client kubernetes.Interface, opts ...Option) *ConfigMapStore {
+	return &ConfigMapStore{client: client, ref: newConfigMapRef(opts)}
+}
+
+// Load gets the configmap
+func (s *ConfigMapStore) Load(ctx context.Context) (*v1.ConfigMap, string, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(s.ref.namespace).Get(ctx, s.ref.name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return newAuthConfigMap(s.ref), "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if cm.ResourceVersion == "" {
+		return cm, unversionedRevision, nil
+	}
+	return cm, cm.ResourceVersion, nil
+}
+
+// Save updates the configmap against revision, or creates it when it was missing at Load, i.e. revision
+// is empty. A configmap which was created or deleted since it was loaded fails with a conflict.
+func (s *ConfigMapStore) Save(ctx context.Context, cm *v1.ConfigMap, revision string, dryRun bool) (string, error) {
+	var opts []string
+	if dryRun {
+		opts = []string{metav1.DryRunAll}
+	}
+
+	cm = cm.DeepCopy()
+	cm.ResourceVersion = revision
+	if revision == unversionedRevision {
+		cm.ResourceVersion = ""
+	}
+	if revision == "" {
+		created, err := s.client.CoreV1().ConfigMaps(s.ref.namespace).Create(ctx, cm, metav1.CreateOptions{DryRun: opts})
+		if errors.IsAlreadyExists(err) {
+			// another writer created the configmap since it was loaded
+			return "", errors.NewConflict(v1.Resource("configmaps"), s.ref.name, err)
+		}
+		if err != nil {
+			return "", err
+		}
+		return created.ResourceVersion, nil
+	}
+
+	// an update without a resourceVersion would be unconditional, so it is only made against revision
+	updated, err := s.client.CoreV1().ConfigMaps(s.ref.namespace).Update(ctx, cm, metav1.UpdateOptions{DryRun: opts})
+	if errors.IsNotFound(err) {
+		// another writer deleted the configmap since it was loaded
+		return "", errors.NewConflict(v1.Resource("configmaps"), s.ref.name, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return updated.ResourceVersion, nil
+}
+
+// Watch watches the configmap through the API server
+func (s *ConfigMapStore) Watch(ctx context.Context) (<-chan *v1.ConfigMap, error) {
+	w, err := s.client.CoreV1().ConfigMaps(s.ref.namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", s.ref.name).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *v1.ConfigMap)
+	go func() {
+		defer close(ch)
+		defer w.Stop()
+		for {
+			var event watch.Event
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-w.ResultChan():
+				if !ok {
+					return
+				}
+				event = e
+			}
+
+			cm, ok := event.Object.(*v1.ConfigMap)
+			if !ok || cm.Name != s.ref.name {
+				continue
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+			case watch.Deleted:
+				cm = newAuthConfigMap(s.ref)
+			default:
+				continue
+			}
+
+			select {
+			case ch <- cm:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// MemoryStore keeps the aws-auth configmap in memory, for tests and callers which persist it
+// themselves. Its revision counts the saves.
+type MemoryStore struct {
+	ref      configMapRef
+	mu       sync.Mutex
+	cm       *v1.ConfigMap
+	revision int
+	watchers map[chan *v1.ConfigMap]struct{}
+}
+
+// NewMemoryStore returns a store holding a copy of cm, or no configmap when cm is nil. WithNamespace
+// and WithConfigMapName name the empty configmap returned then.
+func NewMemoryStore(cm *v1.ConfigMap, opts ...Option) *MemoryStore {
+	s := &MemoryStore{ref: newConfigMapRef(opts), watchers: map[chan *v1.ConfigMap]struct{}{}}
+	if cm != nil {
+		s.revision = 1
+		s.cm = cm.DeepCopy()
+		s.cm.ResourceVersion = strconv.Itoa(s.revision)
+	}
+	return s
+}
+
+// Load returns a copy of the configmap
+func (s *MemoryStore) Load(_ context.Context) (*v1.ConfigMap, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cm == nil {
+		return newAuthConfigMap(s.ref), "", nil
+	}
+	return s.cm.DeepCopy(), s.cm.ResourceVersion, nil
+}
+
+// Save replaces the configmap with a copy of cm and notifies the watchers
+func (s *MemoryStore) Save(_ context.Context, cm *v1.ConfigMap, revision string, dryRun bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current string
+	if s.cm != nil {
+		current = s.cm.ResourceVersion
+	}
+	if revision != current {
+		return "", errors.NewConflict(v1.Resource("configmaps"), s.ref.name, fmt.Errorf("revision %q is not the current revision %q", revision, current))
+	}
+
+	next := strconv.Itoa(s.revision + 1)
+	if dryRun {
+		return next, nil
+	}
+
+	s.revision++
+	s.cm = cm.DeepCopy()
+	s.cm.ResourceVersion = next
+	for ch := range s.watchers {
+		notify(ch, s.cm.DeepCopy())
+	}
+	return next, nil
+}
+
+// Watch receives the saved configmaps, a watcher which falls behind only receives the latest one
+func (s *MemoryStore) Watch(ctx context.Context) (<-chan *v1.ConfigMap, error) {
+	ch := make(chan *v1.ConfigMap, 1)
+
+	s.mu.Lock()
+	s.watchers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.watchers, ch)
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// notify sends cm to a watcher with a buffer of one, replacing a configmap it did not receive yet
+func notify(ch chan *v1.ConfigMap, cm *v1.ConfigMap) {
+	for {
+		select {
+		case ch <- cm:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// Option configures an AuthMapper created by New. WithNamespace and WithConfigMapName also locate
+// the configmap of the stores and of ReadAuthMap, CreateAuthMap and UpdateAuthMap.
+type Option func(*AuthMapper)
+
+// WithStore makes the mapper read and write the aws-auth configmap in store instead of the cluster
+// of its KubernetesClient. Backups are only taken when a KubernetesClient is set too.
+func WithStore(store AuthStore) Option {
+	return func(b *AuthMapper) {
+		b.authStore = store
+	}
+}
+
+// store returns the store of the mapper, the cluster of KubernetesClient unless another store was set
+func (b *AuthMapper) store() AuthStore {
+	if b.authStore != nil {
+		return b.authStore
+	}
+	return &ConfigMapStore{client: b.KubernetesClient, ref: b.configMapRef()}
+}
+
+// clusterRequired returns ErrClusterRequired when the mapper has no kubernetes client
+func (b *AuthMapper) clusterRequired(feature string) error {
+	if b.KubernetesClient == nil {
+		return fmt.Errorf("%w: %v", ErrClusterRequired, feature)
+	}
+	return nil
+}
+
+func newAuthConfigMap(ref configMapRef) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ref.name,
+			Namespace: ref.namespace,
+		},
+	}
+}
@@ -0,0 +1,171 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMemoryStore(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewMemoryStore(nil)
+
+	cm, revision, err := store.Load(ctx)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(revision).To(gomega.BeEmpty())
+	g.Expect(cm.Name).To(gomega.Equal(AwsAuthName))
+
+	events, err := store.Watch(ctx)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	cm.Data = map[string]string{"mapAccounts": "- \"111111111111\"\n"}
+	_, err = store.Save(ctx, cm, revision, true)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(events).NotTo(gomega.Receive())
+
+	next, err := store.Save(ctx, cm, revision, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(next).NotTo(gomega.Equal(revision))
+
+	var saved *v1.ConfigMap
+	g.Eventually(events).Should(gomega.Receive(&saved))
+	g.Expect(saved.Data).To(gomega.Equal(cm.Data))
+	g.Expect(saved.ResourceVersion).To(gomega.Equal(next))
+
+	// the revision the change was made at is stale now
+	_, err = store.Save(ctx, cm, revision, false)
+	g.Expect(errors.IsConflict(err)).To(gomega.BeTrue())
+
+	cancel()
+	g.Eventually(events).Should(gomega.BeClosed())
+}
+
+func TestConfigMapStore(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := fake.NewSimpleClientset()
+	store := NewConfigMapStore(client)
+
+	events, err := store.Watch(ctx)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	cm, revision, err := store.Load(ctx)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(revision).To(gomega.BeEmpty())
+
+	// the missing configmap is created
+	cm.Data = map[string]string{"mapAccounts": "- \"111111111111\"\n"}
+	_, err = store.Save(ctx, cm, revision, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	var saved *v1.ConfigMap
+	g.Eventually(events).Should(gomega.Receive(&saved))
+	g.Expect(saved.Data).To(gomega.Equal(cm.Data))
+
+	g.Expect(client.CoreV1().ConfigMaps(AwsAuthNamespace).Delete(ctx, AwsAuthName, metav1.DeleteOptions{})).To(gomega.Succeed())
+	g.Eventually(events).Should(gomega.Receive(&saved))
+	g.Expect(saved.Data).To(gomega.BeEmpty())
+
+	cancel()
+	g.Eventually(events).Should(gomega.BeClosed())
+}
+
+func TestConfigMapStore_CreatedSinceLoad(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	store := NewConfigMapStore(client)
+
+	cm, revision, err := store.Load(ctx)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(revision).To(gomega.BeEmpty())
+
+	// another writer creates the configmap before it is saved
+	other := newAuthConfigMap(newConfigMapRef(nil))
+	other.Data = map[string]string{"mapRoles": "other"}
+	_, err = client.CoreV1().ConfigMaps(AwsAuthNamespace).Create(ctx, other, metav1.CreateOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	cm.Data = map[string]string{"mapRoles": "mine"}
+	_, err = store.Save(ctx, cm, revision, false)
+	g.Expect(errors.IsConflict(err)).To(gomega.BeTrue())
+
+	stored, err := client.CoreV1().ConfigMaps(AwsAuthNamespace).Get(ctx, AwsAuthName, metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(stored.Data).To(gomega.Equal(other.Data))
+}
+
+func TestFileStore_Watch(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := file_MockManifest(t, testManifest)
+	store := NewFileStore(path)
+	store.pollInterval = 10 * time.Millisecond
+
+	events, err := store.Watch(ctx)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Consistently(events, 50*time.Millisecond).ShouldNot(gomega.Receive())
+
+	// edits made outside of the store are picked up too
+	g.Expect(os.WriteFile(path, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: aws-auth\ndata:\n  mapAccounts: |\n    - \"111111111111\"\n"), 0600)).To(gomega.Succeed())
+
+	var saved *v1.ConfigMap
+	g.Eventually(events).Should(gomega.Receive(&saved))
+	g.Expect(saved.Data).To(gomega.HaveKey("mapAccounts"))
+
+	cancel()
+	g.Eventually(events).Should(gomega.BeClosed())
+}
+
+func TestMapper_WithStore(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	store := NewMemoryStore(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: AwsAuthName, Namespace: AwsAuthNamespace},
+		Data:       map[string]string{"mapRoles": "- rolearn: arn:aws:iam::000000000000:role/node-1\n  username: system:node:{{EC2PrivateDNSName}}\n  groups:\n  - system:nodes\n"},
+	})
+	mapper := New(nil, true, WithStore(store))
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/admin",
+		Username: "admin",
+		Groups:   []string{"admins"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	cm, revision, err := store.Load(context.Background())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(revision).To(gomega.Equal("2"))
+	g.Expect(cm.Data["mapRoles"]).To(gomega.ContainSubstring("role/admin"))
+
+	auth, err := mapper.Get(&MapperArguments{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(2))
+}
@@ -0,0 +1,103 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// SyncOptions are the options of Sync
+type SyncOptions struct {
+	// Filter selects the mappings of the source which are copied, all of them when it is nil
+	Filter *MappingFilter
+	// Append adds the groups of the source to the groups of an existing mapping instead of replacing them
+	Append bool
+	// DryRun computes the changes without persisting them
+	DryRun DryRunMode
+}
+
+// Sync copies the mappings of source which are selected by opts.Filter into the aws-auth configmap,
+// typically source was read from another cluster with ReadAuthMap. Mappings are copied like Upsert
+// does: missing ones are added and existing ones with the same ARN or account take the username and
+// groups of the source. Mappings which are not selected are left untouched, nothing is removed.
+// The returned plan lists the changes which were made, or would be made with opts.DryRun.
+func (b *AuthMapper) Sync(source *AwsAuthData, opts SyncOptions) (*Plan, error) {
+	return b.SyncWithContext(context.Background(), source, opts)
+}
+
+// SyncWithContext is like Sync but uses the given context for the API calls
+func (b *AuthMapper) SyncWithContext(ctx context.Context, source *AwsAuthData, opts SyncOptions) (*Plan, error) {
+	if opts.DryRun != DryRunNone && opts.DryRun != DryRunClient && opts.DryRun != DryRunServer {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDryRun, opts.DryRun)
+	}
+
+	selected := *source
+	if opts.Filter != nil {
+		selected = source.Filter(opts.Filter)
+	}
+	if err := selected.Validate(); err != nil {
+		return nil, err
+	}
+
+	fn := syncMutation(&selected, &UpsertOptions{Append: opts.Append, UpdateUsername: true})
+	if opts.DryRun != DryRunNone {
+		return b.planMutation(ctx, OperationSync, fn, opts.DryRun)
+	}
+
+	var plan *Plan
+	err := b.mutateAuthMap(ctx, OperationSync, func(authData *AwsAuthData) (bool, error) {
+		before := authData.Mappings().Items
+		updated, err := fn(authData)
+
+		plan = &Plan{Changes: []Change{}}
+		diffMappings(plan, before, authData.Mappings().Items)
+		return updated, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// syncMutation returns the mutation which upserts every mapping of source
+func syncMutation(source *AwsAuthData, opts *UpsertOptions) authMapMutation {
+	return func(authData *AwsAuthData) (bool, error) {
+		var updated bool
+
+		for _, r := range source.MapRoles {
+			// the groups are copied, an added mapping must not share them with the source
+			newMap, ok := upsertRole(authData.MapRoles, NewRolesAuthMap(r.RoleARN, r.Username, slices.Clone(r.Groups)), opts)
+			authData.SetMapRoles(newMap)
+			updated = updated || ok
+		}
+
+		for _, u := range source.MapUsers {
+			newMap, ok := upsertUser(authData.MapUsers, NewUsersAuthMap(u.UserARN, u.Username, slices.Clone(u.Groups)), opts)
+			authData.SetMapUsers(newMap)
+			updated = updated || ok
+		}
+
+		for _, a := range source.MapAccounts {
+			newMap, ok := upsertAccount(authData.MapAccounts, a)
+			authData.SetMapAccounts(newMap)
+			updated = updated || ok
+		}
+
+		return updated, nil
+	}
+}
@@ -0,0 +1,127 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const syncTarget = `- rolearn: arn:aws:iam::000000000000:role/node-1
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+  - system:nodes
+- rolearn: arn:aws:iam::000000000000:role/ci
+  username: ci
+  groups:
+  - viewers
+`
+
+func sync_MockStore() *MemoryStore {
+	return NewMemoryStore(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: AwsAuthName, Namespace: AwsAuthNamespace},
+		Data:       map[string]string{"mapRoles": syncTarget},
+	})
+}
+
+func TestSync(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	source, err := UnmarshalAuthData([]byte(desiredState))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	store := sync_MockStore()
+	mapper := New(nil, true, WithStore(store))
+
+	plan, err := mapper.Sync(&source, SyncOptions{Filter: &MappingFilter{RoleARN: "arn:aws:iam::*:role/ci"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(1))
+	g.Expect(plan.Changes[0].Action).To(gomega.Equal(ChangeModify))
+	g.Expect(plan.Changes[0].Name).To(gomega.Equal("role/arn:aws:iam::000000000000:role/ci"))
+
+	auth, err := mapper.Get(&MapperArguments{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(2))
+	g.Expect(auth.MapRoles[1].Groups).To(gomega.Equal([]string{"deployers"}))
+	g.Expect(auth.MapUsers).To(gomega.BeEmpty())
+	g.Expect(auth.MapAccounts).To(gomega.BeEmpty())
+
+	// mappings of the target which are not in the source are kept
+	plan, err = mapper.Sync(&source, SyncOptions{Filter: &MappingFilter{AccountID: "111111111111"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(1))
+	g.Expect(plan.Changes[0].Action).To(gomega.Equal(ChangeAdd))
+
+	auth, err = mapper.Get(&MapperArguments{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth.MapRoles).To(gomega.HaveLen(2))
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"111111111111"}))
+
+	// nothing changes when the target is in sync
+	_, revision, err := store.Load(context.Background())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	plan, err = mapper.Sync(&source, SyncOptions{Filter: &MappingFilter{AccountID: "111111111111"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.IsEmpty()).To(gomega.BeTrue())
+	_, current, err := store.Load(context.Background())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(current).To(gomega.Equal(revision))
+}
+
+func TestSync_DryRun(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	source, err := UnmarshalAuthData([]byte(desiredState))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	store := sync_MockStore()
+	mapper := New(nil, true, WithStore(store))
+
+	plan, err := mapper.Sync(&source, SyncOptions{DryRun: DryRunClient, Append: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plan.Changes).To(gomega.HaveLen(4))
+	g.Expect(plan.Changes[1].After.Groups).To(gomega.Equal([]string{"viewers", "deployers"}))
+	g.Expect(source.MapRoles[1].Groups).To(gomega.Equal([]string{"deployers"}))
+
+	cm, revision, err := store.Load(context.Background())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(revision).To(gomega.Equal("1"))
+	g.Expect(cm.Data["mapRoles"]).To(gomega.Equal(syncTarget))
+
+	_, err = mapper.Sync(&source, SyncOptions{DryRun: "always"})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidDryRun))
+}
+
+func TestSync_Protected(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	source, err := UnmarshalAuthData([]byte(desiredState))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	store := NewMemoryStore(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        AwsAuthName,
+			Namespace:   AwsAuthNamespace,
+			Annotations: map[string]string{ProtectedAnnotation: "arn:aws:iam::000000000000:role/ci"},
+		},
+		Data: map[string]string{"mapRoles": syncTarget},
+	})
+	mapper := New(nil, true, WithStore(store))
+
+	_, err = mapper.Sync(&source, SyncOptions{})
+	g.Expect(err).To(gomega.MatchError(ErrProtected))
+}
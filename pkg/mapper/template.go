@@ -0,0 +1,134 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Placeholders supported by aws-iam-authenticator in usernames and groups
+const (
+	PlaceholderEC2PrivateDNSName = "EC2PrivateDNSName"
+	PlaceholderSessionName       = "SessionName"
+	PlaceholderSessionNameRaw    = "SessionNameRaw"
+	PlaceholderAccountID         = "AccountID"
+	PlaceholderAccessKeyID       = "AccessKeyID"
+)
+
+// TemplatePlaceholders are the placeholders accepted in a username template
+var TemplatePlaceholders = []string{
+	PlaceholderEC2PrivateDNSName,
+	PlaceholderSessionName,
+	PlaceholderSessionNameRaw,
+	PlaceholderAccountID,
+	PlaceholderAccessKeyID,
+}
+
+var placeholderPattern = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+
+// ValidateUsernameTemplate returns an error if s has a placeholder which is not supported by the
+// authenticator, or unbalanced braces
+func ValidateUsernameTemplate(s string) error {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+		name := m[1]
+		if slices.Contains(TemplatePlaceholders, name) {
+			continue
+		}
+
+		reason := fmt.Sprintf("unknown placeholder %v, supported placeholders are {{%v}}", m[0], strings.Join(TemplatePlaceholders, "}}, {{"))
+		for _, p := range TemplatePlaceholders {
+			if strings.EqualFold(p, strings.TrimSpace(name)) {
+				reason = fmt.Sprintf("unknown placeholder %v, did you mean {{%v}}", m[0], p)
+			}
+		}
+		return &TemplateError{Template: s, Reason: reason}
+	}
+
+	rest := placeholderPattern.ReplaceAllString(s, "")
+	if strings.Contains(rest, "{{") || strings.Contains(rest, "}}") {
+		return &TemplateError{Template: s, Reason: "unbalanced {{ or }}"}
+	}
+	return nil
+}
+
+// RenderArguments describe the caller whose identity is rendered. SessionName defaults to the
+// session name of an assumed-role CallerARN. AccessKeyID and EC2PrivateDNSName are only known
+// to the authenticator at login, placeholders for them are left in place when they are not set.
+type RenderArguments struct {
+	CallerARN         string
+	SessionName       string
+	AccessKeyID       string
+	EC2PrivateDNSName string
+}
+
+// Identity is the Kubernetes username and groups a caller is mapped to
+type Identity struct {
+	// Mapping is the mapping which matched the caller
+	Mapping  Mapping  `json:"mapping"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups"`
+	// Unresolved are the placeholders which could not be rendered from the arguments
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// Render returns the username and groups of the mapping in the aws-auth configmap which matches the
+// caller of args, with their placeholders expanded the way the authenticator expands them. The
+// mapping is found the way Resolve finds it.
+func (b *AuthMapper) Render(args *RenderArguments) (*Identity, error) {
+	return b.RenderWithContext(context.Background(), args)
+}
+
+// RenderWithContext is like Render but uses the given context for the API calls
+func (b *AuthMapper) RenderWithContext(ctx context.Context, args *RenderArguments) (*Identity, error) {
+	authData, err := b.getAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return authData.Render(args)
+}
+
+// Render is like AuthMapper.Render for the given mappings
+func (m *AwsAuthData) Render(args *RenderArguments) (*Identity, error) {
+	return m.Resolve(args)
+}
+
+// renderTemplate expands the placeholders of s, placeholders without a value are left in place and
+// added to unresolved
+func renderTemplate(s string, values map[string]string, unresolved *[]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := strings.Trim(placeholder, "{}")
+
+		value := values[name]
+		if name == PlaceholderSessionNameRaw {
+			value = values[PlaceholderSessionName]
+		} else if name == PlaceholderSessionName {
+			// the authenticator replaces @ as it is not allowed in some usernames
+			value = strings.ReplaceAll(value, "@", "-")
+		}
+
+		if value == "" {
+			if !slices.Contains(*unresolved, name) {
+				*unresolved = append(*unresolved, name)
+			}
+			return placeholder
+		}
+		return value
+	})
+}
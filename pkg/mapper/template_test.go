@@ -0,0 +1,146 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateUsernameTemplate(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	for _, s := range []string{
+		"admin",
+		"system:node:{{EC2PrivateDNSName}}",
+		"deploy:{{AccountID}}:{{SessionName}}",
+		"{{SessionNameRaw}}",
+		"key-{{AccessKeyID}}",
+	} {
+		g.Expect(ValidateUsernameTemplate(s)).To(gomega.Succeed(), s)
+	}
+
+	err := ValidateUsernameTemplate("system:node:{{EC2PrivateDnsName}}")
+	g.Expect(err).To(gomega.MatchError(ErrInvalidUsernameTemplate))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("did you mean {{EC2PrivateDNSName}}"))
+
+	err = ValidateUsernameTemplate("{{InstanceID}}")
+	g.Expect(err).To(gomega.MatchError(ErrInvalidUsernameTemplate))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("supported placeholders are {{EC2PrivateDNSName}}, {{SessionName}}"))
+
+	err = ValidateUsernameTemplate("system:node:{{EC2PrivateDNSName}")
+	g.Expect(err).To(gomega.MatchError(ErrInvalidUsernameTemplate))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("unbalanced"))
+}
+
+func TestMapper_UpsertRejectsInvalidTemplate(t *testing.T) {
+	g := gomega.NewWithT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, false)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
+		Username: "system:node:{{EC2PrivateDnsName}}",
+	})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidUsernameTemplate))
+
+	err = mapper.UpsertMultiple(nil, []*UsersAuthMap{
+		NewUsersAuthMap("arn:aws:iam::000000000000:user/alice", "{{sessionname}}", nil),
+	})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidUsernameTemplate))
+
+	err = (&AwsAuthData{
+		MapRoles: []*RolesAuthMap{NewRolesAuthMap("arn:aws:iam::000000000000:role/ci", "ci-{{Session}}", nil)},
+	}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrInvalidUsernameTemplate))
+	g.Expect(err.Error()).To(gomega.HavePrefix("mapRoles[0]: invalid username template"))
+
+	g.Expect(client.Actions()).To(gomega.BeEmpty())
+}
+
+func TestRender(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	data := &AwsAuthData{
+		MapRoles: []*RolesAuthMap{
+			NewRolesAuthMap("arn:aws:iam::111111111111:role/eks/node", "system:node:{{EC2PrivateDNSName}}", []string{"system:bootstrappers", "system:nodes"}),
+			NewRolesAuthMap("arn:aws:iam::111111111111:role/Deploy", "deploy:{{AccountID}}:{{SessionName}}", []string{"deployers"}),
+		},
+		MapUsers: []*UsersAuthMap{
+			NewUsersAuthMap("arn:aws:iam::111111111111:user/alice", "alice", []string{"system:masters"}),
+		},
+	}
+
+	identity, err := data.Render(&RenderArguments{CallerARN: "arn:aws:sts::111111111111:assumed-role/Deploy/jane@example.com"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Mapping.Name()).To(gomega.Equal("role/arn:aws:iam::111111111111:role/Deploy"))
+	g.Expect(identity.Username).To(gomega.Equal("deploy:111111111111:jane-example.com"))
+	g.Expect(identity.Groups).To(gomega.Equal([]string{"deployers"}))
+	g.Expect(identity.Unresolved).To(gomega.BeEmpty())
+
+	// roles are matched regardless of their path, the instance name is only known at login
+	identity, err = data.Render(&RenderArguments{CallerARN: "arn:aws:sts::111111111111:assumed-role/node/i-0123456789abcdef0"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Username).To(gomega.Equal("system:node:{{EC2PrivateDNSName}}"))
+	g.Expect(identity.Unresolved).To(gomega.Equal([]string{PlaceholderEC2PrivateDNSName}))
+
+	identity, err = data.Render(&RenderArguments{
+		CallerARN:         "arn:aws:iam::111111111111:role/node",
+		EC2PrivateDNSName: "ip-10-0-0-1.ec2.internal",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Username).To(gomega.Equal("system:node:ip-10-0-0-1.ec2.internal"))
+
+	identity, err = data.Render(&RenderArguments{CallerARN: "arn:aws:iam::111111111111:user/alice"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Mapping.Kind).To(gomega.Equal(UserMappingKind))
+	g.Expect(identity.Username).To(gomega.Equal("alice"))
+
+	_, err = data.Render(&RenderArguments{CallerARN: "arn:aws:iam::111111111111:user/bob"})
+	g.Expect(err).To(gomega.MatchError(ErrNoMatchingMapping))
+
+	_, err = data.Render(&RenderArguments{CallerARN: "bob"})
+	g.Expect(err).To(gomega.MatchError(ErrInvalidARN))
+}
+
+func TestRenderTemplate_SessionNameRaw(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var unresolved []string
+	values := map[string]string{PlaceholderSessionName: "jane@example.com"}
+	g.Expect(renderTemplate("{{SessionNameRaw}}/{{SessionName}}", values, &unresolved)).To(gomega.Equal("jane@example.com/jane-example.com"))
+	g.Expect(renderTemplate("{{AccessKeyID}}{{AccessKeyID}}", values, &unresolved)).To(gomega.Equal("{{AccessKeyID}}{{AccessKeyID}}"))
+	g.Expect(unresolved).To(gomega.Equal([]string{PlaceholderAccessKeyID}))
+}
+
+func TestMapper_Render(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMap(client)
+
+	identity, err := mapper.Render(&RenderArguments{
+		CallerARN:         "arn:aws:sts::000000000000:assumed-role/node-1/i-0123456789abcdef0",
+		EC2PrivateDNSName: "ip-10-0-0-1.ec2.internal",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(identity.Username).To(gomega.Equal("system:node:ip-10-0-0-1.ec2.internal"))
+	g.Expect(identity.Groups).To(gomega.Equal([]string{"system:bootstrappers", "system:nodes"}))
+}
@@ -0,0 +1,241 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// The variables of the terraform-aws-modules/eks aws-auth module
+const (
+	terraformRoles    = "aws_auth_roles"
+	terraformUsers    = "aws_auth_users"
+	terraformAccounts = "aws_auth_accounts"
+)
+
+// terraformFilename is the file name of the imported HCL in parse errors
+const terraformFilename = "terraform"
+
+// writeTerraform writes authData as the aws-auth module variables of a tfvars file
+func writeTerraform(w io.Writer, authData *AwsAuthData) error {
+	var s strings.Builder
+	mappings := authData.Mappings().Items
+
+	writeList := func(name string, kind MappingKind, item func(m Mapping) string) {
+		var items []string
+		for _, m := range mappings {
+			if m.Kind == kind {
+				items = append(items, item(m))
+			}
+		}
+		if len(items) == 0 {
+			fmt.Fprintf(&s, "%v = []\n", name)
+			return
+		}
+		fmt.Fprintf(&s, "%v = [\n%v]\n", name, strings.Join(items, ""))
+	}
+	object := func(arnKey string) func(m Mapping) string {
+		return func(m Mapping) string {
+			groups := make([]string, len(m.Groups))
+			for i, g := range m.Groups {
+				groups[i] = hclString(g)
+			}
+			return fmt.Sprintf("  {\n    %-8v = %v\n    username = %v\n    groups   = [%v]\n  },\n",
+				arnKey, hclString(m.ARN), hclString(m.Username), strings.Join(groups, ", "))
+		}
+	}
+
+	writeList(terraformRoles, RoleMappingKind, object("rolearn"))
+	s.WriteString("\n")
+	writeList(terraformUsers, UserMappingKind, object("userarn"))
+	s.WriteString("\n")
+	writeList(terraformAccounts, AccountMappingKind, func(m Mapping) string {
+		return fmt.Sprintf("  %v,\n", hclString(m.Account))
+	})
+
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// hclString quotes s as an HCL string literal. Only the escapes of the HCL spec are used, other control
+// characters are written as \uNNNN or \UNNNNNNNN and template sequences are escaped.
+func hclString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case (r == '$' || r == '%') && strings.HasPrefix(s[i+1:], "{"):
+			b.WriteRune(r)
+			b.WriteRune(r)
+		case r > 0xffff && !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\U%08x`, r)
+		case r == utf8.RuneError || !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// parseTerraform reads the aws-auth module variables from HCL, either a tfvars file or the arguments of
+// a module block. Only the variables are evaluated, they must be literal values, everything else is skipped.
+func parseTerraform(data []byte) (AwsAuthData, error) {
+	file, diags := hclsyntax.ParseConfig(data, terraformFilename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return AwsAuthData{}, diags
+	}
+
+	var authData AwsAuthData
+	var walk func(body *hclsyntax.Body) error
+	walk = func(body *hclsyntax.Body) error {
+		for _, name := range []string{terraformRoles, terraformUsers, terraformAccounts} {
+			attr, ok := body.Attributes[name]
+			if !ok {
+				continue
+			}
+			// without an evaluation context variables and function calls fail, only literals have a value
+			value, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return fmt.Errorf("%v: only literal values can be imported: %w", name, diags)
+			}
+			if err := authData.addTerraform(name, ctyToGo(value)); err != nil {
+				return fmt.Errorf("%v: %w", name, err)
+			}
+		}
+		for _, block := range body.Blocks {
+			if err := walk(block.Body); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(file.Body.(*hclsyntax.Body)); err != nil {
+		return AwsAuthData{}, err
+	}
+	return authData, nil
+}
+
+// ctyToGo converts an HCL value to strings, lists and maps, numbers are kept as their decimal text
+func ctyToGo(v cty.Value) interface{} {
+	if v.IsNull() || !v.IsKnown() {
+		return nil
+	}
+
+	t := v.Type()
+	switch {
+	case t == cty.String:
+		return v.AsString()
+	case t == cty.Number:
+		return v.AsBigFloat().Text('f', -1)
+	case t == cty.Bool:
+		return v.True()
+	case t.IsTupleType() || t.IsListType() || t.IsSetType():
+		list := []interface{}{}
+		for it := v.ElementIterator(); it.Next(); {
+			_, e := it.Element()
+			list = append(list, ctyToGo(e))
+		}
+		return list
+	case t.IsObjectType() || t.IsMapType():
+		object := map[string]interface{}{}
+		for it := v.ElementIterator(); it.Next(); {
+			k, e := it.Element()
+			object[k.AsString()] = ctyToGo(e)
+		}
+		return object
+	}
+	return nil
+}
+
+// addTerraform adds the mappings of the value of one of the aws-auth module variables
+func (m *AwsAuthData) addTerraform(name string, value interface{}) error {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("must be a list")
+	}
+
+	for i, item := range list {
+		if name == terraformAccounts {
+			account, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("[%d] must be a string", i)
+			}
+			m.MapAccounts = append(m.MapAccounts, account)
+			continue
+		}
+
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("[%d] must be an object", i)
+		}
+		var arn, username string
+		var groups []string
+		for key, v := range object {
+			switch key {
+			case "rolearn", "userarn", "username":
+				s, ok := v.(string)
+				if !ok {
+					return fmt.Errorf("[%d].%v must be a string", i, key)
+				}
+				if key == "username" {
+					username = s
+				} else {
+					arn = s
+				}
+			case "groups":
+				l, ok := v.([]interface{})
+				if !ok {
+					return fmt.Errorf("[%d].groups must be a list", i)
+				}
+				for _, g := range l {
+					s, ok := g.(string)
+					if !ok {
+						return fmt.Errorf("[%d].groups must be a list of strings", i)
+					}
+					groups = append(groups, s)
+				}
+			}
+		}
+
+		if name == terraformRoles {
+			m.MapRoles = append(m.MapRoles, NewRolesAuthMap(arn, username, groups))
+		} else {
+			m.MapUsers = append(m.MapUsers, NewUsersAuthMap(arn, username, groups))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestExport_Terraform(t *testing.T) {
+	g := gomega.NewWithT(t)
+	authData := AwsAuthData{
+		MapRoles: []*RolesAuthMap{NewRolesAuthMap("arn:aws:iam::000000000000:role/ci", "ci-${team}", []string{"deployers"})},
+	}
+
+	var buf bytes.Buffer
+	g.Expect(ExportAuthData(&buf, &authData, ExportOptions{Format: FormatTerraform})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal(`aws_auth_roles = [
+  {
+    rolearn  = "arn:aws:iam::000000000000:role/ci"
+    username = "ci-$${team}"
+    groups   = ["deployers"]
+  },
+]
+
+aws_auth_users = []
+
+aws_auth_accounts = []
+`))
+
+	imported, err := ImportAuthData(buf.Bytes(), FormatTerraform)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(imported).To(gomega.Equal(authData))
+}
+
+func TestImport_TerraformModule(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	imported, err := ImportAuthData([]byte(`module "eks" {
+  source  = "terraform-aws-modules/eks/aws//modules/aws-auth"
+  version = "~> 20.0"
+
+  manage_aws_auth_configmap = true
+
+  # mappings
+  aws_auth_roles = [
+    {
+      rolearn  = "arn:aws:iam::000000000000:role/ci" // deployments
+      username = "ci"
+      groups   = ["deployers", "viewers"]
+    }
+  ]
+  aws_auth_users = [{ "userarn" = "arn:aws:iam::000000000000:user/alice", username = "alice", groups = [] }]
+  /* accounts
+     of the organization */
+  aws_auth_accounts = ["111111111111", "222222222222"]
+
+  tags = merge(local.tags, { Team = "platform" })
+}
+`), FormatTerraform)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(imported.MapRoles).To(gomega.Equal([]*RolesAuthMap{NewRolesAuthMap("arn:aws:iam::000000000000:role/ci", "ci", []string{"deployers", "viewers"})}))
+	g.Expect(imported.MapUsers).To(gomega.HaveLen(1))
+	g.Expect(imported.MapUsers[0].Username).To(gomega.Equal("alice"))
+	g.Expect(imported.MapAccounts).To(gomega.Equal([]string{"111111111111", "222222222222"}))
+}
+
+func TestImport_TerraformNotLiteral(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	_, err := ImportAuthData([]byte("aws_auth_roles = local.roles\n"), FormatTerraform)
+	g.Expect(err).To(gomega.MatchError("aws_auth_roles: only literal values can be imported: terraform:1,18-23: Variables not allowed; Variables may not be used here."))
+
+	_, err = ImportAuthData([]byte("aws_auth_accounts = [\"${var.account}\"]\n"), FormatTerraform)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("only literal values can be imported")))
+
+	_, err = ImportAuthData([]byte("aws_auth_accounts = [format(\"%s\", \"111111111111\")]\n"), FormatTerraform)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("Function calls not allowed")))
+
+	_, err = ImportAuthData([]byte("aws_auth_users = [\n  {\n    userarn = \"arn:aws:iam::000000000000:user/alice\n"), FormatTerraform)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("terraform:3,52-4,1: Invalid multi-line string")))
+
+	_, err = ImportAuthData([]byte("aws_auth_users = \"alice\"\n"), FormatTerraform)
+	g.Expect(err).To(gomega.MatchError("aws_auth_users: must be a list"))
+}
+
+func TestImport_TerraformHeredocAndEscapes(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	imported, err := ImportAuthData([]byte(`aws_auth_roles = [
+  {
+    rolearn  = <<-EOT
+      arn:aws:iam::000000000000:role/ci
+    EOT
+    username = "ci-\u00e9\t$${team}"
+    groups   = ["deployers"] # the "${" in a comment is not a template
+  },
+]
+`), FormatTerraform)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	// a heredoc keeps its final newline
+	g.Expect(imported.MapRoles).To(gomega.Equal([]*RolesAuthMap{NewRolesAuthMap("arn:aws:iam::000000000000:role/ci\n", "ci-\u00e9\t${team}", []string{"deployers"})}))
+
+	// control characters are written with the escapes of HCL and read back unchanged
+	authData := AwsAuthData{
+		MapUsers: []*UsersAuthMap{NewUsersAuthMap("arn:aws:iam::000000000000:user/alice", "a\x00l\ai\"c\\e\r\n%{x}", []string{"viewers"})},
+	}
+	var buf bytes.Buffer
+	g.Expect(ExportAuthData(&buf, &authData, ExportOptions{Format: FormatTerraform})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.ContainSubstring(`username = "a\u0000l\u0007i\"c\\e\r\n%%{x}"`))
+
+	imported, err = ImportAuthData(buf.Bytes(), FormatTerraform)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(imported).To(gomega.Equal(authData))
+}
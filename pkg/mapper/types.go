@@ -16,14 +16,18 @@ limitations under the License.
 package mapper
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"log"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/jpillora/backoff"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -35,11 +39,32 @@ func init() {
 type AuthMapper struct {
 	KubernetesClient kubernetes.Interface
 	LoggingEnabled   bool
+	// Backups configures the backups taken before every write, they are enabled by default
+	Backups BackupOptions
+	// Actor is recorded as the author of the changes in the change history, the identity of the
+	// kubernetes client is looked up when it is empty
+	Actor string
+	// OverrideProtection allows removing and modifying the mappings protected by the ProtectedAnnotation
+	OverrideProtection bool
+	// Policies are evaluated against the data of every write, which is refused if it violates any of them
+	Policies []Policy
+	// Logger receives the messages of the mapper, the standard logger when nil
+	Logger *log.Logger
+
+	// authStore is where the configmap is read and written, the cluster of KubernetesClient when nil
+	authStore AuthStore
+	// configMap locates the configmap, set with WithNamespace and WithConfigMapName
+	configMap configMapRef
 }
 
-func New(client kubernetes.Interface, isCommandline bool) *AuthMapper {
+// New returns an AuthMapper for the aws-auth configmap of the cluster of client. The client may be nil
+// when another store is passed with WithStore.
+func New(client kubernetes.Interface, isCommandline bool, opts ...Option) *AuthMapper {
 	var mapper = &AuthMapper{}
 	mapper.KubernetesClient = client
+	for _, opt := range opts {
+		opt(mapper)
+	}
 
 	if !isCommandline {
 		log.SetOutput(io.Discard)
@@ -47,16 +72,36 @@ func New(client kubernetes.Interface, isCommandline bool) *AuthMapper {
 	return mapper
 }
 
+// logf writes a message to the logger of the mapper
+func (b *AuthMapper) logf(format string, v ...interface{}) {
+	if b.Logger != nil {
+		b.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
 var (
 	DefaultRetryerBackoffFactor float64 = 2.0
 	DefaultRetryerBackoffJitter         = true
 	UpdateUsernameDefaultValue  bool    = true
+
+	// DefaultConflictRetryBackoff is the backoff used to re-read and re-apply a change
+	// when the aws-auth configmap was modified by another writer
+	DefaultConflictRetryBackoff = wait.Backoff{
+		Steps:    20,
+		Duration: 10 * time.Millisecond,
+		Factor:   1.5,
+		Jitter:   0.5,
+		Cap:      2 * time.Second,
+	}
 )
 
 // AwsAuthData represents the data of the aws-auth configmap
 type AwsAuthData struct {
-	MapRoles []*RolesAuthMap `yaml:"mapRoles"`
-	MapUsers []*UsersAuthMap `yaml:"mapUsers"`
+	MapRoles    []*RolesAuthMap `yaml:"mapRoles"`
+	MapUsers    []*UsersAuthMap `yaml:"mapUsers"`
+	MapAccounts []string        `yaml:"mapAccounts"`
 }
 
 // SetMapRoles sets the MapRoles element
@@ -69,68 +114,150 @@ func (m *AwsAuthData) SetMapUsers(authMap []*UsersAuthMap) {
 	m.MapUsers = authMap
 }
 
+// SetMapAccounts sets the MapAccounts element
+func (m *AwsAuthData) SetMapAccounts(accounts []string) {
+	m.MapAccounts = accounts
+}
+
 type OperationType string
 
 const (
 	OperationUpsert OperationType = "upsert"
 	OperationRemove OperationType = "remove"
 	OperationGet    OperationType = "get"
+
+	OperationRemoveByUsername OperationType = "remove-by-username"
+	OperationNormalize        OperationType = "normalize"
+	OperationUpsertMultiple   OperationType = "upsert-multiple"
+	OperationApply            OperationType = "apply"
+	OperationRestore          OperationType = "restore"
+	OperationRollback         OperationType = "rollback"
+	OperationSync             OperationType = "sync"
+)
+
+// DryRunMode selects how a mutating operation is previewed
+type DryRunMode string
+
+const (
+	// DryRunNone applies the change
+	DryRunNone DryRunMode = ""
+	// DryRunClient computes the change without sending it to the API server
+	DryRunClient DryRunMode = "client"
+	// DryRunServer also submits the update to the API server with the DryRun option,
+	// so it is validated and admitted without being persisted
+	DryRunServer DryRunMode = "server"
+)
+
+// Output formats supported by the get operation
+const (
+	FormatTable = "table"
+	FormatWide  = "wide"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+	FormatName  = "name"
+)
+
+// Output formats supported by a dry run of a mutating operation
+const (
+	FormatDiff = "diff"
+)
+
+// Output formats supported by lint, next to table and json
+const (
+	FormatSARIF = "sarif"
 )
 
-// MapperArguments are the arguments for removing a mapRole or mapUsers
+// SupportedFormats are the values accepted for MapperArguments.Format
+var SupportedFormats = []string{FormatTable, FormatWide, FormatJSON, FormatYAML, FormatName}
+
+// SupportedPlanFormats are the values accepted for MapperArguments.Format with a dry run
+var SupportedPlanFormats = []string{FormatDiff, FormatJSON}
+
+// MapperArguments are the arguments for removing a mapRole, mapUsers or mapAccounts
 type MapperArguments struct {
 	KubeconfigPath string
 	Format         string
 	OperationType  OperationType
 	MapRoles       bool
 	MapUsers       bool
+	MapAccounts    bool
 	Force          bool
 	Username       string
 	RoleARN        string
 	UserARN        string
+	AccountID      string
 	Groups         []string
 	WithRetries    bool
 	MinRetryTime   time.Duration
 	MaxRetryTime   time.Duration
 	MaxRetryCount  int
+	Timeout        time.Duration
+	DryRun         DryRunMode
 	IsGlobal       bool
 	Append         bool
 	UpdateUsername *bool
+	// IgnorePath matches role ARNs regardless of their IAM path, the way the authenticator does
+	IgnorePath bool
 
 	AsUser   string
 	AsGroups []string
 }
 
-func (args *MapperArguments) Validate() {
+// Validate checks the arguments and returns all problems found, joined into a single error.
+// Individual problems can be matched with errors.Is against the exported Err values.
+func (args *MapperArguments) Validate() error {
+	var errs []error
+
 	if args.WithRetries {
 		if args.MaxRetryCount < 1 {
-			log.Fatal("error: --retry-max-count is invalid, must be greater than zero")
+			errs = append(errs, ErrInvalidRetryCount)
 		}
 	}
 
-	if args.RoleARN == "" && args.MapRoles {
-		log.Fatal("error: --rolearn not provided")
-	}
+	// get uses the map types and entry fields as a filter, so they are not required together
+	if args.OperationType != OperationGet {
+		if args.RoleARN == "" && args.MapRoles {
+			errs = append(errs, ErrMissingRoleARN)
+		}
+
+		if args.UserARN == "" && args.MapUsers {
+			errs = append(errs, ErrMissingUserARN)
+		}
+
+		if args.AccountID == "" && args.MapAccounts {
+			errs = append(errs, ErrMissingAccountID)
+		}
+
+		if args.MapUsers && args.MapRoles {
+			errs = append(errs, &MutuallyExclusiveError{Flags: []string{"--mapusers", "--maproles"}})
+		}
 
-	if args.UserARN == "" && args.MapUsers {
-		log.Fatal("error: --userarn not provided")
+		if args.MapAccounts && (args.MapUsers || args.MapRoles) {
+			errs = append(errs, &MutuallyExclusiveError{Flags: []string{"--mapaccounts", "--mapusers", "--maproles"}})
+		}
 	}
 
-	if args.MapUsers && args.MapRoles {
-		log.Fatal("error: --mapusers and --maproles are mutually exclusive")
+	if args.OperationType == OperationUpsert && args.Username == "" && !args.MapAccounts {
+		errs = append(errs, ErrMissingUsername)
 	}
 
-	if args.OperationType == OperationUpsert && args.Username == "" {
-		log.Fatal("error: --username not provided")
+	if args.OperationType == OperationGet && !slices.Contains(SupportedFormats, args.Format) {
+		errs = append(errs, fmt.Errorf("%w: %q, supported values are %v", ErrUnsupportedFormat, args.Format, strings.Join(SupportedFormats, ", ")))
 	}
 
-	if args.OperationType == OperationGet && args.Format != "table" {
-		log.Fatal("error: --format only supports value 'table'")
+	switch args.DryRun {
+	case DryRunNone:
+	case DryRunClient, DryRunServer:
+		if args.Format != "" && !slices.Contains(SupportedPlanFormats, args.Format) {
+			errs = append(errs, fmt.Errorf("%w: %q, supported values with --dry-run are %v", ErrUnsupportedFormat, args.Format, strings.Join(SupportedPlanFormats, ", ")))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", ErrInvalidDryRun, args.DryRun))
 	}
 
-	if !args.MapUsers && !args.MapRoles {
+	if !args.MapUsers && !args.MapRoles && !args.MapAccounts {
 		if !args.IsGlobal {
-			log.Fatal("error: must select --mapusers or --maproles")
+			errs = append(errs, ErrMissingMapType)
 		}
 	}
 
@@ -138,6 +265,21 @@ func (args *MapperArguments) Validate() {
 		args.UpdateUsername = &UpdateUsernameDefaultValue
 	}
 
+	return stderrors.Join(errs...)
+}
+
+// validateMapping checks the ARN or account and the username template of a mapping which is about to be written, mappings
+// which are removed are not checked so that malformed entries can still be removed
+func (args *MapperArguments) validateMapping() error {
+	switch {
+	case args.MapRoles:
+		return stderrors.Join(ValidateRoleARN(args.RoleARN), ValidateUsernameTemplate(args.Username))
+	case args.MapUsers:
+		return stderrors.Join(ValidateUserARN(args.UserARN), ValidateUsernameTemplate(args.Username))
+	case args.MapAccounts:
+		return ValidateAccountID(args.AccountID)
+	}
+	return nil
 }
 
 // RolesAuthMap is the basic structure of a mapRoles authentication object
@@ -233,6 +375,20 @@ func (r *RolesAuthMap) AppendGroups(g []string) *RolesAuthMap {
 type RetriableFunction func() (interface{}, error)
 
 func WithRetry(fn RetriableFunction, args *MapperArguments) (interface{}, error) {
+	return WithRetryContext(context.Background(), fn, args)
+}
+
+// WithRetryContext is like WithRetry but stops waiting between attempts when the context is done
+func WithRetryContext(ctx context.Context, fn RetriableFunction, args *MapperArguments) (interface{}, error) {
+	return retryContext(ctx, log.Printf, fn, args)
+}
+
+// withRetry is like WithRetryContext but logs the retries to the logger of the mapper
+func (b *AuthMapper) withRetry(ctx context.Context, fn RetriableFunction, args *MapperArguments) (interface{}, error) {
+	return retryContext(ctx, b.logf, fn, args)
+}
+
+func retryContext(ctx context.Context, logf func(format string, v ...interface{}), fn RetriableFunction, args *MapperArguments) (interface{}, error) {
 	// Update the config map and return an AuthMap
 	var (
 		counter int
@@ -249,9 +405,19 @@ func WithRetry(fn RetriableFunction, args *MapperArguments) (interface{}, error)
 	for counter < args.MaxRetryCount {
 
 		if out, err = fn(); err != nil {
+			if ctx.Err() != nil {
+				return out, errors.Wrapf(ctx.Err(), "waiter cancelled after error: %v", err)
+			}
 			d := bkoff.Duration()
-			log.Printf("error: %v: will retry after %v", err, d)
-			time.Sleep(d)
+			logf("error: %v: will retry after %v", err, d)
+
+			timer := time.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return out, errors.Wrap(ctx.Err(), "waiter cancelled")
+			case <-timer.C:
+			}
 			counter++
 			continue
 		}
@@ -263,4 +429,5 @@ func WithRetry(fn RetriableFunction, args *MapperArguments) (interface{}, error)
 type UpsertOptions struct {
 	Append         bool
 	UpdateUsername bool
+	IgnorePath     bool
 }
@@ -16,6 +16,10 @@ limitations under the License.
 package mapper
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
 	"testing"
 	"time"
 
@@ -31,6 +35,19 @@ func TestNew_LoggingDisabled(t *testing.T) {
 	g.Expect(mapper.KubernetesClient).To(gomega.Equal(client))
 }
 
+func TestMapper_Logger(t *testing.T) {
+	g := gomega.NewWithT(t)
+	client := fake.NewSimpleClientset()
+	create_MockConfigMap(client)
+	mapper := New(client, true)
+
+	var buf bytes.Buffer
+	mapper.Logger = log.New(&buf, "[prod] ", 0)
+	g.Expect(mapper.Upsert(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})).To(gomega.Succeed())
+	g.Expect(mapper.Remove(&MapperArguments{MapAccounts: true, AccountID: "111111111111"})).To(gomega.Succeed())
+	g.Expect(buf.String()).To(gomega.Equal("[prod] account 111111111111 has been updated\n[prod] removed 111111111111 from aws-auth\n"))
+}
+
 func TestNewRolesAuthMap(t *testing.T) {
 	g := gomega.NewWithT(t)
 	r := NewRolesAuthMap("arn:aws:iam::123:role/foo", "myuser", []string{"group1", "group2"})
@@ -129,3 +146,132 @@ func TestWithRetry_ExhaustsRetries(t *testing.T) {
 	g.Expect(err.Error()).To(gomega.ContainSubstring("waiter timed out"))
 	g.Expect(calls).To(gomega.Equal(2))
 }
+
+func TestValidate_Valid(t *testing.T) {
+	g := gomega.NewWithT(t)
+	args := &MapperArguments{
+		OperationType: OperationUpsert,
+		MapRoles:      true,
+		RoleARN:       "arn:aws:iam::123:role/foo",
+		Username:      "foo",
+	}
+	g.Expect(args.Validate()).To(gomega.Succeed())
+	g.Expect(*args.UpdateUsername).To(gomega.BeTrue())
+}
+
+func TestValidate_ReturnsTypedErrors(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	err := (&MapperArguments{OperationType: OperationUpsert, MapRoles: true}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrMissingRoleARN))
+	g.Expect(err).To(gomega.MatchError(ErrMissingUsername))
+
+	err = (&MapperArguments{MapUsers: true}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrMissingUserARN))
+
+	err = (&MapperArguments{MapAccounts: true}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrMissingAccountID))
+
+	err = (&MapperArguments{}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrMissingMapType))
+
+	err = (&MapperArguments{IsGlobal: true, WithRetries: true}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrInvalidRetryCount))
+
+	err = (&MapperArguments{OperationType: OperationGet, IsGlobal: true, Format: "xml"}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrUnsupportedFormat))
+}
+
+func TestValidate_MutuallyExclusive(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	err := (&MapperArguments{
+		MapRoles: true,
+		MapUsers: true,
+		RoleARN:  "arn:aws:iam::123:role/foo",
+		UserARN:  "arn:aws:iam::123:user/foo",
+	}).Validate()
+	g.Expect(err).To(gomega.MatchError(ErrMutuallyExclusive))
+
+	var exclusive *MutuallyExclusiveError
+	g.Expect(errors.As(err, &exclusive)).To(gomega.BeTrue())
+	g.Expect(exclusive.Flags).To(gomega.Equal([]string{"--mapusers", "--maproles"}))
+	g.Expect(err.Error()).To(gomega.Equal("--mapusers, --maproles are mutually exclusive"))
+}
+
+func TestMapper_OperationsReturnValidationErrors(t *testing.T) {
+	g := gomega.NewWithT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, false)
+
+	g.Expect(mapper.Upsert(&MapperArguments{MapRoles: true})).To(gomega.MatchError(ErrMissingRoleARN))
+	g.Expect(mapper.Remove(&MapperArguments{})).To(gomega.MatchError(ErrMissingMapType))
+	g.Expect(mapper.RemoveByUsername(&MapperArguments{WithRetries: true})).To(gomega.MatchError(ErrInvalidRetryCount))
+
+	_, err := mapper.Get(&MapperArguments{OperationType: OperationGet, Format: "xml"})
+	g.Expect(err).To(gomega.MatchError(ErrUnsupportedFormat))
+}
+
+func TestWithRetryContext_StopsWhenCancelled(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	fn := RetriableFunction(func() (interface{}, error) {
+		calls++
+		cancel()
+		return nil, errors.New("always fails")
+	})
+
+	start := time.Now()
+	_, err := WithRetryContext(ctx, fn, &MapperArguments{
+		MaxRetryCount: 5,
+		MinRetryTime:  time.Minute,
+		MaxRetryTime:  time.Minute,
+	})
+	g.Expect(err).To(gomega.MatchError(context.Canceled))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("waiter cancelled"))
+	g.Expect(calls).To(gomega.Equal(1))
+	g.Expect(time.Since(start)).To(gomega.BeNumerically("<", time.Second))
+}
+
+func TestWithRetryContext_DeadlineDuringBackoff(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := WithRetryContext(ctx, func() (interface{}, error) {
+		return nil, errors.New("always fails")
+	}, &MapperArguments{
+		MaxRetryCount: 5,
+		MinRetryTime:  time.Minute,
+		MaxRetryTime:  time.Minute,
+	})
+	g.Expect(err).To(gomega.MatchError(context.DeadlineExceeded))
+}
+
+func TestMapper_OperationsWithCancelledContext(t *testing.T) {
+	g := gomega.NewWithT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := mapper.UpsertWithContext(ctx, &MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::123456789012:role/foo",
+		Username: "foo",
+	})
+	g.Expect(err).To(gomega.MatchError(context.Canceled))
+
+	err = mapper.RemoveWithContext(ctx, &MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::123456789012:role/foo",
+	})
+	g.Expect(err).To(gomega.MatchError(context.Canceled))
+
+	err = mapper.RemoveByUsernameWithContext(ctx, &MapperArguments{Username: "foo"})
+	g.Expect(err).To(gomega.MatchError(context.Canceled))
+
+	err = mapper.UpsertMultipleWithContext(ctx, nil, nil)
+	g.Expect(err).To(gomega.MatchError(context.Canceled))
+}
@@ -16,22 +16,39 @@ limitations under the License.
 package mapper
 
 import (
-	"log"
+	"context"
+	"errors"
 	"reflect"
 )
 
 // Upsert update or inserts by rolearn
 func (b *AuthMapper) Upsert(args *MapperArguments) error {
-	args.Validate()
+	return b.UpsertWithContext(context.Background(), args)
+}
+
+// UpsertWithContext is like Upsert but uses the given context for the API calls and retries
+func (b *AuthMapper) UpsertWithContext(ctx context.Context, args *MapperArguments) error {
+	if err := args.Validate(); err != nil {
+		return err
+	}
+
+	if err := args.validateMapping(); err != nil {
+		return err
+	}
+
+	if args.DryRun != DryRunNone {
+		_, err := b.planMutation(ctx, OperationUpsert, b.upsertMutation(args), args.DryRun)
+		return err
+	}
 
 	if args.WithRetries {
-		_, err := WithRetry(func() (interface{}, error) {
-			return nil, b.upsertAuth(args)
+		_, err := b.withRetry(ctx, func() (interface{}, error) {
+			return nil, b.upsertAuth(ctx, args)
 		}, args)
 		return err
 	}
 
-	return b.upsertAuth(args)
+	return b.upsertAuth(ctx, args)
 }
 
 /**
@@ -39,16 +56,36 @@ func (b *AuthMapper) Upsert(args *MapperArguments) error {
  *  if no changes are required based on new entries, configmap doesn't get updated
  */
 func (b *AuthMapper) UpsertMultiple(newMapRoles []*RolesAuthMap, newMapUsers []*UsersAuthMap) error {
-	updated := false
-	mapRoles := []*RolesAuthMap{}
-	mapUsers := []*UsersAuthMap{}
+	return b.UpsertMultipleWithContext(context.Background(), newMapRoles, newMapUsers)
+}
 
-	// Read the config map and return an AuthMap
-	authData, configMap, err := ReadAuthMap(b.KubernetesClient)
-	if err != nil {
+// UpsertMultipleWithContext is like UpsertMultiple but uses the given context for the API calls
+func (b *AuthMapper) UpsertMultipleWithContext(ctx context.Context, newMapRoles []*RolesAuthMap, newMapUsers []*UsersAuthMap) error {
+	var errs []error
+	for _, r := range newMapRoles {
+		errs = append(errs, ValidateRoleARN(r.RoleARN), ValidateUsernameTemplate(r.Username))
+	}
+	for _, u := range newMapUsers {
+		errs = append(errs, ValidateUserARN(u.UserARN), ValidateUsernameTemplate(u.Username))
+	}
+	if err := errors.Join(errs...); err != nil {
 		return err
 	}
 
+	return b.mutateAuthMap(ctx, OperationUpsertMultiple, func(authData *AwsAuthData) (bool, error) {
+		if !upsertMultiple(authData, newMapRoles, newMapUsers) {
+			b.logf("found zero changes to update, configmap is not changed \n")
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+func upsertMultiple(authData *AwsAuthData, newMapRoles []*RolesAuthMap, newMapUsers []*UsersAuthMap) bool {
+	updated := false
+	mapRoles := []*RolesAuthMap{}
+	mapUsers := []*UsersAuthMap{}
+
 	// Insert all new mapRole entries
 	for _, newMember := range newMapRoles {
 		found := false
@@ -130,59 +167,68 @@ func (b *AuthMapper) UpsertMultiple(newMapRoles []*RolesAuthMap, newMapUsers []*
 	}
 
 	if !updated {
-		log.Printf("found zero changes to update, configmap is not changed \n")
-		return nil
+		return false
 	}
 
 	authData.SetMapRoles(mapRoles)
-	authData.SetMapUsers((mapUsers))
-
-	// Update the config map and return an AuthMap
-	err = UpdateAuthMap(b.KubernetesClient, authData, configMap)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	authData.SetMapUsers(mapUsers)
+	return true
 }
 
-func (b *AuthMapper) upsertAuth(args *MapperArguments) error {
-	// Read the config map and return an AuthMap
-	authData, configMap, err := ReadAuthMap(b.KubernetesClient)
-	if err != nil {
-		return err
-	}
+func (b *AuthMapper) upsertAuth(ctx context.Context, args *MapperArguments) error {
+	return b.mutateAuthMap(ctx, OperationUpsert, b.upsertMutation(args))
+}
 
+// upsertMutation returns the mutation which upserts the role, user or account of args
+func (b *AuthMapper) upsertMutation(args *MapperArguments) authMapMutation {
 	opts := &UpsertOptions{
 		Append:         args.Append,
 		UpdateUsername: *args.UpdateUsername,
+		IgnorePath:     args.IgnorePath,
 	}
 
-	if args.MapRoles {
-		var roleResource = NewRolesAuthMap(args.RoleARN, args.Username, args.Groups)
+	return func(authData *AwsAuthData) (bool, error) {
+		var updated bool
+
+		if args.MapRoles {
+			var roleResource = NewRolesAuthMap(args.RoleARN, args.Username, args.Groups)
 
-		newMap, ok := upsertRole(authData.MapRoles, roleResource, opts)
-		if ok {
-			log.Printf("role %v has been updated\n", roleResource.RoleARN)
-		} else {
-			log.Printf("no updates needed to %v\n", roleResource.RoleARN)
+			newMap, ok := upsertRole(authData.MapRoles, roleResource, opts)
+			if ok {
+				b.logf("role %v has been updated\n", roleResource.RoleARN)
+			} else {
+				b.logf("no updates needed to %v\n", roleResource.RoleARN)
+			}
+			authData.SetMapRoles(newMap)
+			updated = updated || ok
 		}
-		authData.SetMapRoles(newMap)
-	}
 
-	if args.MapUsers {
-		var userResource = NewUsersAuthMap(args.UserARN, args.Username, args.Groups)
+		if args.MapUsers {
+			var userResource = NewUsersAuthMap(args.UserARN, args.Username, args.Groups)
 
-		newMap, ok := upsertUser(authData.MapUsers, userResource, opts)
-		if ok {
-			log.Printf("role %v has been updated\n", userResource.UserARN)
-		} else {
-			log.Printf("no updates needed to %v\n", userResource.UserARN)
+			newMap, ok := upsertUser(authData.MapUsers, userResource, opts)
+			if ok {
+				b.logf("role %v has been updated\n", userResource.UserARN)
+			} else {
+				b.logf("no updates needed to %v\n", userResource.UserARN)
+			}
+			authData.SetMapUsers(newMap)
+			updated = updated || ok
+		}
+
+		if args.MapAccounts {
+			newMap, ok := upsertAccount(authData.MapAccounts, args.AccountID)
+			if ok {
+				b.logf("account %v has been updated\n", args.AccountID)
+			} else {
+				b.logf("no updates needed to %v\n", args.AccountID)
+			}
+			authData.SetMapAccounts(newMap)
+			updated = updated || ok
 		}
-		authData.SetMapUsers(newMap)
-	}
 
-	return UpdateAuthMap(b.KubernetesClient, authData, configMap)
+		return updated, nil
+	}
 }
 
 func upsertRole(authMaps []*RolesAuthMap, resource *RolesAuthMap, opts *UpsertOptions) ([]*RolesAuthMap, bool) {
@@ -190,7 +236,7 @@ func upsertRole(authMaps []*RolesAuthMap, resource *RolesAuthMap, opts *UpsertOp
 	var updated bool
 	for _, existing := range authMaps {
 		// Update
-		if existing.RoleARN == resource.RoleARN {
+		if roleARNEqual(existing.RoleARN, resource.RoleARN, opts.IgnorePath) {
 			match = true
 			if !reflect.DeepEqual(existing.Groups, resource.Groups) {
 				if opts.Append {
@@ -248,3 +294,12 @@ func upsertUser(authMaps []*UsersAuthMap, resource *UsersAuthMap, opts *UpsertOp
 	}
 	return authMaps, updated
 }
+
+func upsertAccount(accounts []string, account string) ([]string, bool) {
+	for _, existing := range accounts {
+		if existing == account {
+			return accounts, false
+		}
+	}
+	return append(accounts, account), true
+}
@@ -32,7 +32,7 @@ func TestMapper_UpsertInsert(t *testing.T) {
 
 	err := mapper.Upsert(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-2",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-2",
 		Username: "system:node:{{EC2PrivateDNSName}}",
 		Groups:   []string{"system:bootstrappers", "system:nodes"},
 	})
@@ -40,7 +40,7 @@ func TestMapper_UpsertInsert(t *testing.T) {
 
 	err = mapper.Upsert(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-2",
+		UserARN:  "arn:aws:iam::000000000000:user/user-2",
 		Username: "admin",
 		Groups:   []string{"system:masters"},
 	})
@@ -61,7 +61,7 @@ func TestMapper_UpsertUpdate(t *testing.T) {
 
 	err := mapper.Upsert(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"system:some-role"},
 	})
@@ -69,7 +69,7 @@ func TestMapper_UpsertUpdate(t *testing.T) {
 
 	err = mapper.Upsert(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"system:some-role"},
 	})
@@ -79,10 +79,10 @@ func TestMapper_UpsertUpdate(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
 }
@@ -96,7 +96,7 @@ func TestMapper_UpsertNotNeeded(t *testing.T) {
 
 	err := mapper.Upsert(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "system:node:{{EC2PrivateDNSName}}",
 		Groups:   []string{"system:bootstrappers", "system:nodes"},
 	})
@@ -104,7 +104,7 @@ func TestMapper_UpsertNotNeeded(t *testing.T) {
 
 	err = mapper.Upsert(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "admin",
 		Groups:   []string{"system:masters"},
 	})
@@ -124,7 +124,7 @@ func TestMapper_UpsertWithCreate(t *testing.T) {
 
 	err := mapper.Upsert(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"system:some-role"},
 	})
@@ -132,7 +132,7 @@ func TestMapper_UpsertWithCreate(t *testing.T) {
 
 	err = mapper.Upsert(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"system:some-role"},
 	})
@@ -142,10 +142,10 @@ func TestMapper_UpsertWithCreate(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
 }
@@ -158,13 +158,13 @@ func TestMapper_UpsertMultipleInsert(t *testing.T) {
 	create_MockConfigMap(client)
 
 	role2 := &RolesAuthMap{
-		RoleARN:  "arn:aws:iam::00000000000:role/node-2",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-2",
 		Username: "system:node:{{EC2PrivateDNSName}}",
 		Groups:   []string{"system:bootstrappers", "system:nodes"},
 	}
 
 	role3 := &RolesAuthMap{
-		RoleARN:  "arn:aws:iam::00000000000:role/node-3",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-3",
 		Username: "system:node:{{EC2PrivateDNSName}}",
 		Groups:   []string{"system:bootstrappers", "system:nodes"},
 	}
@@ -178,7 +178,7 @@ func TestMapper_UpsertMultipleInsert(t *testing.T) {
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
 
 	mapUser2 := &UsersAuthMap{
-		UserARN:  "arn:aws:iam::00000000000:user/user-2",
+		UserARN:  "arn:aws:iam::000000000000:user/user-2",
 		Username: "admin",
 		Groups:   []string{"system:masters"},
 	}
@@ -200,7 +200,7 @@ func TestMapper_UpsertMultipleUpdate(t *testing.T) {
 	create_MockConfigMap(client)
 
 	role1 := &RolesAuthMap{
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"system:some-role"},
 	}
@@ -212,15 +212,15 @@ func TestMapper_UpsertMultipleUpdate(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("admin"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.Equal([]string{"system:masters"}))
 
 	mapUser1 := &UsersAuthMap{
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"system:some-role"},
 	}
@@ -232,10 +232,10 @@ func TestMapper_UpsertMultipleUpdate(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
 }
@@ -248,13 +248,13 @@ func TestMapper_UpsertMultipleNotNeeded(t *testing.T) {
 	create_MockConfigMap(client)
 
 	role1 := &RolesAuthMap{
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "system:node:{{EC2PrivateDNSName}}",
 		Groups:   []string{"system:bootstrappers", "system:nodes"},
 	}
 
 	mapUser1 := &UsersAuthMap{
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "admin",
 		Groups:   []string{"system:masters"},
 	}
@@ -275,13 +275,13 @@ func TestMapper_UpsertMultipleWithCreate(t *testing.T) {
 	mapper := New(client, true)
 
 	role1 := &RolesAuthMap{
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"system:some-role"},
 	}
 
 	mapUser1 := &UsersAuthMap{
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"system:some-role"},
 	}
@@ -293,10 +293,10 @@ func TestMapper_UpsertMultipleWithCreate(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
 }
@@ -308,13 +308,13 @@ func TestMapper_UpsertEmptyGroups(t *testing.T) {
 	mapper := New(client, true)
 
 	role1 := &RolesAuthMap{
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "this:is:a:test",
 		Groups:   []string{},
 	}
 
 	mapUser1 := &UsersAuthMap{
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "this:is:a:test",
 		Groups:   []string{},
 	}
@@ -326,10 +326,10 @@ func TestMapper_UpsertEmptyGroups(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.BeNil())
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.BeNil())
 }
@@ -343,7 +343,7 @@ func TestMapper_UpsertWithRetries(t *testing.T) {
 
 	err := mapper.Upsert(&MapperArguments{
 		MapRoles:      true,
-		RoleARN:       "arn:aws:iam::00000000000:role/node-2",
+		RoleARN:       "arn:aws:iam::000000000000:role/node-2",
 		Username:      "system:node:{{EC2PrivateDNSName}}",
 		Groups:        []string{"system:bootstrappers", "system:nodes"},
 		WithRetries:   true,
@@ -355,7 +355,7 @@ func TestMapper_UpsertWithRetries(t *testing.T) {
 
 	err = mapper.Upsert(&MapperArguments{
 		MapUsers:      true,
-		UserARN:       "arn:aws:iam::00000000000:user/user-2",
+		UserARN:       "arn:aws:iam::000000000000:user/user-2",
 		Username:      "admin",
 		Groups:        []string{"system:masters"},
 		WithRetries:   true,
@@ -380,7 +380,7 @@ func TestUpsertWithRetries(t *testing.T) {
 
 	err := mapper.Upsert(&MapperArguments{
 		MapRoles:      true,
-		RoleARN:       "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:       "arn:aws:iam::000000000000:role/node-1",
 		Username:      "this:is:a:test",
 		Groups:        []string{"system:some-role"},
 		WithRetries:   true,
@@ -392,7 +392,7 @@ func TestUpsertWithRetries(t *testing.T) {
 
 	err = mapper.Upsert(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"system:some-role"},
 	})
@@ -402,10 +402,10 @@ func TestUpsertWithRetries(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.Equal([]string{"system:some-role"}))
 }
@@ -419,7 +419,7 @@ func TestMapper_UpsertUpdateAppendGroups(t *testing.T) {
 
 	err := mapper.Upsert(&MapperArguments{
 		MapRoles: true,
-		RoleARN:  "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:  "arn:aws:iam::000000000000:role/node-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"appendedGroup"},
 		Append:   true,
@@ -428,7 +428,7 @@ func TestMapper_UpsertUpdateAppendGroups(t *testing.T) {
 
 	err = mapper.Upsert(&MapperArguments{
 		MapUsers: true,
-		UserARN:  "arn:aws:iam::00000000000:user/user-1",
+		UserARN:  "arn:aws:iam::000000000000:user/user-1",
 		Username: "this:is:a:test",
 		Groups:   []string{"appendedGroup"},
 		Append:   true,
@@ -439,10 +439,10 @@ func TestMapper_UpsertUpdateAppendGroups(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:bootstrappers", "system:nodes", "appendedGroup"}))
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("this:is:a:test"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.Equal([]string{"system:masters", "appendedGroup"}))
 }
@@ -492,7 +492,7 @@ func TestMapper_UpdateUsername(t *testing.T) {
 
 	err := mapper.Upsert(&MapperArguments{
 		MapRoles:       true,
-		RoleARN:        "arn:aws:iam::00000000000:role/node-1",
+		RoleARN:        "arn:aws:iam::000000000000:role/node-1",
 		Username:       "this:is:a:test",
 		Groups:         []string{"system:bootstrappers", "system:nodes"},
 		UpdateUsername: &updateUsername,
@@ -501,7 +501,7 @@ func TestMapper_UpdateUsername(t *testing.T) {
 
 	err = mapper.Upsert(&MapperArguments{
 		MapUsers:       true,
-		UserARN:        "arn:aws:iam::00000000000:user/user-1",
+		UserARN:        "arn:aws:iam::000000000000:user/user-1",
 		Username:       "this:is:a:test",
 		Groups:         []string{"system:masters"},
 		UpdateUsername: &updateUsername,
@@ -512,10 +512,44 @@ func TestMapper_UpdateUsername(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(len(auth.MapRoles)).To(gomega.Equal(1))
 	g.Expect(len(auth.MapUsers)).To(gomega.Equal(1))
-	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::00000000000:role/node-1"))
+	g.Expect(auth.MapRoles[0].RoleARN).To(gomega.Equal("arn:aws:iam::000000000000:role/node-1"))
 	g.Expect(auth.MapRoles[0].Username).To(gomega.Equal("system:node:{{EC2PrivateDNSName}}"))
 	g.Expect(auth.MapRoles[0].Groups).To(gomega.Equal([]string{"system:bootstrappers", "system:nodes"}))
-	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::00000000000:user/user-1"))
+	g.Expect(auth.MapUsers[0].UserARN).To(gomega.Equal("arn:aws:iam::000000000000:user/user-1"))
 	g.Expect(auth.MapUsers[0].Username).To(gomega.Equal("admin"))
 	g.Expect(auth.MapUsers[0].Groups).To(gomega.Equal([]string{"system:masters"}))
 }
+
+func TestMapper_UpsertAccount(t *testing.T) {
+	g := gomega.NewWithT(t)
+	gomega.RegisterTestingT(t)
+	client := fake.NewSimpleClientset()
+	mapper := New(client, true)
+	create_MockConfigMapWithAccounts(client)
+
+	err := mapper.Upsert(&MapperArguments{
+		MapAccounts: true,
+		AccountID:   "333333333333",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// Upserting an existing account is a no-op
+	err = mapper.Upsert(&MapperArguments{
+		MapAccounts: true,
+		AccountID:   "111111111111",
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	err = mapper.Upsert(&MapperArguments{
+		MapRoles: true,
+		RoleARN:  "arn:aws:iam::000000000000:role/node-2",
+		Username: "system:node:{{EC2PrivateDNSName}}",
+		Groups:   []string{"system:bootstrappers", "system:nodes"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	auth, _, err := ReadAuthMap(client)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(len(auth.MapRoles)).To(gomega.Equal(2))
+	g.Expect(auth.MapAccounts).To(gomega.Equal([]string{"111111111111", "222222222222", "333333333333"}))
+}